@@ -0,0 +1,140 @@
+package Utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rate is a request rate limit of Requests per Per, allowing bursts of up to Burst requests.
+//
+// The zero Rate is no limit.
+type Rate struct {
+	// Requests is the number of requests allowed each Per.
+	Requests float64
+	// Per is the period the requests are spread over.
+	Per time.Duration
+	// Burst is the number of requests which may be sent back to back. Values
+	// below 1 are treated as 1.
+	Burst int
+}
+
+// rateUnits maps the unit names accepted by ParseRate to durations.
+var rateUnits = map[string]time.Duration{
+	"ns": time.Nanosecond, "us": time.Microsecond, "µs": time.Microsecond,
+	"ms": time.Millisecond, "msec": time.Millisecond, "millisecond": time.Millisecond,
+	"s": time.Second, "sec": time.Second, "second": time.Second,
+	"m": time.Minute, "min": time.Minute, "minute": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hour": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour,
+}
+
+// ParseRate parses a human readable request rate.
+//
+// Accepted forms are "<n>/<period>" and "<n> per <period>" where the period is
+// a unit, a number and unit or a Go duration, e.g. "500/min", "10/s",
+// "1 per 2s" or "100 per 1.5 minutes". A bare duration such as "40ms" is one request per
+// duration. Any form may be followed by "burst <n>", e.g. "10/s burst 5".
+//
+// Parameters:
+//   - rate (string): The rate to parse.
+//
+// Returns:
+//   - Rate: The parsed rate.
+//   - error: An error describing why the rate is invalid.
+func ParseRate(rate string) (Rate, error) {
+	text := strings.ToLower(strings.TrimSpace(rate))
+	var parsed Rate
+	// Parse the burst
+	if idx := strings.LastIndex(text, "burst"); idx >= 0 {
+		burst, err := strconv.Atoi(strings.TrimSpace(text[idx+len("burst"):]))
+		if err != nil || burst < 1 {
+			return Rate{}, fmt.Errorf("invalid burst in rate %q", rate)
+		}
+		parsed.Burst = burst
+		text = strings.TrimRight(strings.TrimSpace(text[:idx]), ",")
+	}
+	count, period, found := strings.Cut(text, "/")
+	if !found {
+		count, period, found = strings.Cut(text, " per ")
+	}
+	if !found {
+		// A bare duration
+		duration, err := parsePeriod(text)
+		if err != nil {
+			return Rate{}, fmt.Errorf("invalid rate %q: expected <requests>/<period> or a duration", rate)
+		}
+		parsed.Requests, parsed.Per = 1, duration
+		return parsed, nil
+	}
+	requests, err := strconv.ParseFloat(strings.TrimSpace(count), 64)
+	if err != nil || requests <= 0 {
+		return Rate{}, fmt.Errorf("invalid request count in rate %q", rate)
+	}
+	per, err := parsePeriod(period)
+	if err != nil {
+		return Rate{}, fmt.Errorf("invalid period in rate %q: %w", rate, err)
+	}
+	parsed.Requests, parsed.Per = requests, per
+	return parsed, nil
+}
+
+// parsePeriod parses a unit such as "min", a number and unit such as "1.5 minutes"
+// or a Go duration such as "1m30s".
+func parsePeriod(period string) (time.Duration, error) {
+	period = strings.TrimSpace(period)
+	if duration, err := time.ParseDuration(period); err == nil && duration > 0 {
+		return duration, nil
+	}
+	split := strings.IndexFunc(period, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if split < 0 {
+		return 0, fmt.Errorf("missing unit in %q", period)
+	}
+	amount := 1.0
+	if split > 0 {
+		var err error
+		if amount, err = strconv.ParseFloat(period[:split], 64); err != nil {
+			return 0, err
+		}
+	}
+	unitName := strings.TrimSpace(period[split:])
+	unit, exists := rateUnits[unitName]
+	if !exists {
+		// Plurals such as "minutes"
+		unit, exists = rateUnits[strings.TrimSuffix(unitName, "s")]
+	}
+	if !exists {
+		return 0, fmt.Errorf("unknown unit %q", unitName)
+	}
+	duration := time.Duration(amount * float64(unit))
+	if duration <= 0 {
+		return 0, fmt.Errorf("period %q must be positive", period)
+	}
+	return duration, nil
+}
+
+// Interval returns the delay between requests sent at the rate.
+//
+// Returns:
+//   - time.Duration: The delay, 0 for no limit.
+func (rate Rate) Interval() time.Duration {
+	if rate.Requests <= 0 {
+		return 0
+	}
+	return time.Duration(float64(rate.Per) / rate.Requests)
+}
+
+// String formats the rate in the form accepted by ParseRate.
+func (rate Rate) String() string {
+	if rate.Requests <= 0 {
+		return "unlimited"
+	}
+	text := strconv.FormatFloat(rate.Requests, 'f', -1, 64) + " per " + rate.Per.String()
+	if rate.Burst > 1 {
+		text += " burst " + strconv.Itoa(rate.Burst)
+	}
+	return text
+}
@@ -0,0 +1,64 @@
+package Utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		rate     string
+		interval time.Duration
+		burst    int
+	}{
+		{"500/min", 120 * time.Millisecond, 0},
+		{"10/s burst 5", 100 * time.Millisecond, 5},
+		{"1 per 2s", 2 * time.Second, 0},
+		{"100 per 1.5 minutes", 900 * time.Millisecond, 0},
+		{"3/h, burst 2", 20 * time.Minute, 2},
+		{"0.5/sec", 2 * time.Second, 0},
+		{"40ms", 40 * time.Millisecond, 0},
+		{"2 / 500ms", 250 * time.Millisecond, 0},
+		{"86400/day", time.Second, 0},
+	}
+	for _, test := range tests {
+		rate, err := ParseRate(test.rate)
+		if err != nil {
+			t.Errorf("ParseRate(%q): %v", test.rate, err)
+			continue
+		}
+		if rate.Interval() != test.interval || rate.Burst != test.burst {
+			t.Errorf("ParseRate(%q) = %s, expected interval %s burst %d", test.rate, rate, test.interval, test.burst)
+		}
+		// String round trips
+		if again, err := ParseRate(rate.String()); err != nil || again != rate {
+			t.Errorf("ParseRate(%q) = %+v, %v", rate.String(), again, err)
+		}
+	}
+	for _, rate := range []string{"", "fast", "0/s", "-1/s", "5/fortnight", "5/s burst", "5/s burst 0", "1 per"} {
+		if _, err := ParseRate(rate); err == nil {
+			t.Errorf("Expected an error for %q", rate)
+		}
+	}
+	if (Rate{}).Interval() != 0 {
+		t.Error("Expected the zero Rate to have no interval")
+	}
+}
+
+func TestMillisecondToDuration(t *testing.T) {
+	if MillisecondToDuration(1.5) != 1500*time.Microsecond {
+		t.Errorf("Fractional milliseconds lost: %s", MillisecondToDuration(1.5))
+	}
+	if MillisecondToDuration(int64(20)) != 20*time.Millisecond || MillisecondToDuration(uint8(3)) != 3*time.Millisecond {
+		t.Error("Unexpected integer conversion")
+	}
+	if MillisecondToDuration(time.Duration(7)) != 7*time.Millisecond {
+		t.Error("Unexpected time.Duration conversion")
+	}
+	if MillisecondToDuration(-5) != 0 {
+		t.Error("Expected negative values to be rounded to 0")
+	}
+	if delay := RpsToDuration(3); delay < 333333*time.Microsecond || delay > 333334*time.Microsecond {
+		t.Errorf("Unexpected RpsToDuration(3) = %s", RpsToDuration(3))
+	}
+}
@@ -9,29 +9,30 @@ import (
 	"os"
 )
 
+// Number is the constraint satisfied by every integer and floating point type,
+// including named types such as time.Duration.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
 // MillisecondToDuration converts a time duration in milliseconds to a time.Duration.
 //
-// The ms argument must be able to be cast as a float64. Negative values will be rounded to 0.
-// The function supports int, float32, and float64 types for the ms argument.
+// Negative values will be rounded to 0. Fractional milliseconds are kept to the
+// nanosecond.
 //
 // Parameters:
-//   - ms (interface{}): The time duration in milliseconds, which can be an int, float32, or float64.
+//   - ms (Number): The time duration in milliseconds, which can be any integer or float type.
 //
 // Returns:
 //   - time.Duration: The equivalent time.Duration value.
-func MillisecondToDuration(ms interface{}) time.Duration {
-  var msValue float64
-	switch ms.(type) {
-	case int:
-		msValue = float64(ms.(int))
-	case float32:
-		msValue = float64(ms.(float32))
-	case float64:
-		msValue = ms.(float64)
-	default:
-    panic("Expected int or float ms value")
+func MillisecondToDuration[T Number](ms T) time.Duration {
+	msValue := float64(ms)
+	if msValue <= 0 {
+		return 0
 	}
-	return time.Millisecond * time.Duration(msValue)
+	return time.Duration(msValue * float64(time.Millisecond))
 }
 
 // RpsToDuration converts a rate per second (rps) to a time.Duration delay.
@@ -47,7 +48,7 @@ func RpsToDuration(rps float32) time.Duration {
 	if rps <= 0 {
 		return time.Duration(0)
 	} else {
-		return MillisecondToDuration(1000 / rps)
+		return MillisecondToDuration(1000 / float64(rps))
 	}
 }
 
@@ -0,0 +1,180 @@
+package HttpClientPool
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// adaptiveHistorySize is the number of AdaptiveSamples kept by a ClientPool.
+const adaptiveHistorySize = 1000
+
+// AdaptivePolicy configures adaptive rate limiting for a ClientPool.
+//
+// Delays shrink additively by Step after each healthy response and grow
+// multiplicatively by Backoff after a 429 or 503 response, a timeout or a ban.
+// The pool delay is only adapted if MaxPoolDelay is set and client delays are
+// only adapted if MaxClientDelay is set.
+type AdaptivePolicy struct {
+	// MinPoolDelay and MaxPoolDelay bound the pool delay.
+	MinPoolDelay time.Duration
+	MaxPoolDelay time.Duration
+
+	// MinClientDelay and MaxClientDelay bound each client delay.
+	MinClientDelay time.Duration
+	MaxClientDelay time.Duration
+
+	// Step is subtracted from the delays after a healthy response. It is also
+	// the delay used when backing off from a delay of 0.
+	Step time.Duration
+
+	// Backoff multiplies the delays after a rate limit signal. Defaults to 2.
+	Backoff float64
+
+	// OnChange is called with each new sample when a delay changes. It must not
+	// call back into the pool.
+	OnChange func(sample AdaptiveSample)
+}
+
+// AdaptiveSample records the delays after an adjustment.
+type AdaptiveSample struct {
+	// Time is when the adjustment was made.
+	Time time.Time
+	// Backoff is true if the delays grew and false if they shrank.
+	Backoff bool
+	// PoolDelay is the new pool delay.
+	PoolDelay time.Duration
+	// ClientDelay is the new delay of the client which made the request.
+	ClientDelay time.Duration
+	// Client is the client which made the request.
+	Client *Client
+}
+
+// adaptiveLimiter adjusts pool and client delays according to an AdaptivePolicy.
+type adaptiveLimiter struct {
+	policy  AdaptivePolicy
+	history []AdaptiveSample
+	mu      sync.Mutex
+}
+
+// SetAdaptivePolicy enables adaptive rate limiting for the pool's QuickRequest.
+//
+// The current pool and client delays are used as the starting point.
+//
+// Parameters:
+//   - policy (AdaptivePolicy): The adaptive policy. Use an empty AdaptivePolicy to disable.
+func (pool *ClientPool) SetAdaptivePolicy(policy AdaptivePolicy) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	if policy.MaxPoolDelay == 0 && policy.MaxClientDelay == 0 {
+		pool.adaptive = nil
+		return
+	}
+	if policy.Backoff <= 1 {
+		policy.Backoff = 2
+	}
+	pool.adaptive = &adaptiveLimiter{policy: policy}
+}
+
+// GetAdaptiveHistory returns the most recent delay adjustments, oldest first.
+//
+// Returns:
+//   - []AdaptiveSample: Up to the last 1000 adjustments.
+func (pool *ClientPool) GetAdaptiveHistory() []AdaptiveSample {
+	pool.mu.Lock()
+	limiter := pool.adaptive
+	pool.mu.Unlock()
+	if limiter == nil {
+		return nil
+	}
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	return append([]AdaptiveSample(nil), limiter.history...)
+}
+
+// adapt adjusts the delays following the result of a request.
+//
+// Parameters:
+//   - client (*Client): The client which made the request.
+//   - response (ResponseData): The response received.
+//   - err (error): The request error, if any.
+//   - banned (bool): True if the response was detected as a ban.
+func (pool *ClientPool) adapt(client *Client, response ResponseData, err error, banned bool) {
+	pool.mu.Lock()
+	limiter := pool.adaptive
+	pool.mu.Unlock()
+	if limiter == nil || client == nil {
+		return
+	}
+	backoff := banned || isRateLimitSignal(response, err)
+	if err != nil && !backoff {
+		// Errors unrelated to rate limiting are ignored
+		return
+	}
+	policy := limiter.policy
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	sample := AdaptiveSample{
+		Time:        pool.GetClock().Now(),
+		Backoff:     backoff,
+		PoolDelay:   pool.GetPoolDelay(),
+		ClientDelay: client.GetDelay(),
+		Client:      client,
+	}
+	changed := false
+	if policy.MaxPoolDelay > 0 {
+		delay := policy.next(sample.PoolDelay, policy.MinPoolDelay, policy.MaxPoolDelay, backoff)
+		if delay != sample.PoolDelay {
+			pool.SetPoolDelay(delay)
+			sample.PoolDelay, changed = delay, true
+		}
+	}
+	if policy.MaxClientDelay > 0 {
+		delay := policy.next(sample.ClientDelay, policy.MinClientDelay, policy.MaxClientDelay, backoff)
+		if delay != sample.ClientDelay {
+			client.SetDelay(delay)
+			sample.ClientDelay, changed = delay, true
+		}
+	}
+	if !changed {
+		return
+	}
+	if len(limiter.history) >= adaptiveHistorySize {
+		limiter.history = append(limiter.history[:0], limiter.history[1:]...)
+	}
+	limiter.history = append(limiter.history, sample)
+	if policy.OnChange != nil {
+		policy.OnChange(sample)
+	}
+}
+
+// next returns the adjusted delay bounded by min and max.
+func (policy AdaptivePolicy) next(delay, min, max time.Duration, backoff bool) time.Duration {
+	if backoff {
+		delay = time.Duration(float64(delay) * policy.Backoff)
+		if delay < policy.Step {
+			delay = policy.Step
+		}
+	} else {
+		delay -= policy.Step
+	}
+	if delay < min {
+		delay = min
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// isRateLimitSignal returns true for 429 and 503 responses and timeouts.
+func isRateLimitSignal(response ResponseData, err error) bool {
+	if err != nil {
+		var netErr net.Error
+		return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+	}
+	return response.StatusCode == http.StatusTooManyRequests || response.StatusCode == http.StatusServiceUnavailable
+}
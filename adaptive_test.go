@@ -0,0 +1,88 @@
+package HttpClientPool
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Runs requests against a server enforcing a hidden limit of one request per 10ms
+func TestAdaptivePolicy(t *testing.T) {
+	const hiddenDelay = 10 * time.Millisecond
+	clock := NewFakeClock(time.Unix(0, 0))
+	clock.SetAutoAdvance(true)
+	var mu sync.Mutex
+	var lastAllowed time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if clock.Now().Sub(lastAllowed) < hiddenDelay {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		lastAllowed = clock.Now()
+	}))
+	defer server.Close()
+	pool := NewClientPool(0, 0, nil, nil)
+	pool.SetClock(clock)
+	var changes int
+	pool.SetAdaptivePolicy(AdaptivePolicy{
+		MinPoolDelay: time.Millisecond,
+		MaxPoolDelay: 50 * time.Millisecond,
+		Step:         time.Millisecond,
+		OnChange:     func(sample AdaptiveSample) { changes++ },
+	})
+	const requests = 100
+	var limited int
+	for i := 0; i < requests; i++ {
+		response, err := pool.QuickRequest(RequestData{Type: "GET", Url: server.URL})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i >= requests/2 && response.StatusCode == http.StatusTooManyRequests {
+			limited++
+		}
+	}
+	// After converging most requests should succeed
+	if limited > requests/2/4 {
+		t.Errorf("Too many rate limited requests after converging (%d)", limited)
+	}
+	poolDelay := pool.GetPoolDelay()
+	if poolDelay < time.Millisecond || poolDelay > 50*time.Millisecond {
+		t.Errorf("Pool delay out of bounds (%v)", poolDelay)
+	}
+	history := pool.GetAdaptiveHistory()
+	if len(history) == 0 || len(history) != changes {
+		t.Errorf("Unexpected history length %d with %d changes", len(history), changes)
+	}
+	backoffs := 0
+	for _, sample := range history {
+		if sample.Backoff {
+			backoffs++
+		}
+	}
+	if backoffs == 0 {
+		t.Error("Expected at least one backoff")
+	}
+}
+
+func TestAdaptiveNext(t *testing.T) {
+	policy := AdaptivePolicy{Step: time.Millisecond, Backoff: 2}
+	tests := []struct {
+		delay, expected time.Duration
+		backoff         bool
+	}{
+		{0, time.Millisecond, true},
+		{4 * time.Millisecond, 8 * time.Millisecond, true},
+		{40 * time.Millisecond, 50 * time.Millisecond, true},
+		{4 * time.Millisecond, 3 * time.Millisecond, false},
+		{time.Millisecond, time.Millisecond, false},
+	}
+	for _, test := range tests {
+		if delay := policy.next(test.delay, time.Millisecond, 50*time.Millisecond, test.backoff); delay != test.expected {
+			t.Errorf("Expected %v got %v", test.expected, delay)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package HttpClientPool
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Authenticator adds credentials to requests made by a Client.
+//
+// Authenticate is called by QuickRequest after the params, headers, user agent
+// and cookies have been set.
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// RefreshingAuthenticator is an Authenticator whose credentials can be refreshed.
+//
+// When a request is answered with 401 Unauthorized, QuickRequest calls Invalidate
+// and retries the request once with fresh credentials.
+type RefreshingAuthenticator interface {
+	Authenticator
+	// Invalidate discards any cached credentials.
+	Invalidate()
+}
+
+// BasicAuth authenticates requests with HTTP basic authentication.
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// Authenticate sets the Authorization header.
+func (auth BasicAuth) Authenticate(req *http.Request) error {
+	req.SetBasicAuth(auth.Username, auth.Password)
+	return nil
+}
+
+// BearerToken authenticates requests with a static bearer token.
+type BearerToken string
+
+// Authenticate sets the Authorization header.
+func (token BearerToken) Authenticate(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+string(token))
+	return nil
+}
+
+// APIKey authenticates requests with an API key sent in a header or url parameter.
+type APIKey struct {
+	// Name is the header or url parameter name (e.g., X-Api-Key).
+	Name string
+	// Value is the API key.
+	Value string
+	// InQuery sends the key as a url parameter instead of a header.
+	InQuery bool
+}
+
+// Authenticate sets the API key header or url parameter.
+func (auth APIKey) Authenticate(req *http.Request) error {
+	if auth.InQuery {
+		q := req.URL.Query()
+		q.Set(auth.Name, auth.Value)
+		req.URL.RawQuery = q.Encode()
+	} else {
+		req.Header.Set(auth.Name, auth.Value)
+	}
+	return nil
+}
+
+// OAuth2ClientCredentials authenticates requests with a bearer token obtained
+// using the OAuth2 client credentials grant.
+//
+// Tokens are cached and shared by every Client using the authenticator. A new
+// token is requested when the cached token is within ExpiryDelta of expiring or
+// after the server rejects it with 401 Unauthorized.
+type OAuth2ClientCredentials struct {
+	// TokenURL is the token endpoint of the authorization server.
+	TokenURL string
+	// ClientID and ClientSecret are sent using HTTP basic authentication.
+	ClientID     string
+	ClientSecret string
+	// Scopes are the requested scopes. Use nil for the default scopes.
+	Scopes []string
+	// EndpointParams contains additional parameters sent to the token endpoint.
+	EndpointParams url.Values
+	// ExpiryDelta is how long before expiry a token is refreshed. Defaults to 10 seconds.
+	ExpiryDelta time.Duration
+	// HTTPClient is used to request tokens. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// oauth2Token is the token endpoint response.
+type oauth2Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// Authenticate sets the Authorization header, requesting a new token if required.
+func (auth *OAuth2ClientCredentials) Authenticate(req *http.Request) error {
+	auth.mu.Lock()
+	defer auth.mu.Unlock()
+	expiryDelta := auth.ExpiryDelta
+	if expiryDelta == 0 {
+		expiryDelta = 10 * time.Second
+	}
+	now := clockFromContext(req.Context()).Now()
+	if auth.token == "" || (!auth.expiry.IsZero() && now.Add(expiryDelta).After(auth.expiry)) {
+		if err := auth.fetchToken(req); err != nil {
+			return err
+		}
+	}
+	req.Header.Set("Authorization", "Bearer "+auth.token)
+	return nil
+}
+
+// Invalidate discards the cached token.
+func (auth *OAuth2ClientCredentials) Invalidate() {
+	auth.mu.Lock()
+	defer auth.mu.Unlock()
+	auth.token = ""
+	auth.expiry = time.Time{}
+}
+
+// fetchToken requests a new token from the token endpoint. auth.mu must be held.
+func (auth *OAuth2ClientCredentials) fetchToken(req *http.Request) error {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(auth.Scopes) > 0 {
+		form.Set("scope", strings.Join(auth.Scopes, " "))
+	}
+	for key, values := range auth.EndpointParams {
+		form[key] = values
+	}
+	tokenReq, err := http.NewRequestWithContext(req.Context(), http.MethodPost, auth.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	tokenReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	tokenReq.Header.Set("Accept", "application/json")
+	tokenReq.SetBasicAuth(url.QueryEscape(auth.ClientID), url.QueryEscape(auth.ClientSecret))
+	httpClient := auth.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	res, err := httpClient.Do(tokenReq)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("oauth2 token request failed: %w", newHTTPError(ResponseData{
+			Status:     res.Status,
+			StatusCode: res.StatusCode,
+			Headers:    res.Header,
+			Body:       body,
+		}))
+	}
+	var token oauth2Token
+	if err := json.Unmarshal(body, &token); err != nil {
+		return err
+	}
+	if token.AccessToken == "" {
+		return fmt.Errorf("oauth2 token response missing access_token")
+	}
+	auth.token = token.AccessToken
+	auth.expiry = time.Time{}
+	if token.ExpiresIn > 0 {
+		auth.expiry = clockFromContext(req.Context()).Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return nil
+}
+
+// SetAuthenticator sets the Authenticator used by QuickRequest.
+//
+// Parameters:
+//   - authenticator (Authenticator): The authenticator to use. Use nil for no authentication.
+func (client *Client) SetAuthenticator(authenticator Authenticator) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.authenticator = authenticator
+}
+
+// GetAuthenticator returns the clients Authenticator
+//
+// Returns:
+//   - Authenticator: The client.authenticator value
+func (client *Client) GetAuthenticator() Authenticator {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return client.authenticator
+}
+
+// SetAuthenticator sets the Authenticator of each client in the pool.
+//
+// Parameters:
+//   - authenticator (Authenticator): The authenticator to use. Use nil for no authentication.
+func (pool *ClientPool) SetAuthenticator(authenticator Authenticator) {
+	for _, client := range pool.getClients() {
+		client.SetAuthenticator(authenticator)
+	}
+}
+
+// rewindRequest returns a copy of req with a fresh body so it can be sent again.
+//
+// Returns false if the body cannot be rewound.
+func rewindRequest(req *http.Request) (*http.Request, bool) {
+	retry := req.Clone(req.Context())
+	if req.Body == nil || req.Body == http.NoBody {
+		return retry, true
+	}
+	if req.GetBody == nil {
+		return nil, false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, false
+	}
+	retry.Body = body
+	return retry, true
+}
@@ -0,0 +1,138 @@
+package HttpClientPool
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStaticAuthenticators(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization") + r.Header.Get("X-Api-Key") + r.URL.Query().Get("key")))
+	}))
+	defer server.Close()
+	client := NewClient(nil, "HttpClient", 0)
+	tests := map[Authenticator]string{
+		BasicAuth{"user", "pass"}:                          "Basic dXNlcjpwYXNz",
+		BearerToken("token"):                               "Bearer token",
+		APIKey{Name: "X-Api-Key", Value: "header"}:         "header",
+		APIKey{Name: "key", Value: "query", InQuery: true}: "query",
+	}
+	for authenticator, expected := range tests {
+		client.SetAuthenticator(authenticator)
+		response, err := client.QuickRequest(RequestData{Type: "GET", Url: server.URL})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(response.Body) != expected {
+			t.Errorf("Expected %q got %q", expected, response.Body)
+		}
+	}
+}
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+	var tokensIssued atomic.Int32
+	var revoked atomic.Bool
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		r.ParseForm()
+		if id != "id" || secret != "secret" || r.Form.Get("grant_type") != "client_credentials" || r.Form.Get("scope") != "read write" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":3600}`, tokensIssued.Add(1))
+	}))
+	defer tokenServer.Close()
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "token-1" && revoked.Load() {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(token))
+	}))
+	defer apiServer.Close()
+
+	pool := NewClientPool(0, 0, nil, nil)
+	pool.SetAuthenticator(&OAuth2ClientCredentials{
+		TokenURL:     tokenServer.URL,
+		ClientID:     "id",
+		ClientSecret: "secret",
+		Scopes:       []string{"read", "write"},
+	})
+	// Token is cached between requests
+	for i := 0; i < 3; i++ {
+		response, err := pool.QuickRequest(RequestData{Type: "POST", Url: apiServer.URL, JsonData: i})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(response.Body) != "token-1" {
+			t.Errorf("Unexpected token %s", response.Body)
+		}
+	}
+	// Rejected token is refreshed once
+	revoked.Store(true)
+	response, err := pool.QuickRequest(RequestData{Type: "POST", Url: apiServer.URL, JsonData: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != http.StatusOK || string(response.Body) != "token-2" {
+		t.Errorf("Unexpected response %s %s", response.Status, response.Body)
+	}
+	if issued := tokensIssued.Load(); issued != 2 {
+		t.Errorf("Expected 2 tokens issued got %d", issued)
+	}
+	// Tokens expire by the pool clock
+	clock := NewFakeClock(time.Now())
+	pool.SetClock(clock)
+	pool.SetAuthenticator(&OAuth2ClientCredentials{TokenURL: tokenServer.URL, ClientID: "id", ClientSecret: "secret", Scopes: []string{"read", "write"}})
+	for _, expected := range []string{"token-3", "token-3", "token-4"} {
+		response, err := pool.QuickRequest(RequestData{Type: "GET", Url: apiServer.URL})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(response.Body) != expected {
+			t.Errorf("Expected %s got %s", expected, response.Body)
+		}
+		clock.Advance(30 * time.Minute)
+	}
+}
+
+// readCloser records whether a request body was closed
+type readCloser struct {
+	io.Reader
+	closed atomic.Bool
+}
+
+func (body *readCloser) Close() error {
+	body.closed.Store(true)
+	return nil
+}
+
+type failingAuthenticator struct{}
+
+func (failingAuthenticator) Authenticate(req *http.Request) error {
+	return errors.New("no credentials")
+}
+
+// The request body is released when authentication fails before sending
+func TestAuthenticatorErrorClosesBody(t *testing.T) {
+	client := NewClient(nil, "HttpClient", 0)
+	client.SetAuthenticator(failingAuthenticator{})
+	body := &readCloser{Reader: strings.NewReader("data")}
+	var reader io.Reader = body
+	_, err := client.QuickRequest(RequestData{Type: "POST", Url: "http://127.0.0.1/", RawData: &reader})
+	if err == nil || err.Error() != "no credentials" {
+		t.Fatalf("Expected the authenticator error, got %v", err)
+	}
+	if !body.closed.Load() {
+		t.Error("Request body not closed")
+	}
+}
@@ -0,0 +1,244 @@
+package HttpClientPool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// ErrBanned is returned by ClientPool.QuickRequest when a response is detected
+// as a ban and no retries remain.
+var ErrBanned = errors.New("client banned")
+
+// BanDetector reports whether a response indicates the Client has been banned,
+// for example a captcha page served with 200 OK.
+type BanDetector interface {
+	IsBan(response ResponseData) bool
+}
+
+// BanDetectorFunc is an adapter allowing a function to be used as a BanDetector.
+type BanDetectorFunc func(response ResponseData) bool
+
+// IsBan calls f(response).
+func (f BanDetectorFunc) IsBan(response ResponseData) bool {
+	return f(response)
+}
+
+// BanOnStatus detects responses with any of the given status codes as bans.
+//
+// Parameters:
+//   - codes (...int): The status codes to match (e.g., 403, 429).
+//
+// Returns:
+//   - BanDetector: The detector.
+func BanOnStatus(codes ...int) BanDetector {
+	return BanDetectorFunc(func(response ResponseData) bool {
+		for _, code := range codes {
+			if response.StatusCode == code {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// BanOnBody detects responses with a body matching a pattern as bans.
+//
+// Parameters:
+//   - pattern (*regexp.Regexp): The pattern to match (e.g., `(?i)captcha`).
+//
+// Returns:
+//   - BanDetector: The detector.
+func BanOnBody(pattern *regexp.Regexp) BanDetector {
+	return BanDetectorFunc(func(response ResponseData) bool {
+		return pattern.Match(response.Body)
+	})
+}
+
+// BanOnHeader detects responses with a header present as bans.
+//
+// Parameters:
+//   - name (string): The header name (e.g., cf-mitigated).
+//   - pattern (*regexp.Regexp): The pattern a header value must match. Use nil to match any value.
+//
+// Returns:
+//   - BanDetector: The detector.
+func BanOnHeader(name string, pattern *regexp.Regexp) BanDetector {
+	return BanDetectorFunc(func(response ResponseData) bool {
+		values, exists := http.Header(response.Headers)[http.CanonicalHeaderKey(name)]
+		if !exists {
+			return false
+		}
+		if pattern == nil {
+			return true
+		}
+		for _, value := range values {
+			if pattern.MatchString(value) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// BanOnRedirect detects responses redirected to a URL matching a pattern as bans.
+//
+// Parameters:
+//   - pattern (*regexp.Regexp): The pattern the final URL must match (e.g., `/captcha`).
+//
+// Returns:
+//   - BanDetector: The detector.
+func BanOnRedirect(pattern *regexp.Regexp) BanDetector {
+	return BanDetectorFunc(func(response ResponseData) bool {
+		return pattern.MatchString(response.Url)
+	})
+}
+
+// BanPolicy configures ban detection for a ClientPool.
+type BanPolicy struct {
+	// Detectors are checked in order. A match from any detector is a ban.
+	Detectors []BanDetector
+
+	// Cooldown is how long a banned Client is unavailable.
+	Cooldown time.Duration
+
+	// Retries is the number of times a banned request is retried on another Client.
+	// Clients which banned the request are not used for its retries. Requests with
+	// a body which cannot be rewound are not retried.
+	Retries int
+
+	// RetireAfter removes a Client from the pool once it has been banned this many
+	// times. Use 0 to never remove clients. Once every client is retired requests
+	// fail with ErrNoClients.
+	RetireAfter int
+}
+
+// isBan returns true if any detector matches the response.
+func (policy BanPolicy) isBan(response ResponseData) bool {
+	for _, detector := range policy.Detectors {
+		if detector.IsBan(response) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetBanPolicy sets the ban detection used by the pool's QuickRequest.
+//
+// Parameters:
+//   - policy (BanPolicy): The ban policy. Use an empty BanPolicy to disable ban detection.
+func (pool *ClientPool) SetBanPolicy(policy BanPolicy) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	pool.banPolicy = policy
+}
+
+// GetBanCounts returns the number of bans recorded for each proxy.
+//
+// Counts include clients which have since been retired. Clients without a proxy
+// are counted under the empty string.
+//
+// Returns:
+//   - map[string]int: Map of proxy URL to ban count.
+func (pool *ClientPool) GetBanCounts() map[string]int {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	counts := make(map[string]int, len(pool.banCounts))
+	for proxy, count := range pool.banCounts {
+		counts[proxy] = count
+	}
+	return counts
+}
+
+// banClient bans a client according to the policy, retiring it if required.
+func (pool *ClientPool) banClient(client *Client, policy BanPolicy) {
+	banCount := client.Ban(policy.Cooldown)
+	var proxy string
+	if proxyUrl := client.GetProxy(); proxyUrl != nil {
+		proxy = proxyUrl.String()
+	}
+	pool.mu.Lock()
+	if pool.banCounts == nil {
+		pool.banCounts = make(map[string]int)
+	}
+	pool.banCounts[proxy]++
+	pool.mu.Unlock()
+	if policy.RetireAfter > 0 && banCount >= policy.RetireAfter {
+		pool.RemmoveClient(client)
+	}
+}
+
+// banCheck runs a request with pool.attempt applying the ban policy.
+//
+// Parameters:
+//   - ctx (context.Context): The context for the request.
+//   - reqData (RequestData): The RequestData struct containing HTTP request data.
+//   - policy (BanPolicy): The ban policy to apply.
+//
+// Returns:
+//   - ResponseData: The last response received.
+//   - error: An error, if any, encountered during the request. Wraps ErrBanned if
+//     the last response was a ban.
+func (pool *ClientPool) banCheck(ctx context.Context, reqData RequestData, policy BanPolicy) (ResponseData, error) {
+	rewind := reqData.bodyRewinder()
+	var banned []*Client
+	var bannedResponse ResponseData
+	for retry := 0; ; retry++ {
+		client, response, err := pool.attempt(ctx, reqData, banned)
+		if errors.Is(err, ErrNoClients) && len(banned) > 0 {
+			// Every remaining client has banned the request
+			return bannedResponse, fmt.Errorf("%w: %s", ErrBanned, bannedResponse.Status)
+		}
+		isBan := err == nil && policy.isBan(response)
+		pool.adapt(client, response, err, isBan)
+		if !isBan {
+			return response, err
+		}
+		pool.banClient(client, policy)
+		banned, bannedResponse = append(banned, client), response
+		if retry >= policy.Retries || rewind == nil {
+			return response, fmt.Errorf("%w: %s", ErrBanned, response.Status)
+		}
+		if err := rewind(); err != nil {
+			return response, err
+		}
+	}
+}
+
+// Ban marks the client as unavailable for a cool-down and increments its ban count.
+//
+// Parameters:
+//   - cooldown (time.Duration): How long the client is unavailable.
+//
+// Returns:
+//   - int: The number of times the client has been banned.
+func (client *Client) Ban(cooldown time.Duration) int {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.bannedUntil = client.clock.Now().Add(cooldown)
+	client.banCount++
+	return client.banCount
+}
+
+// IsBanned returns true if the client is within a ban cool-down.
+//
+// Returns:
+//   - bool: True if the client is banned; otherwise, false.
+func (client *Client) IsBanned() bool {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return client.bannedUntil.After(client.clock.Now())
+}
+
+// GetBanCount returns the number of times the client has been banned
+//
+// Returns:
+//   - int: the client.banCount value
+func (client *Client) GetBanCount() int {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return client.banCount
+}
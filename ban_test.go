@@ -0,0 +1,127 @@
+package HttpClientPool
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBanDetection(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.UserAgent(), "Banned") {
+			w.Write([]byte("<html>Please solve the CAPTCHA</html>"))
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	pool := NewClientPool(0, 0, nil, map[string]float32{"Banned 1": 1})
+	pool.AddClient(NewClient(nil, "Banned 2", 0))
+	pool.AddClient(NewClient(nil, "Good", 0))
+	pool.SetBanPolicy(BanPolicy{
+		Detectors:   []BanDetector{BanOnBody(regexp.MustCompile(`(?i)captcha`))},
+		Cooldown:    time.Hour,
+		Retries:     2,
+		RetireAfter: 1,
+	})
+	// Banned clients are retried and retired
+	response, err := pool.QuickRequest(RequestData{Type: "POST", Url: server.URL, JsonData: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(response.Body) != "ok" || response.Client.GetUserAgent() != "Good" {
+		t.Errorf("Unexpected response %s from %s", response.Body, response.Client.GetUserAgent())
+	}
+	if len(pool.Clients) != 1 {
+		t.Errorf("Incorrect pool size. Expected 1 got %d", len(pool.Clients))
+	}
+	if counts := pool.GetBanCounts(); counts[""] != 2 {
+		t.Errorf("Unexpected ban counts %v", counts)
+	}
+	// Banned client is unavailable during the cool-down
+	client := NewClient(nil, "Banned 3", 0)
+	pool.AddClient(client)
+	pool.SetBanPolicy(BanPolicy{
+		Detectors: []BanDetector{BanOnStatus(http.StatusOK)},
+		Cooldown:  time.Hour,
+	})
+	_, err = pool.QuickRequest(RequestData{Type: "GET", Url: server.URL})
+	if !errors.Is(err, ErrBanned) {
+		t.Errorf("Expected ErrBanned got %v", err)
+	}
+	if !pool.Clients[0].IsBanned() || pool.Clients[0].IsAvailable() || pool.Clients[0].GetBanCount() != 1 {
+		t.Error("Client should be banned")
+	}
+}
+
+// Retries skip clients which banned the request and an emptied pool fails fast
+func TestBanRetryExclusion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.UserAgent(), "Banned") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	pool := NewClientPool(0, 0, nil, map[string]float32{"Banned": 1})
+	pool.AddClient(NewClient(nil, "Good", 0))
+	// Without a cool-down the banned client is available again straight away
+	pool.SetBanPolicy(BanPolicy{
+		Detectors: []BanDetector{BanOnStatus(http.StatusForbidden)},
+		Retries:   1,
+	})
+	response, err := pool.QuickRequest(RequestData{Type: "GET", Url: server.URL})
+	if err != nil || response.Client.GetUserAgent() != "Good" {
+		t.Fatalf("Expected the retry on the good client, got %v", err)
+	}
+	// Every client banning the request is a ban, not a missing client
+	pool.RemmoveClient(response.Client)
+	pool.AddClient(NewClient(nil, "Banned 2", 0))
+	pool.SetBanPolicy(BanPolicy{
+		Detectors: []BanDetector{BanOnStatus(http.StatusForbidden)},
+		Retries:   5,
+	})
+	if _, err := pool.QuickRequest(RequestData{Type: "GET", Url: server.URL}); !errors.Is(err, ErrBanned) {
+		t.Fatalf("Expected ErrBanned got %v", err)
+	}
+	// Retiring every client empties the pool
+	pool.SetBanPolicy(BanPolicy{
+		Detectors:   []BanDetector{BanOnStatus(http.StatusForbidden)},
+		Retries:     5,
+		RetireAfter: 1,
+	})
+	if _, err := pool.QuickRequest(RequestData{Type: "GET", Url: server.URL}); !errors.Is(err, ErrBanned) {
+		t.Fatalf("Expected ErrBanned got %v", err)
+	}
+	if _, err := pool.QuickRequest(RequestData{Type: "GET", Url: server.URL}); !errors.Is(err, ErrNoClients) {
+		t.Fatalf("Expected ErrNoClients got %v", err)
+	}
+	if client := pool.GetClient(); client != nil {
+		t.Fatal("Expected no client from an empty pool")
+	}
+}
+
+func TestBanDetectors(t *testing.T) {
+	response := ResponseData{
+		StatusCode: http.StatusOK,
+		Headers:    map[string][]string{"Cf-Mitigated": {"challenge"}},
+		Url:        "https://example.com/captcha?return=/",
+	}
+	if !BanOnHeader("cf-mitigated", nil).IsBan(response) {
+		t.Error("Expected header ban")
+	}
+	if BanOnHeader("cf-mitigated", regexp.MustCompile("block")).IsBan(response) {
+		t.Error("Unexpected header ban")
+	}
+	if !BanOnRedirect(regexp.MustCompile(`/captcha`)).IsBan(response) {
+		t.Error("Expected redirect ban")
+	}
+	if BanOnStatus(http.StatusForbidden).IsBan(response) {
+		t.Error("Unexpected status ban")
+	}
+}
@@ -0,0 +1,189 @@
+package HttpClientPool
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BatchOptions configures ClientPool.Batch.
+type BatchOptions struct {
+	// Workers is the number of requests run concurrently. Defaults to the
+	// number of clients in the pool.
+	Workers int
+
+	// Ordered returns results in input order. Results completing out of order
+	// are held until the earlier results are returned.
+	Ordered bool
+
+	// Window is the maximum number of requests started but not yet returned.
+	// It bounds the results held when Ordered is set. Defaults to 4 * Workers.
+	Window int
+
+	// StopOnError stops reading new requests after the first request error.
+	// Requests already started are completed and returned.
+	StopOnError bool
+
+	// Progress is called after each result is returned. It must not block.
+	Progress func(progress BatchProgress)
+}
+
+// BatchResult is the result of one request run by ClientPool.Batch.
+type BatchResult struct {
+	// Index is the position of the request in the input, starting at 0.
+	Index int
+	// Request is the request which was run.
+	Request RequestData
+	// Response is the response received.
+	Response ResponseData
+	// Err is the request error, if any.
+	Err error
+	// Duration is the time taken by the request including waiting for a Client.
+	Duration time.Duration
+}
+
+// BatchProgress reports the progress of ClientPool.Batch.
+type BatchProgress struct {
+	// Started is the number of requests read from the input.
+	Started int
+	// Completed is the number of results returned.
+	Completed int
+	// Failed is the number of results returned with an error.
+	Failed int
+	// Elapsed is the time since the batch started.
+	Elapsed time.Duration
+}
+
+// batchJob is a request read from the input of ClientPool.Batch.
+type batchJob struct {
+	index   int
+	request RequestData
+}
+
+// Batch runs requests from a channel through the pool with a bounded number of workers.
+//
+// Requests are read from the channel as workers become free, so inputs larger
+// than memory can be streamed. The returned channel is closed once the input
+// channel is closed and every started request has been returned. Results are
+// discarded once ctx ends, otherwise the channel must be drained until closed.
+//
+// Parameters:
+//   - ctx (context.Context): The context for the requests. Ending it stops the batch.
+//   - requests (<-chan RequestData): The requests to run. Close it to finish the batch.
+//   - opts (BatchOptions): The batch options.
+//
+// Returns:
+//   - <-chan BatchResult: The results of the requests.
+func (pool *ClientPool) Batch(ctx context.Context, requests <-chan RequestData, opts BatchOptions) <-chan BatchResult {
+	if opts.Workers <= 0 {
+		opts.Workers = len(pool.getClients())
+	}
+	if opts.Window <= 0 {
+		opts.Window = 4 * opts.Workers
+	} else if opts.Window < opts.Workers {
+		opts.Window = opts.Workers
+	}
+	// stop ends reading the input without cancelling started requests
+	stop, stopInput := context.WithCancel(ctx)
+	jobs := make(chan batchJob)
+	completed := make(chan BatchResult, opts.Workers)
+	results := make(chan BatchResult)
+	// slots bounds the requests started but not yet returned
+	slots := make(chan struct{}, opts.Window)
+	progress := BatchProgress{}
+	var progressMu sync.Mutex
+	start := time.Now()
+	// Read the input
+	go func() {
+		defer close(jobs)
+		for index := 0; ; index++ {
+			select {
+			case slots <- struct{}{}:
+			case <-stop.Done():
+				return
+			}
+			var request RequestData
+			var ok bool
+			select {
+			case request, ok = <-requests:
+			case <-stop.Done():
+				return
+			}
+			if !ok {
+				return
+			}
+			progressMu.Lock()
+			progress.Started++
+			progressMu.Unlock()
+			jobs <- batchJob{index: index, request: request}
+		}
+	}()
+	// Run the requests
+	var workers sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for job := range jobs {
+				started := time.Now()
+				response, err := pool.QuickRequestContext(ctx, job.request)
+				completed <- BatchResult{
+					Index:    job.index,
+					Request:  job.request,
+					Response: response,
+					Err:      err,
+					Duration: time.Since(started),
+				}
+			}
+		}()
+	}
+	go func() {
+		workers.Wait()
+		close(completed)
+	}()
+	// Return the results
+	go func() {
+		defer close(results)
+		defer stopInput()
+		emit := func(result BatchResult) {
+			select {
+			case results <- result:
+			case <-ctx.Done():
+			}
+			<-slots
+			progressMu.Lock()
+			progress.Completed++
+			if result.Err != nil {
+				progress.Failed++
+			}
+			progress.Elapsed = time.Since(start)
+			current := progress
+			progressMu.Unlock()
+			if opts.Progress != nil {
+				opts.Progress(current)
+			}
+		}
+		pending := make(map[int]BatchResult)
+		next := 0
+		for result := range completed {
+			if result.Err != nil && opts.StopOnError {
+				stopInput()
+			}
+			if !opts.Ordered {
+				emit(result)
+				continue
+			}
+			pending[result.Index] = result
+			for {
+				result, exists := pending[next]
+				if !exists {
+					break
+				}
+				delete(pending, next)
+				emit(result)
+				next++
+			}
+		}
+	}()
+	return results
+}
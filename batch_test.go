@@ -0,0 +1,104 @@
+package HttpClientPool
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Complete requests out of order
+		time.Sleep(time.Duration(rand.Intn(5)) * time.Millisecond)
+		w.Write([]byte(r.URL.Query().Get("n")))
+	}))
+	defer server.Close()
+	pool := NewClientPool(0, 0, nil, nil)
+	pool.SetClientMaxInFlight(0)
+	const requests = 50
+	feed := func() <-chan RequestData {
+		input := make(chan RequestData)
+		go func() {
+			defer close(input)
+			for i := 0; i < requests; i++ {
+				input <- RequestData{Type: "GET", Url: server.URL, Params: map[string][]string{"n": {strconv.Itoa(i)}}}
+			}
+		}()
+		return input
+	}
+	// Ordered results
+	var lastProgress BatchProgress
+	next := 0
+	for result := range pool.Batch(context.Background(), feed(), BatchOptions{
+		Workers:  8,
+		Ordered:  true,
+		Window:   8,
+		Progress: func(progress BatchProgress) { lastProgress = progress },
+	}) {
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+		if result.Index != next || string(result.Response.Body) != strconv.Itoa(next) {
+			t.Fatalf("Expected result %d, got index %d body %q", next, result.Index, result.Response.Body)
+		}
+		next++
+	}
+	if next != requests {
+		t.Fatalf("Expected %d results, got %d", requests, next)
+	}
+	if lastProgress.Started != requests || lastProgress.Completed != requests || lastProgress.Failed != 0 {
+		t.Fatalf("Unexpected progress %+v", lastProgress)
+	}
+	// Unordered results
+	seen := make(map[int]bool)
+	for result := range pool.Batch(context.Background(), feed(), BatchOptions{Workers: 8}) {
+		if string(result.Response.Body) != strconv.Itoa(result.Index) {
+			t.Fatalf("Result %d has body %q", result.Index, result.Response.Body)
+		}
+		seen[result.Index] = true
+	}
+	if len(seen) != requests {
+		t.Fatalf("Expected %d results, got %d", requests, len(seen))
+	}
+}
+
+func TestBatchStopOnError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	pool := NewClientPool(0, 0, nil, nil)
+	var read atomic.Int32
+	input := make(chan RequestData)
+	go func() {
+		defer close(input)
+		for i := 0; i < 100; i++ {
+			reqData := RequestData{Type: "GET", Url: server.URL}
+			if i == 3 {
+				// Fails validation
+				reqData.Type = "BOGUS"
+			}
+			select {
+			case input <- reqData:
+				read.Add(1)
+			case <-time.After(100 * time.Millisecond):
+				return
+			}
+		}
+	}()
+	var failed int
+	for result := range pool.Batch(context.Background(), input, BatchOptions{Workers: 1, Window: 1, StopOnError: true}) {
+		if result.Err != nil {
+			failed++
+		}
+	}
+	if failed != 1 {
+		t.Fatalf("Expected 1 failed result, got %d", failed)
+	}
+	if read.Load() > 5 {
+		t.Fatalf("Expected the batch to stop reading after the error, read %d", read.Load())
+	}
+}
@@ -0,0 +1,297 @@
+package HttpClientPool
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownContentType is returned when no BodyEncoder or BodyDecoder is
+// registered for a content type.
+var ErrUnknownContentType = errors.New("no body codec registered for content type")
+
+// BodyEncoder encodes a value into a request body.
+type BodyEncoder interface {
+	// Encode returns an io.Reader containing the encoded value.
+	Encode(v interface{}) (io.Reader, error)
+}
+
+// BodyEncoderFunc is an adapter allowing a function to be used as a BodyEncoder.
+type BodyEncoderFunc func(v interface{}) (io.Reader, error)
+
+// Encode calls f(v).
+func (f BodyEncoderFunc) Encode(v interface{}) (io.Reader, error) {
+	return f(v)
+}
+
+// BodyDecoder decodes a response body into a value.
+type BodyDecoder interface {
+	// Decode decodes body into the value pointed to by v.
+	Decode(body []byte, v interface{}) error
+}
+
+// BodyDecoderFunc is an adapter allowing a function to be used as a BodyDecoder.
+type BodyDecoderFunc func(body []byte, v interface{}) error
+
+// Decode calls f(body, v).
+func (f BodyDecoderFunc) Decode(body []byte, v interface{}) error {
+	return f(body, v)
+}
+
+var (
+	codecMu      sync.RWMutex
+	bodyEncoders = map[string]BodyEncoder{
+		"application/json":                  BodyEncoderFunc(jsonDataReader),
+		"application/x-www-form-urlencoded": BodyEncoderFunc(encodeUrlencoded),
+		"application/xml":                   BodyEncoderFunc(encodeXml),
+		"text/xml":                          BodyEncoderFunc(encodeXml),
+		"application/x-ndjson":              BodyEncoderFunc(encodeNdjson),
+		"application/octet-stream":          BodyEncoderFunc(encodeRaw),
+		"text/plain":                        BodyEncoderFunc(encodeRaw),
+	}
+	bodyDecoders = map[string]BodyDecoder{
+		"application/json":                  BodyDecoderFunc(json.Unmarshal),
+		"application/x-www-form-urlencoded": BodyDecoderFunc(decodeUrlencoded),
+		"application/xml":                   BodyDecoderFunc(xml.Unmarshal),
+		"text/xml":                          BodyDecoderFunc(xml.Unmarshal),
+		"application/x-ndjson":              BodyDecoderFunc(decodeNdjson),
+		"application/octet-stream":          BodyDecoderFunc(decodeRaw),
+		"text/plain":                        BodyDecoderFunc(decodeRaw),
+	}
+)
+
+// RegisterBodyEncoder registers a BodyEncoder for a content type, replacing any existing encoder.
+//
+// Parameters:
+//   - contentType (string): The media type handled by the encoder (e.g., application/msgpack).
+//   - encoder (BodyEncoder): The encoder to register.
+func RegisterBodyEncoder(contentType string, encoder BodyEncoder) {
+	codecMu.Lock()
+	defer codecMu.Unlock()
+	bodyEncoders[mediaType(contentType)] = encoder
+}
+
+// RegisterBodyDecoder registers a BodyDecoder for a content type, replacing any existing decoder.
+//
+// Parameters:
+//   - contentType (string): The media type handled by the decoder (e.g., application/msgpack).
+//   - decoder (BodyDecoder): The decoder to register.
+func RegisterBodyDecoder(contentType string, decoder BodyDecoder) {
+	codecMu.Lock()
+	defer codecMu.Unlock()
+	bodyDecoders[mediaType(contentType)] = decoder
+}
+
+// lookupBodyEncoder returns the BodyEncoder registered for a content type.
+//
+// Structured syntax suffixes such as application/problem+json fall back to the
+// encoder of the base format.
+func lookupBodyEncoder(contentType string) (BodyEncoder, error) {
+	codecMu.RLock()
+	defer codecMu.RUnlock()
+	for _, key := range mediaTypeKeys(contentType) {
+		if encoder, exists := bodyEncoders[key]; exists {
+			return encoder, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownContentType, contentType)
+}
+
+// lookupBodyDecoder returns the BodyDecoder registered for a content type.
+//
+// Structured syntax suffixes such as application/problem+json fall back to the
+// decoder of the base format.
+func lookupBodyDecoder(contentType string) (BodyDecoder, error) {
+	codecMu.RLock()
+	defer codecMu.RUnlock()
+	for _, key := range mediaTypeKeys(contentType) {
+		if decoder, exists := bodyDecoders[key]; exists {
+			return decoder, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownContentType, contentType)
+}
+
+// mediaType strips parameters from a content type and lowercases it.
+func mediaType(contentType string) string {
+	parsed, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return parsed
+}
+
+// mediaTypeKeys returns the registry keys to try for a content type in order.
+func mediaTypeKeys(contentType string) []string {
+	parsed := mediaType(contentType)
+	keys := []string{parsed}
+	if idx := strings.LastIndex(parsed, "+"); idx != -1 {
+		keys = append(keys, "application/"+parsed[idx+1:])
+	}
+	return keys
+}
+
+// Decode decodes the response body into the value pointed to by v.
+//
+// The BodyDecoder is chosen by the Content-Type header of the response.
+//
+// Parameters:
+//   - v (interface{}): A pointer to the value to decode into.
+//
+// Returns:
+//   - error: An error, if any, encountered while decoding.
+func (response ResponseData) Decode(v interface{}) error {
+	var contentType string
+	for key, values := range response.Headers {
+		if strings.EqualFold(key, "Content-Type") && len(values) > 0 {
+			contentType = values[0]
+		}
+	}
+	decoder, err := lookupBodyDecoder(contentType)
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(response.Body, v)
+}
+
+// encodeUrlencoded encodes url.Values, map[string][]string, map[string]string
+// or a pre-encoded string as an application/x-www-form-urlencoded body.
+func encodeUrlencoded(v interface{}) (io.Reader, error) {
+	switch data := v.(type) {
+	case url.Values:
+		return strings.NewReader(data.Encode()), nil
+	case map[string][]string:
+		return strings.NewReader(url.Values(data).Encode()), nil
+	case map[string]string:
+		values := make(url.Values, len(data))
+		for key, value := range data {
+			values.Set(key, value)
+		}
+		return strings.NewReader(values.Encode()), nil
+	case string:
+		return strings.NewReader(data), nil
+	}
+	return nil, fmt.Errorf("cannot urlencode %T", v)
+}
+
+// decodeUrlencoded decodes an application/x-www-form-urlencoded body into
+// a *url.Values or *map[string][]string.
+func decodeUrlencoded(body []byte, v interface{}) error {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return err
+	}
+	switch target := v.(type) {
+	case *url.Values:
+		*target = values
+	case *map[string][]string:
+		*target = values
+	default:
+		return fmt.Errorf("cannot urldecode into %T", v)
+	}
+	return nil
+}
+
+// encodeXml encodes a value as XML using encoding/xml.
+func encodeXml(v interface{}) (io.Reader, error) {
+	xmlData, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(xmlData), nil
+}
+
+// encodeNdjson encodes each element of a slice, array or channel as one line of JSON.
+//
+// Channels are streamed through an io.Pipe until they are closed.
+func encodeNdjson(v interface{}) (io.Reader, error) {
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		var buffer bytes.Buffer
+		encoder := json.NewEncoder(&buffer)
+		for i := 0; i < value.Len(); i++ {
+			if err := encoder.Encode(value.Index(i).Interface()); err != nil {
+				return nil, err
+			}
+		}
+		return &buffer, nil
+	case reflect.Chan:
+		pipeReader, pipeWriter := io.Pipe()
+		go func() {
+			encoder := json.NewEncoder(pipeWriter)
+			for {
+				item, ok := value.Recv()
+				if !ok {
+					break
+				}
+				if err := encoder.Encode(item.Interface()); err != nil {
+					pipeWriter.CloseWithError(err)
+					return
+				}
+			}
+			pipeWriter.Close()
+		}()
+		return pipeReader, nil
+	}
+	return nil, fmt.Errorf("cannot encode %T as NDJSON", v)
+}
+
+// decodeNdjson decodes each line of JSON into a new element of the slice pointed to by v.
+func decodeNdjson(body []byte, v interface{}) error {
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Pointer || target.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("cannot decode NDJSON into %T", v)
+	}
+	slice := target.Elem()
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(nil, len(body)+1)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		item := reflect.New(slice.Type().Elem())
+		if err := json.Unmarshal(line, item.Interface()); err != nil {
+			return err
+		}
+		slice = reflect.Append(slice, item.Elem())
+	}
+	target.Elem().Set(slice)
+	return scanner.Err()
+}
+
+// encodeRaw passes through []byte, string and io.Reader values unchanged.
+func encodeRaw(v interface{}) (io.Reader, error) {
+	switch data := v.(type) {
+	case []byte:
+		return bytes.NewReader(data), nil
+	case string:
+		return strings.NewReader(data), nil
+	case io.Reader:
+		return data, nil
+	}
+	return nil, fmt.Errorf("cannot use %T as a raw body", v)
+}
+
+// decodeRaw copies the body into a *[]byte or *string.
+func decodeRaw(body []byte, v interface{}) error {
+	switch target := v.(type) {
+	case *[]byte:
+		*target = append([]byte(nil), body...)
+	case *string:
+		*target = string(body)
+	default:
+		return fmt.Errorf("cannot decode raw body into %T", v)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package HttpClientPool
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+type xmlItem struct {
+	XMLName xml.Name `xml:"item"`
+	Name    string   `xml:"name"`
+	Count   int      `xml:"count"`
+}
+
+// Sends each built-in body type to a server which echoes the body back with the same content type
+func TestBodyEncoders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		io.Copy(w, r.Body)
+	}))
+	defer server.Close()
+	client := NewClient(nil, "HttpClient", 0)
+
+	// XML
+	response, err := client.QuickRequest(RequestData{
+		Type: "POST", Url: server.URL,
+		Data:        xmlItem{Name: "widget", Count: 3},
+		ContentType: "application/xml; charset=utf-8",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var item xmlItem
+	if err := response.Decode(&item); err != nil {
+		t.Fatal(err)
+	}
+	if item.Name != "widget" || item.Count != 3 {
+		t.Errorf("Unexpected XML item %+v", item)
+	}
+
+	// Urlencoded
+	response, err = client.QuickRequest(RequestData{
+		Type: "POST", Url: server.URL,
+		Data:        map[string]string{"a": "1", "b": "two words"},
+		ContentType: "application/x-www-form-urlencoded",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(response.Body) != "a=1&b=two+words" {
+		t.Errorf("Unexpected urlencoded body %s", response.Body)
+	}
+	var values url.Values
+	if err := response.Decode(&values); err != nil {
+		t.Fatal(err)
+	}
+	if values.Get("b") != "two words" {
+		t.Errorf("Unexpected urlencoded values %v", values)
+	}
+
+	// NDJSON from a channel
+	items := make(chan map[string]int)
+	go func() {
+		for i := 0; i < 3; i++ {
+			items <- map[string]int{"n": i}
+		}
+		close(items)
+	}()
+	response, err = client.QuickRequest(RequestData{
+		Type: "POST", Url: server.URL,
+		Data:        items,
+		ContentType: "application/x-ndjson",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []map[string]int
+	if err := response.Decode(&lines); err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 3 || lines[2]["n"] != 2 {
+		t.Errorf("Unexpected NDJSON lines %v", lines)
+	}
+
+	// Structured suffix falls back to JSON
+	response, err = client.QuickRequest(RequestData{
+		Type: "POST", Url: server.URL,
+		Data:        map[string]string{"title": "Not Found"},
+		ContentType: "application/problem+json",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var problem map[string]string
+	if err := response.Decode(&problem); err != nil {
+		t.Fatal(err)
+	}
+	if problem["title"] != "Not Found" {
+		t.Errorf("Unexpected problem %v", problem)
+	}
+
+	// Unknown content type
+	_, err = client.QuickRequest(RequestData{
+		Type: "POST", Url: server.URL,
+		Data:        struct{}{},
+		ContentType: "application/unknown",
+	})
+	if !errors.Is(err, ErrUnknownContentType) {
+		t.Errorf("Expected ErrUnknownContentType got %v", err)
+	}
+}
+
+func TestRegisterBodyEncoder(t *testing.T) {
+	RegisterBodyEncoder("application/x-test", BodyEncoderFunc(func(v interface{}) (io.Reader, error) {
+		return encodeRaw("test")
+	}))
+	t.Cleanup(func() {
+		codecMu.Lock()
+		defer codecMu.Unlock()
+		delete(bodyEncoders, "application/x-test")
+	})
+	reader, contentType, err := RequestData{Data: 1, ContentType: "application/x-test"}.bodyReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(reader)
+	if string(body) != "test" || contentType != "application/x-test" {
+		t.Errorf("Unexpected body %s with content type %s", body, contentType)
+	}
+}
@@ -0,0 +1,478 @@
+package HttpClientPool
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// CassetteRedacted replaces redacted values in cassettes.
+const CassetteRedacted = "[REDACTED]"
+
+// ErrCassetteMismatch is returned when a replaying cassette has no interaction matching a request.
+var ErrCassetteMismatch = errors.New("cassette has no matching interaction")
+
+// CassetteMode is how a Cassette handles requests.
+type CassetteMode int
+
+const (
+	// CassetteReplay answers requests from the cassette without using the
+	// network. Requests without a matching interaction fail with ErrCassetteMismatch.
+	CassetteReplay CassetteMode = iota
+	// CassetteRecord sends every request and records the interactions,
+	// replacing any already in the cassette.
+	CassetteRecord
+	// CassettePassthrough answers matching requests from the cassette and sends
+	// the others, recording them as new interactions.
+	CassettePassthrough
+)
+
+// CassetteMatch selects the parts of a request compared when matching interactions.
+type CassetteMatch int
+
+const (
+	// MatchMethod compares the request method.
+	MatchMethod CassetteMatch = 1 << iota
+	// MatchURL compares the URL including the query parameters.
+	MatchURL
+	// MatchBody compares a SHA-256 hash of the request body.
+	MatchBody
+)
+
+// CassetteOptions configures a Cassette.
+type CassetteOptions struct {
+	// Mode is how requests are handled, CassetteReplay by default.
+	Mode CassetteMode
+	// Match selects the parts of requests compared, MatchMethod|MatchURL|MatchBody if 0.
+	Match CassetteMatch
+	// MatchHeaders are request headers which must also be equal to match.
+	MatchHeaders []string
+	// Redact are the names of headers, query parameters and cookies whose values
+	// are replaced with CassetteRedacted when recorded, e.g. "Authorization".
+	// Cookies are also redacted inside Cookie and Set-Cookie headers.
+	Redact []string
+	// RedactPatterns are replaced with CassetteRedacted in recorded response
+	// bodies and URLs. Patterns with groups only replace the groups, e.g.
+	// `"token":"([^"]*)"` keeps the key.
+	RedactPatterns []*regexp.Regexp
+}
+
+// CassetteRequest is the recorded form of a request.
+type CassetteRequest struct {
+	Method string `json:"method"`
+	// Url includes the RequestData.Params.
+	Url     string              `json:"url"`
+	Headers map[string][]string `json:"headers,omitempty"`
+	// BodyHash is the hex SHA-256 of the body, empty for no body or a body which
+	// cannot be rewound.
+	BodyHash string `json:"body_hash,omitempty"`
+}
+
+// CassetteResponse is the recorded form of a response.
+type CassetteResponse struct {
+	Status     string              `json:"status"`
+	StatusCode int                 `json:"status_code"`
+	Headers    map[string][]string `json:"headers,omitempty"`
+	// Body is the response body, base64 encoded if BodyBase64 is set.
+	Body       string            `json:"body"`
+	BodyBase64 bool              `json:"body_base64,omitempty"`
+	Cookies    map[string]string `json:"cookies,omitempty"`
+	Url        string            `json:"url"`
+}
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+	// Error is the error returned instead of a response, if any.
+	Error      string    `json:"error,omitempty"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// Cassette records request and response pairs to a file and replays them for
+// reproducible tests.
+//
+// Example:
+//
+//	cassette, err := HttpClientPool.LoadCassette("testdata/api.json", HttpClientPool.CassetteOptions{
+//		Mode:   HttpClientPool.CassetteRecord,
+//		Redact: []string{"Authorization"},
+//	})
+//	pool.Use(cassette.Middleware())
+//	...
+//	cassette.Save()
+type Cassette struct {
+	// Interactions are the recorded interactions in order.
+	Interactions []Interaction `json:"interactions"`
+	path         string
+	options      CassetteOptions
+	// played counts the times each interaction has been replayed.
+	played []int
+	mu     sync.Mutex
+}
+
+// LoadCassette loads a cassette file.
+//
+// In CassetteReplay mode the file must exist. In CassetteRecord mode the file is
+// not read and is replaced on Save. In CassettePassthrough mode a missing file
+// starts an empty cassette.
+//
+// Parameters:
+//   - path (string): The path of the cassette file.
+//   - options (CassetteOptions): The cassette options.
+//
+// Returns:
+//   - *Cassette: A pointer to the loaded cassette.
+//   - error: An error reading or decoding the file.
+func LoadCassette(path string, options CassetteOptions) (*Cassette, error) {
+	if options.Match == 0 {
+		options.Match = MatchMethod | MatchURL | MatchBody
+	}
+	cassette := &Cassette{path: path, options: options}
+	if options.Mode == CassetteRecord {
+		return cassette, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && options.Mode == CassettePassthrough {
+		return cassette, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cassette); err != nil {
+		return nil, fmt.Errorf("invalid cassette %s: %w", path, err)
+	}
+	cassette.played = make([]int, len(cassette.Interactions))
+	return cassette, nil
+}
+
+// Save writes the cassette to its file.
+//
+// Returns:
+//   - error: An error encoding or writing the file.
+func (cassette *Cassette) Save() error {
+	cassette.mu.Lock()
+	data, err := json.MarshalIndent(cassette, "", "  ")
+	cassette.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(cassette.path, append(data, '\n'), 0644)
+}
+
+// Unplayed returns the recorded interactions which have not been replayed,
+// which lets tests check every expected request was made.
+//
+// Returns:
+//   - []Interaction: The unplayed interactions in order.
+func (cassette *Cassette) Unplayed() []Interaction {
+	cassette.mu.Lock()
+	defer cassette.mu.Unlock()
+	var unplayed []Interaction
+	for idx, interaction := range cassette.Interactions {
+		if idx < len(cassette.played) && cassette.played[idx] == 0 {
+			unplayed = append(unplayed, interaction)
+		}
+	}
+	return unplayed
+}
+
+// Middleware returns middleware recording or replaying requests with the cassette.
+//
+// Used as pool middleware replayed requests do not use a Client, so they are
+// not ratelimited.
+//
+// Returns:
+//   - Middleware: The cassette middleware.
+func (cassette *Cassette) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, reqData RequestData) (ResponseData, error) {
+			request := cassette.request(reqData)
+			if cassette.options.Mode != CassetteRecord {
+				if interaction, found := cassette.play(request); found {
+					response, err := interaction.Response.responseData()
+					if err == nil && interaction.Error != "" {
+						err = errors.New(interaction.Error)
+					}
+					return response, err
+				}
+				if cassette.options.Mode == CassetteReplay {
+					return ResponseData{}, cassette.mismatch(request)
+				}
+			}
+			response, err := next(ctx, reqData)
+			var validationErr *ValidationError
+			if errors.As(err, &validationErr) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				// Not an interaction with the server
+				return response, err
+			}
+			cassette.record(request, response, err, clockFromContext(ctx).Now())
+			return response, err
+		}
+	}
+}
+
+// play finds the interaction matching a request.
+//
+// Interactions are replayed in order, so repeated requests receive the
+// responses in the order they were recorded. Once every match has been
+// played the last is repeated.
+func (cassette *Cassette) play(request CassetteRequest) (Interaction, bool) {
+	cassette.mu.Lock()
+	defer cassette.mu.Unlock()
+	last := -1
+	for idx, interaction := range cassette.Interactions {
+		if !cassette.matches(interaction.Request, request) {
+			continue
+		}
+		last = idx
+		if cassette.played[idx] == 0 {
+			break
+		}
+	}
+	if last < 0 {
+		return Interaction{}, false
+	}
+	cassette.played[last]++
+	return cassette.Interactions[last], true
+}
+
+// mismatch returns the error for a request without a matching interaction.
+func (cassette *Cassette) mismatch(request CassetteRequest) error {
+	cassette.mu.Lock()
+	defer cassette.mu.Unlock()
+	return fmt.Errorf("%w: %s %s (%d recorded interactions in %s)",
+		ErrCassetteMismatch, request.Method, request.Url, len(cassette.Interactions), cassette.path)
+}
+
+// matches reports whether a recorded request matches a request.
+func (cassette *Cassette) matches(recorded, request CassetteRequest) bool {
+	match := cassette.options.Match
+	if match&MatchMethod != 0 && recorded.Method != request.Method {
+		return false
+	}
+	if match&MatchURL != 0 && recorded.Url != request.Url {
+		return false
+	}
+	if match&MatchBody != 0 && recorded.BodyHash != request.BodyHash {
+		return false
+	}
+	for _, name := range cassette.options.MatchHeaders {
+		name = http.CanonicalHeaderKey(name)
+		if strings.Join(recorded.Headers[name], ",") != strings.Join(request.Headers[name], ",") {
+			return false
+		}
+	}
+	return true
+}
+
+// record appends an interaction recorded at now, redacting the response.
+func (cassette *Cassette) record(request CassetteRequest, response ResponseData, err error, now time.Time) {
+	interaction := Interaction{Request: request, RecordedAt: now}
+	interaction.Response = CassetteResponse{
+		Status:     response.Status,
+		StatusCode: response.StatusCode,
+		Headers:    cassette.redactHeaders(response.Headers),
+		Cookies:    cassette.redactCookies(response.Cookies),
+		Url:        string(cassette.redactText([]byte(cassette.redactUrl(response.Url)))),
+	}
+	if body := cassette.redactText(response.Body); utf8.Valid(body) {
+		interaction.Response.Body = string(body)
+	} else {
+		interaction.Response.Body = base64.StdEncoding.EncodeToString(body)
+		interaction.Response.BodyBase64 = true
+	}
+	if err != nil {
+		interaction.Error = err.Error()
+	}
+	cassette.mu.Lock()
+	defer cassette.mu.Unlock()
+	cassette.Interactions = append(cassette.Interactions, interaction)
+	cassette.played = append(cassette.played, 1)
+}
+
+// responseData returns the replayed response.
+func (response CassetteResponse) responseData() (ResponseData, error) {
+	body := []byte(response.Body)
+	if response.BodyBase64 {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(response.Body); err != nil {
+			return ResponseData{}, fmt.Errorf("invalid cassette body: %w", err)
+		}
+	}
+	return ResponseData{
+		Status:     response.Status,
+		StatusCode: response.StatusCode,
+		Headers:    response.Headers,
+		Body:       body,
+		Cookies:    response.Cookies,
+		Url:        response.Url,
+	}, nil
+}
+
+// request returns the redacted recorded form of a request.
+func (cassette *Cassette) request(reqData RequestData) CassetteRequest {
+	request := CassetteRequest{
+		Method: strings.ToUpper(reqData.Type),
+		Url:    string(cassette.redactText([]byte(cassette.redactUrl(reqData.fullUrl())))),
+	}
+	if len(reqData.Headers) > 0 {
+		headers := make(map[string][]string, len(reqData.Headers))
+		for key, values := range reqData.Headers {
+			headers[http.CanonicalHeaderKey(key)] = values
+		}
+		request.Headers = cassette.redactHeaders(headers)
+	}
+	request.BodyHash = bodyHash(reqData)
+	return request
+}
+
+// bodyHash returns the hex SHA-256 of a request body, empty if the body is
+// empty or cannot be rewound.
+func bodyHash(reqData RequestData) string {
+	body, contentType, ok := reqData.bodyBytes()
+	if !ok || len(body) == 0 {
+		return ""
+	}
+	// Multipart boundaries are random so remove them
+	if _, params, err := mime.ParseMediaType(contentType); err == nil && params["boundary"] != "" {
+		body = []byte(strings.ReplaceAll(string(body), params["boundary"], ""))
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// redacted reports whether the values of a header, parameter or cookie are redacted.
+func (cassette *Cassette) redacted(name string) bool {
+	for _, redact := range cassette.options.Redact {
+		if strings.EqualFold(redact, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactHeaders returns a copy of headers with redacted values replaced.
+func (cassette *Cassette) redactHeaders(headers map[string][]string) map[string][]string {
+	if headers == nil {
+		return nil
+	}
+	redacted := make(map[string][]string, len(headers))
+	for key, values := range headers {
+		if cassette.redacted(key) {
+			values = []string{CassetteRedacted}
+		} else if key == "Cookie" || key == "Set-Cookie" {
+			cookies := make([]string, len(values))
+			for idx, value := range values {
+				cookies[idx] = cassette.redactCookieHeader(key, value)
+			}
+			values = cookies
+		}
+		redacted[key] = values
+	}
+	return redacted
+}
+
+// redactCookieHeader replaces the values of redacted cookies in a Cookie or
+// Set-Cookie header value, keeping the other cookies and attributes.
+func (cassette *Cassette) redactCookieHeader(key, value string) string {
+	pairs := strings.Split(value, ";")
+	// Only the first pair of Set-Cookie is a cookie, the rest are attributes
+	if key == "Set-Cookie" {
+		pairs = pairs[:1]
+	}
+	changed := false
+	for idx, pair := range pairs {
+		name, _, found := strings.Cut(pair, "=")
+		if found && cassette.redacted(strings.TrimSpace(name)) {
+			pairs[idx] = name + "=" + CassetteRedacted
+			changed = true
+		}
+	}
+	if !changed {
+		return value
+	}
+	if key == "Set-Cookie" {
+		_, attributes, found := strings.Cut(value, ";")
+		if found {
+			return pairs[0] + ";" + attributes
+		}
+		return pairs[0]
+	}
+	return strings.Join(pairs, ";")
+}
+
+// redactCookies returns a copy of cookies with redacted values replaced.
+func (cassette *Cassette) redactCookies(cookies map[string]string) map[string]string {
+	if cookies == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(cookies))
+	for name, value := range cookies {
+		if cassette.redacted(name) {
+			value = CassetteRedacted
+		}
+		redacted[name] = value
+	}
+	return redacted
+}
+
+// redactUrl replaces the values of redacted query parameters.
+func (cassette *Cassette) redactUrl(rawUrl string) string {
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil || parsedUrl.RawQuery == "" {
+		return rawUrl
+	}
+	query := parsedUrl.Query()
+	changed := false
+	for key := range query {
+		if cassette.redacted(key) {
+			query[key] = []string{CassetteRedacted}
+			changed = true
+		}
+	}
+	if !changed {
+		return rawUrl
+	}
+	parsedUrl.RawQuery = query.Encode()
+	return parsedUrl.String()
+}
+
+// redactText replaces the matches of the redact patterns.
+func (cassette *Cassette) redactText(text []byte) []byte {
+	for _, pattern := range cassette.options.RedactPatterns {
+		var redacted []byte
+		last := 0
+		for _, match := range pattern.FindAllSubmatchIndex(text, -1) {
+			// Replace the groups, or the whole match without groups
+			spans := match[2:]
+			if len(spans) == 0 {
+				spans = match[:2]
+			}
+			for idx := 0; idx+1 < len(spans); idx += 2 {
+				if spans[idx] < last {
+					continue
+				}
+				redacted = append(redacted, text[last:spans[idx]]...)
+				redacted = append(redacted, CassetteRedacted...)
+				last = spans[idx+1]
+			}
+		}
+		if redacted != nil {
+			text = append(redacted, text[last:]...)
+		}
+	}
+	return text
+}
@@ -0,0 +1,172 @@
+package HttpClientPool
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RootInit/HttpClientPool/pooltest"
+)
+
+func TestCassette(t *testing.T) {
+	server := pooltest.NewScriptedServer(t,
+		pooltest.Response{Body: `{"token":"secret-token","id":1}`, Header: http.Header{"Set-Cookie": {"session=abc"}}},
+		pooltest.Response{Status: http.StatusCreated, Body: "created"},
+	)
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	options := CassetteOptions{
+		Mode:           CassetteRecord,
+		MatchHeaders:   []string{"X-Tenant"},
+		Redact:         []string{"Authorization", "api_key", "Set-Cookie", "session"},
+		RedactPatterns: []*regexp.Regexp{regexp.MustCompile(`"token":"([^"]*)"`)},
+	}
+	login := RequestData{
+		Type:    "GET",
+		Url:     server.URL + "/login",
+		Params:  map[string][]string{"api_key": {"secret-key"}},
+		Headers: map[string][]string{"Authorization": {"Bearer secret-bearer"}, "X-Tenant": {"a"}},
+	}
+	create := RequestData{Type: "POST", Url: server.URL + "/items", JsonData: map[string]int{"id": 1}}
+	// Record
+	cassette, err := LoadCassette(path, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := NewClientPool(0, 0, nil, nil)
+	pool.SetClock(NewFakeClock(time.Unix(1700000000, 0)))
+	pool.Use(cassette.Middleware())
+	for _, reqData := range []RequestData{login, create} {
+		if _, err := pool.QuickRequest(reqData); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Interactions are timed by the pool clock
+	if recordedAt := cassette.Interactions[0].RecordedAt; !recordedAt.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("Unexpected RecordedAt %s", recordedAt)
+	}
+	if err := cassette.Save(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	for _, secret := range []string{"secret-key", "secret-bearer", "secret-token", "abc"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("Cassette contains %q", secret)
+		}
+	}
+	if !strings.Contains(string(data), `\"token\":\"[REDACTED]\"`) {
+		t.Errorf("Expected the token key to be kept\n%s", data)
+	}
+	// Replay without the server
+	server.Close()
+	options.Mode = CassetteReplay
+	cassette, err = LoadCassette(path, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool = NewClientPool(0, 0, nil, nil)
+	pool.Use(cassette.Middleware())
+	if len(cassette.Unplayed()) != 2 {
+		t.Fatalf("Expected 2 unplayed interactions, got %d", len(cassette.Unplayed()))
+	}
+	response, err := pool.QuickRequest(create)
+	if err != nil || response.StatusCode != http.StatusCreated || string(response.Body) != "created" {
+		t.Fatalf("Unexpected replay %d %q %v", response.StatusCode, response.Body, err)
+	}
+	// Secrets in the live request are redacted before matching
+	if response, err := pool.QuickRequest(login); err != nil || response.StatusCode != http.StatusOK {
+		t.Fatalf("Unexpected replay %d %v", response.StatusCode, err)
+	}
+	if len(cassette.Unplayed()) != 0 {
+		t.Fatal("Expected every interaction to be played")
+	}
+	// Unmatched method, body, header and URL fail loudly
+	otherTenant := login
+	otherTenant.Headers = map[string][]string{"X-Tenant": {"b"}}
+	otherBody := create
+	otherBody.JsonData = map[string]int{"id": 2}
+	otherUrl := create
+	otherUrl.Url += "/2"
+	for _, reqData := range []RequestData{otherTenant, otherBody, otherUrl, {Type: "DELETE", Url: create.Url}} {
+		if _, err := pool.QuickRequest(reqData); !errors.Is(err, ErrCassetteMismatch) || !strings.Contains(err.Error(), reqData.Type) {
+			t.Errorf("Expected a mismatch for %s %s, got %v", reqData.Type, reqData.Url, err)
+		}
+	}
+	// Ignoring the body matches any body
+	options.Match = MatchMethod | MatchURL
+	cassette, _ = LoadCassette(path, options)
+	pool = NewClientPool(0, 0, nil, nil)
+	pool.Use(cassette.Middleware())
+	if _, err := pool.QuickRequest(otherBody); err != nil {
+		t.Fatalf("Expected the body to be ignored, got %v", err)
+	}
+}
+
+// Redacting a cookie by name leaves the other cookies in the cookie headers
+func TestCassetteRedactCookies(t *testing.T) {
+	server := pooltest.NewScriptedServer(t, pooltest.Response{Header: http.Header{
+		"Set-Cookie": {"session=secret-session; Path=/; HttpOnly", "theme=dark; Path=/"},
+	}})
+	cassette, err := LoadCassette(filepath.Join(t.TempDir(), "cassette.json"), CassetteOptions{
+		Mode:   CassetteRecord,
+		Redact: []string{"session"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := NewClientPool(0, 0, nil, nil)
+	pool.Use(cassette.Middleware())
+	reqData := RequestData{
+		Type:    "GET",
+		Url:     server.URL,
+		Headers: map[string][]string{"Cookie": {"theme=light; session=secret-request"}},
+	}
+	if _, err := pool.QuickRequest(reqData); err != nil {
+		t.Fatal(err)
+	}
+	interaction := cassette.Interactions[0]
+	if cookie := interaction.Request.Headers["Cookie"]; len(cookie) != 1 || cookie[0] != "theme=light; session=[REDACTED]" {
+		t.Errorf("Unexpected Cookie header %q", cookie)
+	}
+	setCookie := interaction.Response.Headers["Set-Cookie"]
+	if len(setCookie) != 2 || setCookie[0] != "session=[REDACTED]; Path=/; HttpOnly" || setCookie[1] != "theme=dark; Path=/" {
+		t.Errorf("Unexpected Set-Cookie headers %q", setCookie)
+	}
+	cookies := interaction.Response.Cookies
+	if cookies["session"] != CassetteRedacted || cookies["theme"] != "dark" {
+		t.Errorf("Unexpected cookies %v", cookies)
+	}
+}
+
+func TestCassettePassthrough(t *testing.T) {
+	server := pooltest.NewEchoServer(t)
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	if _, err := LoadCassette(path, CassetteOptions{}); err == nil {
+		t.Fatal("Expected replaying a missing cassette to fail")
+	}
+	cassette, err := LoadCassette(path, CassetteOptions{Mode: CassettePassthrough})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := NewClientPool(0, 0, nil, nil)
+	pool.Use(cassette.Middleware())
+	first := RequestData{Type: "GET", Url: server.URL + "/first"}
+	second := RequestData{Type: "GET", Url: server.URL + "/second"}
+	for _, reqData := range []RequestData{first, first, second} {
+		if _, err := pool.QuickRequest(reqData); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The repeated request was answered from the cassette
+	if len(server.Requests()) != 2 || len(cassette.Interactions) != 2 {
+		t.Fatalf("Expected 2 requests and interactions, got %d and %d", len(server.Requests()), len(cassette.Interactions))
+	}
+	// Validation errors are not recorded
+	if _, err := pool.QuickRequest(RequestData{Type: "BOGUS", Url: server.URL}); err == nil || len(cassette.Interactions) != 2 {
+		t.Fatalf("Expected an unrecorded validation error, got %v", err)
+	}
+}
@@ -1,55 +1,105 @@
 package HttpClientPool
 
 import (
+	"crypto/tls"
+	"github.com/RootInit/HttpClientPool/Utils"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// lastClientID is the id of the most recently created Client.
+var lastClientID atomic.Uint64
+
 // Client represents an HTTP client with inbuilt ratelimiting.
 //
 // This type can be used along with a ClientPool for orchestration of multiple clients.
 type Client struct {
 	// Client is the underlying HTTP client for making requests.
 	*http.Client
+	// id uniquely identifies the client within the process.
+	id uint64
 	// userAgent is the user agent string to be set in the client's requests.
-	userAgent   string
-	delay       time.Duration
-	running     bool
+	userAgent string
+	// transport is the underlying transport used by the client.
+	transport  *http.Transport
+	tlsProfile *TLSProfile
+	// profileTransport sends HTTPS requests with the TLS profile, nil without a profile.
+	profileTransport *profileTransport
+	// harRecorder records the requests sent by the client, nil if not recording.
+	harRecorder *HARRecorder
+	// authenticator adds credentials to requests made by QuickRequest.
+	authenticator Authenticator
+	// signer signs requests made by QuickRequest.
+	signer Signer
+	// middleware wraps the requests made by QuickRequest.
+	middleware []Middleware
+	// quotas paces requests using rate limit headers, nil if disabled.
+	quotas *quotaTracker
+	// proxy is the proxy URL used by the client, nil for no proxy.
+	proxy *url.URL
+	delay time.Duration
+	// burst is the number of requests which may be sent back to back.
+	burst int
+	// jitter randomises the delay between requests, nil for a fixed delay.
+	jitter Jitter
+	rng    *rand.Rand
+	// spacing is the jittered delay following the last request.
+	spacing time.Duration
+	// tat is the theoretical arrival time of the next request when bursting.
+	tat         time.Time
+	inFlight    int
+	maxInFlight int
 	lastReqTime time.Time
-	mu          sync.Mutex
+	bannedUntil time.Time
+	banCount    int
+	// clock tells the time for ratelimiting.
+	clock Clock
+	// pools are the pools woken when the client may have become available.
+	pools map[*ClientPool]struct{}
+	mu    sync.Mutex
 }
 
 // NewClient creates a new HTTP client with optional proxy, user agent, and request delay.
 //
 // Parameters:
-//   - proxy (*url.URL): The proxy URL to be used for the client. Use nil for no proxy.
+//   - proxy (*url.URL): The proxy URL to be used for the client. Use nil for the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment settings.
 //   - userAgent (string): The user agent string to be set in the client's requests.
 //   - delay (time.Duration): The delay between requests made by the client. Use 0 for no delay.
 //
 // Returns:
 //   - *Client: A pointer to the initialized HTTP client.
 func NewClient(proxy *url.URL, userAgent string, delay time.Duration) *Client {
-	var httpClient *http.Client
+	// Without a proxy the cloned transport keeps http.ProxyFromEnvironment
+	transport := http.DefaultTransport.(*http.Transport).Clone()
 	if proxy != nil {
 		// Set proxy
-		transport := &http.Transport{
-			Proxy: http.ProxyURL(proxy),
-		}
-		httpClient = &http.Client{Transport: transport}
-	} else {
-		// No proxy
-		httpClient = &http.Client{}
+		transport.Proxy = http.ProxyURL(proxy)
 	}
 	client := Client{
-		Client:    httpClient,
-		userAgent: userAgent,
-		delay:     delay,
+		Client:      &http.Client{Transport: transport},
+		id:          lastClientID.Add(1),
+		userAgent:   userAgent,
+		transport:   transport,
+		proxy:       proxy,
+		delay:       delay,
+		maxInFlight: 1,
+		clock:       RealClock,
 	}
 	return &client
 }
 
+// ID returns the id of the client, unique within the process
+//
+// Returns:
+//   - uint64: the client.id value
+func (client *Client) ID() uint64 {
+	return client.id
+}
+
 // IsRunning returns true if the client is currently running.
 //
 // This method is used to check if the client is actively processing requests.
@@ -59,22 +109,63 @@ func NewClient(proxy *url.URL, userAgent string, delay time.Duration) *Client {
 func (client *Client) IsRunning() bool {
 	client.mu.Lock()
 	defer client.mu.Unlock()
-	return client.running
+	return client.inFlight > 0
 }
 
-// SetActive marks the HTTP client as active and updates the lastReqTime.
+// SetActive marks the start of a request by the HTTP client and updates the lastReqTime.
 func (client *Client) SetActive() {
 	client.mu.Lock()
 	defer client.mu.Unlock()
-	client.running = true
-	client.lastReqTime = time.Now()
+	client.markActive()
+}
+
+// markActive records the start of a request. The caller must hold client.mu.
+func (client *Client) markActive() {
+	client.inFlight++
+	client.lastReqTime = client.clock.Now()
+	client.spacing = client.jitter.sample(client.delay, client.rng)
+	client.tat = nextArrival(client.tat, client.lastReqTime, client.spacing)
 }
 
-// SetInactive marks the HTTP client as inactive.
+// SetInactive marks the end of a request by the HTTP client.
 func (client *Client) SetInactive() {
+	client.mu.Lock()
+	if client.inFlight > 0 {
+		client.inFlight--
+	}
+	client.mu.Unlock()
+	client.notify()
+}
+
+// GetInFlight returns the number of requests the client is currently running
+//
+// Returns:
+//   - int: the client.inFlight value
+func (client *Client) GetInFlight() int {
 	client.mu.Lock()
 	defer client.mu.Unlock()
-	client.running = false
+	return client.inFlight
+}
+
+// SetMaxInFlight sets the maximum number of concurrent requests for the client.
+//
+// Parameters:
+//   - maxInFlight (int): The maximum concurrent requests. Defaults to 1. Use 0 for no limit.
+func (client *Client) SetMaxInFlight(maxInFlight int) {
+	client.mu.Lock()
+	client.maxInFlight = maxInFlight
+	client.mu.Unlock()
+	client.notify()
+}
+
+// GetMaxInFlight returns the maximum number of concurrent requests for the client
+//
+// Returns:
+//   - int: the client.maxInFlight value, 0 for no limit
+func (client *Client) GetMaxInFlight() int {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return client.maxInFlight
 }
 
 // SetDelay ets the clients delay
@@ -83,8 +174,9 @@ func (client *Client) SetInactive() {
 //   - delay (time.Duration): The duration of the new delay
 func (client *Client) SetDelay(delay time.Duration) {
 	client.mu.Lock()
-	defer client.mu.Unlock()
 	client.delay = delay
+	client.mu.Unlock()
+	client.notify()
 }
 
 // GetDelay returns the clients delay
@@ -97,7 +189,7 @@ func (client *Client) GetDelay() time.Duration {
 	return client.delay
 }
 
-// IsAvailable returns true if the client is not currently running or rate-limited.
+// IsAvailable returns true if the client is not at its in-flight limit, rate-limited or banned.
 //
 // This method is used to check if the client is in an available state for new requests.
 //
@@ -106,15 +198,189 @@ func (client *Client) GetDelay() time.Duration {
 func (client *Client) IsAvailable() bool {
 	client.mu.Lock()
 	defer client.mu.Unlock()
-	// Check currently running
-	if client.running {
-		return false
+	return client.isAvailable()
+}
+
+// isAvailable implements IsAvailable. client.mu must be held.
+func (client *Client) isAvailable() bool {
+	available, _ := client.availability()
+	return available
+}
+
+// availability returns whether the client is available and if not, how long
+// until it will be. client.mu must be held.
+//
+// Returns:
+//   - bool: True if the client is available; otherwise, false.
+//   - time.Duration: The time until the ratelimit or ban ends, 0 if the client
+//     is at its in-flight limit and must wait for a request to finish.
+func (client *Client) availability() (bool, time.Duration) {
+	// Check in-flight limit
+	if client.maxInFlight > 0 && client.inFlight >= client.maxInFlight {
+		return false, 0
 	}
 	// Check client ratelimited
-	if client.lastReqTime.Add(client.delay).After(time.Now()) {
-		return false
+	now := client.clock.Now()
+	var wait time.Duration
+	if client.burst > 1 {
+		wait = burstWait(client.tat, now, client.delay, client.burst)
+	} else {
+		wait = client.lastReqTime.Add(client.nextDelay()).Sub(now)
+	}
+	// Check client banned
+	if banWait := client.bannedUntil.Sub(now); banWait > wait {
+		wait = banWait
+	}
+	return wait <= 0, max(wait, 0)
+}
+
+// tryActivate marks the client active if it is available.
+//
+// Returns:
+//   - bool: True if the client was available and is now active.
+//   - time.Duration: The time until the client is available, see availability.
+func (client *Client) tryActivate() (bool, time.Duration) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	available, wait := client.availability()
+	if available {
+		client.markActive()
+	}
+	return available, wait
+}
+
+// watch registers a pool to be woken when the client may have become available.
+func (client *Client) watch(pool *ClientPool) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if client.pools == nil {
+		client.pools = make(map[*ClientPool]struct{})
+	}
+	client.pools[pool] = struct{}{}
+}
+
+// unwatch removes a pool registered by watch.
+func (client *Client) unwatch(pool *ClientPool) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	delete(client.pools, pool)
+}
+
+// notify wakes the pools waiting for the client. client.mu must not be held.
+func (client *Client) notify() {
+	client.mu.Lock()
+	pools := make([]*ClientPool, 0, len(client.pools))
+	for pool := range client.pools {
+		pools = append(pools, pool)
+	}
+	client.mu.Unlock()
+	for _, pool := range pools {
+		pool.wake()
+	}
+}
+
+// nextDelay returns the delay following the last request. The caller must hold client.mu.
+//
+// Returns:
+//   - time.Duration: The jittered delay, or the configured delay without jitter.
+func (client *Client) nextDelay() time.Duration {
+	if client.jitter == nil {
+		return client.delay
+	}
+	return client.spacing
+}
+
+// SetClock sets the clock used for the clients ratelimiting.
+//
+// Parameters:
+//   - clock (Clock): The clock, e.g. a FakeClock in tests. Use RealClock for the time package.
+func (client *Client) SetClock(clock Clock) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.clock = clock
+}
+
+// GetClock returns the clock used for the clients ratelimiting
+//
+// Returns:
+//   - Clock: the client.clock value
+func (client *Client) GetClock() Clock {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return client.clock
+}
+
+// SetJitter randomises the clients delay between requests.
+//
+// The delay before each request is sampled from the jitter, so the average
+// rate still matches the configured delay.
+//
+// Parameters:
+//   - jitter (Jitter): The jitter, e.g. UniformJitter(0.2). Use nil for a fixed delay.
+//   - seed (int64): The random seed. Equal seeds give equal delays, use
+//     time.Now().UnixNano() for unpredictable delays.
+func (client *Client) SetJitter(jitter Jitter, seed int64) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.jitter = jitter
+	client.rng = newJitterRand(seed)
+}
+
+// SetRate sets the clients delay and burst from a rate.
+//
+// Parameters:
+//   - rate (Utils.Rate): The rate, e.g. from Utils.ParseRate. Use the zero Rate for no limit.
+func (client *Client) SetRate(rate Utils.Rate) {
+	client.mu.Lock()
+	client.delay = rate.Interval()
+	client.burst = rate.Burst
+	client.mu.Unlock()
+	client.notify()
+}
+
+// GetBurst returns the number of requests the client may send back to back
+//
+// Returns:
+//   - int: the client.burst value, 1 if bursting is disabled
+func (client *Client) GetBurst() int {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return max(client.burst, 1)
+}
+
+// nextArrival returns the theoretical arrival time after a request is sent.
+//
+// Parameters:
+//   - tat (time.Time): The current theoretical arrival time.
+//   - now (time.Time): The time the request is sent.
+//   - interval (time.Duration): The delay between requests.
+//
+// Returns:
+//   - time.Time: The theoretical arrival time of the next request.
+func nextArrival(tat, now time.Time, interval time.Duration) time.Time {
+	if tat.Before(now) {
+		tat = now
 	}
-	return true
+	return tat.Add(interval)
+}
+
+// burstWait returns how long until a request is allowed by a generic cell rate
+// algorithm allowing bursts of up to burst requests.
+//
+// Parameters:
+//   - tat (time.Time): The theoretical arrival time of the next request.
+//   - now (time.Time): The current time.
+//   - interval (time.Duration): The delay between requests.
+//   - burst (int): The number of requests which may be sent back to back.
+//
+// Returns:
+//   - time.Duration: The time until a request is allowed, 0 or less if allowed now.
+func burstWait(tat, now time.Time, interval time.Duration, burst int) time.Duration {
+	if !tat.After(now) {
+		return 0
+	}
+	tolerance := time.Duration(burst-1) * interval
+	return tat.Sub(now) - tolerance
 }
 
 // GetUserAgent sets the Clients user-agent
@@ -146,3 +412,77 @@ func (client *Client) GetRequestTime() time.Time {
 	defer client.mu.Unlock()
 	return client.lastReqTime
 }
+
+// SetTLSProfile sets the TLS ClientHello and HTTP/2 profile used by the client.
+//
+// The profile should be set before the client makes any requests as existing
+// connections keep the handshake they were created with.
+//
+// Parameters:
+//   - profile (*TLSProfile): The profile to use. Use nil for the Go defaults.
+func (client *Client) SetTLSProfile(profile *TLSProfile) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if client.profileTransport != nil {
+		client.profileTransport.CloseIdleConnections()
+	}
+	client.transport.CloseIdleConnections()
+	client.tlsProfile = profile
+	client.profileTransport = nil
+	if profile != nil {
+		client.profileTransport = newProfileTransport(client.transport, profile)
+	}
+	client.updateTransport()
+}
+
+// updateTransport sets the RoundTripper of the http.Client from the TLS profile
+// and HAR recorder of the client. The caller must hold client.mu.
+func (client *Client) updateTransport() {
+	var transport http.RoundTripper = client.transport
+	if client.profileTransport != nil {
+		transport = client.profileTransport
+	}
+	if client.harRecorder != nil {
+		transport = client.harRecorder.Transport(transport)
+	}
+	client.Client.Transport = transport
+}
+
+// updateTLSConfig modifies the TLS configuration of the client's transport.
+//
+// Parameters:
+//   - update (func(*tls.Config)): Called with a copy of the current configuration.
+func (client *Client) updateTLSConfig(update func(config *tls.Config)) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	config := &tls.Config{}
+	if client.transport.TLSClientConfig != nil {
+		config = client.transport.TLSClientConfig.Clone()
+	}
+	update(config)
+	client.transport.TLSClientConfig = config
+	client.transport.CloseIdleConnections()
+	if client.profileTransport != nil {
+		client.profileTransport.CloseIdleConnections()
+	}
+}
+
+// GetTLSProfile returns the clients TLS ClientHello profile
+//
+// Returns:
+//   - *TLSProfile: The client.tlsProfile value, nil if the Go defaults are used.
+func (client *Client) GetTLSProfile() *TLSProfile {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return client.tlsProfile
+}
+
+// GetProxy returns the clients proxy
+//
+// Returns:
+//   - *url.URL: the client.proxy value, nil for no proxy
+func (client *Client) GetProxy() *url.URL {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return client.proxy
+}
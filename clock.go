@@ -0,0 +1,282 @@
+package HttpClientPool
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and waits for the ratelimiting of clients, pools and limiters.
+//
+// The default RealClock uses the time package. Tests can use a FakeClock to
+// control time exactly.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// Sleep blocks for a duration.
+	Sleep(duration time.Duration)
+	// NewTimer returns a timer which fires once after a duration.
+	NewTimer(duration time.Duration) Timer
+}
+
+// idleAdvancer is implemented by clocks which move forward while a goroutine
+// blocks on an event rather than the clock, such as an auto advancing FakeClock.
+type idleAdvancer interface {
+	// advanceIdle moves the clock to the deadline of the next timer.
+	advanceIdle()
+}
+
+// Timer is a single event timer created by a Clock.
+type Timer interface {
+	// C returns the channel the time is sent on when the timer fires.
+	C() <-chan time.Time
+	// Stop prevents the timer from firing.
+	//
+	// Returns:
+	//   - bool: True if the timer was stopped; false if it already fired or was stopped.
+	Stop() bool
+}
+
+// RealClock is the Clock using the time package.
+var RealClock Clock = realClock{}
+
+// realClock implements Clock using the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time                 { return time.Now() }
+func (realClock) Sleep(duration time.Duration)   { time.Sleep(duration) }
+func (realClock) NewTimer(d time.Duration) Timer { return realTimer{time.NewTimer(d)} }
+
+// realTimer implements Timer using a time.Timer.
+type realTimer struct {
+	timer *time.Timer
+}
+
+func (timer realTimer) C() <-chan time.Time { return timer.timer.C }
+func (timer realTimer) Stop() bool          { return timer.timer.Stop() }
+
+// FakeClock is a Clock which only moves when advanced, for deterministic tests.
+//
+// Timers and sleeps fire when Advance moves the clock past them. With auto
+// advance enabled, starting a timer or sleep instead moves the clock straight to
+// its deadline, so a single goroutine waiting on the clock never blocks. A
+// goroutine waiting in ClientPool.GetClientContext for a client to finish its
+// request likewise moves the clock to the next timer.
+type FakeClock struct {
+	now         time.Time
+	autoAdvance bool
+	timers      []*fakeTimer
+	mu          sync.Mutex
+}
+
+// fakeTimer is a timer or AfterFunc waiting on a FakeClock.
+type fakeTimer struct {
+	clock    *FakeClock
+	deadline time.Time
+	c        chan time.Time
+	// fn is called instead of sending on c when set.
+	fn func()
+}
+
+// NewFakeClock creates a FakeClock.
+//
+// Parameters:
+//   - start (time.Time): The initial time of the clock.
+//
+// Returns:
+//   - *FakeClock: A pointer to the initialized clock.
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// Now returns the current time of the clock.
+//
+// Returns:
+//   - time.Time: The current time.
+func (clock *FakeClock) Now() time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	return clock.now
+}
+
+// Sleep blocks until the clock has advanced by duration.
+//
+// Parameters:
+//   - duration (time.Duration): The duration to sleep.
+func (clock *FakeClock) Sleep(duration time.Duration) {
+	<-clock.NewTimer(duration).C()
+}
+
+// NewTimer returns a timer which fires once the clock has advanced by duration.
+//
+// Parameters:
+//   - duration (time.Duration): The duration until the timer fires.
+//
+// Returns:
+//   - Timer: The timer.
+func (clock *FakeClock) NewTimer(duration time.Duration) Timer {
+	timer := &fakeTimer{clock: clock, c: make(chan time.Time, 1)}
+	clock.schedule(timer, duration)
+	return timer
+}
+
+// AfterFunc calls fn once the clock has advanced by duration.
+//
+// fn is called from the goroutine advancing the clock before any timer due at
+// the same time fires, which lets tests finish simulated requests exactly on time.
+//
+// Parameters:
+//   - duration (time.Duration): The duration until fn is called.
+//   - fn (func()): The function to call.
+//
+// Returns:
+//   - Timer: A timer which can be stopped to cancel the call.
+func (clock *FakeClock) AfterFunc(duration time.Duration, fn func()) Timer {
+	timer := &fakeTimer{clock: clock, fn: fn}
+	clock.schedule(timer, duration)
+	return timer
+}
+
+// Advance moves the clock forward, firing every timer which falls due.
+//
+// Parameters:
+//   - duration (time.Duration): The duration to advance by.
+func (clock *FakeClock) Advance(duration time.Duration) {
+	clock.mu.Lock()
+	clock.advanceTo(clock.now.Add(duration))
+}
+
+// SetAutoAdvance sets whether starting a timer moves the clock to its deadline.
+//
+// Auto advance suits tests where only one goroutine waits on the clock at a time,
+// for example one calling GetClient while requests are simulated with AfterFunc.
+//
+// Parameters:
+//   - enabled (bool): True to enable auto advance.
+func (clock *FakeClock) SetAutoAdvance(enabled bool) {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	clock.autoAdvance = enabled
+}
+
+// Waiters returns the number of timers waiting to fire.
+//
+// Tests can poll this to know when goroutines are blocked on the clock before
+// calling Advance.
+//
+// Returns:
+//   - int: The number of pending timers.
+func (clock *FakeClock) Waiters() int {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	return len(clock.timers)
+}
+
+// advanceIdle moves an auto advancing clock to the deadline of the next timer.
+// It is called by goroutines about to block on an event rather than the clock.
+func (clock *FakeClock) advanceIdle() {
+	clock.mu.Lock()
+	if !clock.autoAdvance || len(clock.timers) == 0 {
+		clock.mu.Unlock()
+		return
+	}
+	next := clock.timers[0].deadline
+	for _, timer := range clock.timers[1:] {
+		if timer.deadline.Before(next) {
+			next = timer.deadline
+		}
+	}
+	clock.advanceTo(next)
+}
+
+// schedule adds a timer firing after duration. Only timers without a
+// function auto advance the clock as functions do not block.
+func (clock *FakeClock) schedule(timer *fakeTimer, duration time.Duration) {
+	clock.mu.Lock()
+	timer.deadline = clock.now.Add(duration)
+	clock.timers = append(clock.timers, timer)
+	if duration > 0 && clock.autoAdvance && timer.fn == nil {
+		clock.advanceTo(timer.deadline)
+		return
+	}
+	// Fire timers which are already due
+	clock.advanceTo(clock.now)
+}
+
+// advanceTo moves the clock to until, firing due timers in deadline order.
+// The caller must hold clock.mu, which is released before returning.
+func (clock *FakeClock) advanceTo(until time.Time) {
+	for {
+		// Find the earliest due timer
+		next := -1
+		for idx, timer := range clock.timers {
+			if !timer.deadline.After(until) &&
+				(next < 0 || timer.deadline.Before(clock.timers[next].deadline) ||
+					(timer.deadline.Equal(clock.timers[next].deadline) && timer.fn != nil && clock.timers[next].fn == nil)) {
+				next = idx
+			}
+		}
+		if next < 0 {
+			break
+		}
+		timer := clock.timers[next]
+		clock.timers = append(clock.timers[:next], clock.timers[next+1:]...)
+		if timer.deadline.After(clock.now) {
+			clock.now = timer.deadline
+		}
+		if timer.fn != nil {
+			// Call without the lock so fn may use the clock
+			clock.mu.Unlock()
+			timer.fn()
+			clock.mu.Lock()
+		} else {
+			timer.c <- clock.now
+		}
+	}
+	if until.After(clock.now) {
+		clock.now = until
+	}
+	clock.mu.Unlock()
+}
+
+func (timer *fakeTimer) C() <-chan time.Time { return timer.c }
+
+func (timer *fakeTimer) Stop() bool {
+	clock := timer.clock
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	for idx, pending := range clock.timers {
+		if pending == timer {
+			clock.timers = append(clock.timers[:idx], clock.timers[idx+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// clockKey is the context key of the Clock used by middleware.
+type clockKey struct{}
+
+// ContextWithClock returns a context carrying a Clock for middleware such as
+// HostLimits and Retry. Pools add their Clock to the context of each request.
+//
+// Parameters:
+//   - ctx (context.Context): The parent context.
+//   - clock (Clock): The clock.
+//
+// Returns:
+//   - context.Context: The context carrying the clock.
+func ContextWithClock(ctx context.Context, clock Clock) context.Context {
+	return context.WithValue(ctx, clockKey{}, clock)
+}
+
+// clockFromContext returns the Clock carried by a context.
+//
+// Returns:
+//   - Clock: The clock, RealClock if the context carries none.
+func clockFromContext(ctx context.Context) Clock {
+	if clock, ok := ctx.Value(clockKey{}).(Clock); ok {
+		return clock
+	}
+	return RealClock
+}
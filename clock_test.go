@@ -0,0 +1,77 @@
+package HttpClientPool
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	var calls []string
+	clock.AfterFunc(20*time.Millisecond, func() { calls = append(calls, "late") })
+	clock.AfterFunc(10*time.Millisecond, func() { calls = append(calls, "early") })
+	stopped := clock.AfterFunc(15*time.Millisecond, func() { calls = append(calls, "stopped") })
+	if !stopped.Stop() || stopped.Stop() {
+		t.Fatal("Expected only the first Stop to succeed")
+	}
+	timer := clock.NewTimer(10 * time.Millisecond)
+	if clock.Waiters() != 3 {
+		t.Fatalf("Expected 3 waiters, got %d", clock.Waiters())
+	}
+	clock.Advance(10 * time.Millisecond)
+	select {
+	case now := <-timer.C():
+		if now != time.Unix(0, 0).Add(10*time.Millisecond) {
+			t.Fatalf("Unexpected fire time %s", now)
+		}
+	default:
+		t.Fatal("Expected the timer to fire")
+	}
+	clock.Advance(time.Second)
+	if len(calls) != 2 || calls[0] != "early" || calls[1] != "late" {
+		t.Fatalf("Unexpected calls %v", calls)
+	}
+	if clock.Now() != time.Unix(1, 10*int64(time.Millisecond)) {
+		t.Fatalf("Unexpected time %s", clock.Now())
+	}
+}
+
+func TestFakeClockPool(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	pool := NewClientPool(0, 50*time.Millisecond, nil, nil)
+	pool.SetClock(clock)
+	pool.GetClient().SetInactive()
+	// The second request waits on the clock until advanced
+	done := make(chan *Client)
+	go func() {
+		client, _ := pool.GetClientContext(context.Background(), GetClientOptions{})
+		done <- client
+	}()
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(49 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("Expected to wait for the pool delay")
+	case <-time.After(10 * time.Millisecond):
+	}
+	clock.Advance(time.Millisecond)
+	if client := <-done; client == nil {
+		t.Fatal("Expected a client")
+	}
+	// Middleware waits on the clock carried by the context
+	limiter := &hostLimiter{limit: HostLimit{Delay: time.Hour}}
+	clock.SetAutoAdvance(true)
+	ctx := ContextWithClock(context.Background(), clock)
+	for i := 0; i < 3; i++ {
+		if err := limiter.acquire(ctx); err != nil {
+			t.Fatal(err)
+		}
+		limiter.release()
+	}
+	if clock.Now() != time.Unix(0, 0).Add(50*time.Millisecond+2*time.Hour) {
+		t.Fatalf("Expected two host delays, got %s", clock.Now())
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/RootInit/HttpClientPool"
+)
+
+// runBatch runs the requests of a JSONL file, writing the responses as JSONL.
+func runBatch(args []string) error {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	var flags poolFlags
+	flags.register(fs)
+	inputPath := fs.String("in", "", "JSONL request `file`")
+	outputPath := fs.String("out", "", "JSONL response `file`, completed ids are skipped and failed ones retried")
+	workers := fs.Int("workers", 0, "concurrent requests (default number of clients)")
+	ordered := fs.Bool("ordered", false, "write responses in input order")
+	stopOnError := fs.Bool("stop-on-error", false, "stop after the first failed request")
+	quiet := fs.Bool("q", false, "do not report progress")
+	fs.Parse(args)
+	if *inputPath == "" || *outputPath == "" {
+		fs.Usage()
+		return errors.New("-in and -out are required")
+	}
+	pool, err := flags.pool()
+	if err != nil {
+		return err
+	}
+	opts := HttpClientPool.BatchOptions{Workers: *workers, Ordered: *ordered, StopOnError: *stopOnError}
+	if !*quiet {
+		opts.Progress = func(progress HttpClientPool.BatchProgress) {
+			fmt.Fprintf(stderr, "\r%d done, %d failed, %s", progress.Completed, progress.Failed, progress.Elapsed.Round(1e6))
+		}
+	}
+	// Stop cleanly on interrupt so the run can be resumed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	stats, err := pool.RunJSONLFile(ctx, *inputPath, *outputPath, opts)
+	if !*quiet {
+		fmt.Fprintln(stderr)
+	}
+	fmt.Fprintf(stderr, "%d succeeded, %d failed, %d skipped\n", stats.Succeeded, stats.Failed, stats.Skipped)
+	return err
+}
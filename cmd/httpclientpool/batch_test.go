@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/RootInit/HttpClientPool"
+	"github.com/RootInit/HttpClientPool/pooltest"
+)
+
+func TestBatch(t *testing.T) {
+	server := pooltest.NewEchoServer(t)
+	_, errOut := captureOutput(t)
+	dir := t.TempDir()
+	inputPath, outputPath := filepath.Join(dir, "in.jsonl"), filepath.Join(dir, "out.jsonl")
+	input := `{"id": "a", "Type": "GET", "Url": "` + server.URL + `/a"}` + "\n" +
+		`{"id": "b", "Type": "GET", "Url": "` + server.URL + `/b"}` + "\n"
+	os.WriteFile(inputPath, []byte(input), 0644)
+	args := []string{"-in", inputPath, "-out", outputPath, "-q", "-ordered"}
+	if err := runBatch(args); err != nil {
+		t.Fatal(err)
+	}
+	if summary := errOut.String(); summary != "2 succeeded, 0 failed, 0 skipped\n" {
+		t.Errorf("Unexpected summary %q", summary)
+	}
+	file, err := os.Open(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var ids []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record HttpClientPool.JSONLRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		if record.Response == nil || !strings.Contains(string(record.Response.Body), `"url":"/`+record.ID+`"`) {
+			t.Errorf("Unexpected record %+v", record)
+		}
+		ids = append(ids, record.ID)
+	}
+	if strings.Join(ids, ",") != "a,b" {
+		t.Errorf("Expected records a,b, got %v", ids)
+	}
+	// Completed records are skipped when resuming
+	errOut.Reset()
+	if err := runBatch(args); err != nil {
+		t.Fatal(err)
+	}
+	if summary := errOut.String(); summary != "0 succeeded, 0 failed, 2 skipped\n" || len(server.Requests()) != 2 {
+		t.Errorf("Unexpected summary %q after %d requests", summary, len(server.Requests()))
+	}
+}
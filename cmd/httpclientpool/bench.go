@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/RootInit/HttpClientPool"
+)
+
+// benchStats summarises the results of a benchmark.
+type benchStats struct {
+	latencies []time.Duration
+	statuses  map[int]int
+	errors    map[string]int
+	elapsed   time.Duration
+}
+
+// runBench load tests a URL through the pool.
+func runBench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	var flags poolFlags
+	flags.register(fs)
+	headers := headerFlag{}
+	requests := fs.Int("n", 100, "total number of requests")
+	workers := fs.Int("c", 0, "concurrent requests (default number of clients)")
+	duration := fs.Duration("duration", 0, "run for a duration instead of -n requests")
+	method := fs.String("X", "GET", "request `method`")
+	fs.Var(headers, "H", "request header \"Name: value\", may be repeated")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: httpclientpool bench [flags] URL")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected a single URL")
+	}
+	pool, err := flags.pool()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+	reqData := HttpClientPool.RequestData{Type: strings.ToUpper(*method), Url: fs.Arg(0), Headers: headers}
+	input := make(chan HttpClientPool.RequestData)
+	go func() {
+		defer close(input)
+		for i := 0; *duration > 0 || i < *requests; i++ {
+			select {
+			case input <- reqData:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	stats := benchStats{statuses: make(map[int]int), errors: make(map[string]int)}
+	started := time.Now()
+	for result := range pool.Batch(ctx, input, HttpClientPool.BatchOptions{Workers: *workers}) {
+		if result.Err != nil {
+			if ctx.Err() != nil && errors.Is(result.Err, ctx.Err()) {
+				// Cut short by -duration
+				continue
+			}
+			stats.errors[result.Err.Error()]++
+			continue
+		}
+		stats.statuses[result.Response.StatusCode]++
+		stats.latencies = append(stats.latencies, result.Duration)
+	}
+	stats.elapsed = time.Since(started)
+	stats.print(stdout)
+	return nil
+}
+
+// print writes a report of the benchmark.
+func (stats benchStats) print(w io.Writer) {
+	failed := 0
+	for _, count := range stats.errors {
+		failed += count
+	}
+	total := len(stats.latencies) + failed
+	fmt.Fprintf(w, "Requests:   %d in %s (%.1f/s)\n", total, stats.elapsed.Round(time.Millisecond),
+		float64(total)/stats.elapsed.Seconds())
+	if len(stats.latencies) > 0 {
+		sort.Slice(stats.latencies, func(i, j int) bool { return stats.latencies[i] < stats.latencies[j] })
+		percentile := func(p float64) time.Duration {
+			return stats.latencies[int(p*float64(len(stats.latencies)-1))].Round(time.Microsecond)
+		}
+		fmt.Fprintf(w, "Latency:    p50 %s  p90 %s  p99 %s  max %s\n",
+			percentile(0.5), percentile(0.9), percentile(0.99), percentile(1))
+	}
+	codes := make([]int, 0, len(stats.statuses))
+	for code := range stats.statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		fmt.Fprintf(w, "Status %d: %d\n", code, stats.statuses[code])
+	}
+	for message, count := range stats.errors {
+		fmt.Fprintf(w, "Error:      %d x %s\n", count, message)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/RootInit/HttpClientPool/pooltest"
+)
+
+func TestBench(t *testing.T) {
+	server := pooltest.NewScriptedServer(t, pooltest.Response{Status: 200}, pooltest.Response{Status: 503}, pooltest.Response{Status: 200})
+	out, _ := captureOutput(t)
+	if err := runBench([]string{"-n", "10", "-c", "2", server.URL}); err != nil {
+		t.Fatal(err)
+	}
+	report := out.String()
+	for _, line := range []string{"Requests:   10 in ", "Latency:    p50 ", "Status 200: 9\n", "Status 503: 1\n"} {
+		if !strings.Contains(report, line) {
+			t.Errorf("Expected %q in the report\n%s", line, report)
+		}
+	}
+	if requests := len(server.Requests()); requests != 10 {
+		t.Errorf("Expected 10 requests, got %d", requests)
+	}
+}
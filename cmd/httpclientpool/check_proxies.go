@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RootInit/HttpClientPool"
+)
+
+// runCheckProxies tests each proxy of the pool and writes out the working ones.
+func runCheckProxies(args []string) error {
+	fs := flag.NewFlagSet("check-proxies", flag.ExitOnError)
+	var flags poolFlags
+	flags.register(fs)
+	outputPath := fs.String("out", "", "write the working proxies to `file`, one per line")
+	testUrl := fs.String("url", "https://example.com/", "`URL` requested through each proxy")
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout of each check")
+	workers := fs.Int("workers", 16, "proxies checked concurrently")
+	fs.Parse(args)
+	pool, err := flags.pool()
+	if err != nil {
+		return err
+	}
+	clients := pool.Clients
+	if len(clients) == 1 && clients[0].GetProxy() == nil {
+		return errors.New("no proxies given, use -proxies or the config proxies")
+	}
+	working := make([]bool, len(clients))
+	slots := make(chan struct{}, max(*workers, 1))
+	var wg sync.WaitGroup
+	for idx, client := range clients {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(idx int, client *HttpClientPool.Client) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			started := time.Now()
+			response, err := client.QuickRequestContext(context.Background(), HttpClientPool.RequestData{
+				Type:    "GET",
+				Url:     *testUrl,
+				Timeout: *timeout,
+			})
+			if err == nil && response.StatusCode >= 400 {
+				err = errors.New(response.Status)
+			}
+			if err != nil {
+				fmt.Fprintf(stderr, "FAIL %s: %v\n", client.GetProxy(), err)
+				return
+			}
+			working[idx] = true
+			fmt.Fprintf(stderr, "OK   %s %s\n", client.GetProxy(), time.Since(started).Round(time.Millisecond))
+		}(idx, client)
+	}
+	wg.Wait()
+	var good []string
+	for idx, client := range clients {
+		if working[idx] {
+			good = append(good, client.GetProxy().String())
+		}
+	}
+	fmt.Fprintf(stderr, "%d of %d proxies working\n", len(good), len(clients))
+	if *outputPath == "" {
+		for _, proxy := range good {
+			fmt.Fprintln(stdout, proxy)
+		}
+		return nil
+	}
+	return os.WriteFile(*outputPath, []byte(strings.Join(good, "\n")+"\n"), 0644)
+}
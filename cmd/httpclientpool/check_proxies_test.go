@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/RootInit/HttpClientPool/pooltest"
+)
+
+func TestCheckProxies(t *testing.T) {
+	server := pooltest.NewEchoServer(t)
+	working, dead, banned := pooltest.NewHTTPProxy(t), pooltest.NewSOCKS5Proxy(t), pooltest.NewHTTPProxy(t)
+	dead.Fail()
+	banned.Ban()
+	proxiesPath := writeProxies(t, working, dead, banned)
+	out, errOut := captureOutput(t)
+	if err := runCheckProxies([]string{"-proxies", proxiesPath, "-url", server.URL, "-timeout", "5s"}); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != working.URL().String()+"\n" {
+		t.Errorf("Expected only the working proxy, got %q", out)
+	}
+	if summary := errOut.String(); !strings.HasSuffix(summary, "1 of 3 proxies working\n") ||
+		!strings.Contains(summary, "FAIL "+banned.URL().String()+": 403 Forbidden\n") {
+		t.Errorf("Unexpected report %q", summary)
+	}
+	working.AssertUsed(t, server.Host())
+	// The working proxies are written to -out
+	outputPath := filepath.Join(t.TempDir(), "working.txt")
+	if err := runCheckProxies([]string{"-proxies", proxiesPath, "-url", server.URL, "-out", outputPath}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(outputPath); string(data) != working.URL().String()+"\n" {
+		t.Errorf("Unexpected output file %q", data)
+	}
+	// A pool without proxies has nothing to check
+	if err := runCheckProxies(nil); err == nil {
+		t.Error("Expected an error without proxies")
+	}
+}
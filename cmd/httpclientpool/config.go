@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/RootInit/HttpClientPool"
+)
+
+// runConfig validates a config file or prints the config JSON schema.
+func runConfig(args []string) error {
+	fs := flag.NewFlagSet("config", flag.ExitOnError)
+	schema := fs.Bool("schema", false, "print the JSON schema of config files")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: httpclientpool config [-schema] [FILE]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *schema {
+		_, err := fmt.Fprintln(stdout, string(HttpClientPool.ConfigSchema()))
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected a config file")
+	}
+	if _, err := HttpClientPool.LoadConfig(fs.Arg(0)); err != nil {
+		return err
+	}
+	fmt.Fprintf(stderr, "%s is valid\n", fs.Arg(0))
+	return nil
+}
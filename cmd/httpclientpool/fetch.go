@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/RootInit/HttpClientPool"
+)
+
+// runFetch makes a single request and prints the response body.
+func runFetch(args []string) error {
+	fs := flag.NewFlagSet("fetch", flag.ExitOnError)
+	var flags poolFlags
+	flags.register(fs)
+	headers := headerFlag{}
+	method := fs.String("X", "GET", "request `method`")
+	data := fs.String("d", "", "request body, @file to read it from a file")
+	include := fs.Bool("i", false, "print the response status and headers")
+	outputPath := fs.String("o", "", "write the body to `file` instead of stdout")
+	timeout := fs.Duration("timeout", 30*time.Second, "request timeout")
+	fs.Var(headers, "H", "request header \"Name: value\", may be repeated")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: httpclientpool fetch [flags] URL")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected a single URL")
+	}
+	pool, err := flags.pool()
+	if err != nil {
+		return err
+	}
+	reqData := HttpClientPool.RequestData{
+		Type:    strings.ToUpper(*method),
+		Url:     fs.Arg(0),
+		Headers: headers,
+		Timeout: *timeout,
+	}
+	if *data != "" {
+		body := []byte(*data)
+		if strings.HasPrefix(*data, "@") {
+			if body, err = os.ReadFile((*data)[1:]); err != nil {
+				return err
+			}
+		}
+		var reader io.Reader = bytes.NewReader(body)
+		reqData.RawData = &reader
+	}
+	response, err := pool.QuickRequestContext(context.Background(), reqData)
+	if err != nil {
+		return err
+	}
+	if *include {
+		fmt.Fprintln(stdout, response.Status)
+		for _, name := range sortedNames(response.Headers) {
+			for _, value := range response.Headers[name] {
+				fmt.Fprintf(stdout, "%s: %s\n", name, value)
+			}
+		}
+		fmt.Fprintln(stdout)
+	}
+	if *outputPath != "" {
+		return os.WriteFile(*outputPath, response.Body, 0644)
+	}
+	_, err = stdout.Write(response.Body)
+	return err
+}
+
+// sortedNames returns the keys of a header map in sorted order.
+func sortedNames(headers map[string][]string) []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/RootInit/HttpClientPool/pooltest"
+)
+
+func TestFetch(t *testing.T) {
+	server := pooltest.NewEchoServer(t)
+	proxy := pooltest.NewHTTPProxy(t)
+	out, _ := captureOutput(t)
+	err := runFetch([]string{"-proxies", writeProxies(t, proxy), "-X", "post", "-d", "body", "-H", "X-Test: 1", "-i", server.URL + "/fetch"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, body, _ := strings.Cut(out.String(), "\n")
+	if status != "200 OK" || !strings.Contains(body, "Content-Type: application/json\n") {
+		t.Fatalf("Unexpected status and headers %q", out)
+	}
+	var echo pooltest.Echo
+	if err := json.Unmarshal([]byte(body[strings.Index(body, "\n\n")+2:]), &echo); err != nil {
+		t.Fatal(err)
+	}
+	if echo.Method != "POST" || echo.URL != "/fetch" || echo.Body != "body" || echo.Headers["X-Test"][0] != "1" {
+		t.Errorf("Unexpected request %+v", echo)
+	}
+	proxy.AssertUsed(t, server.Host())
+	// The body is written to -o without the headers
+	out.Reset()
+	path := filepath.Join(t.TempDir(), "body.json")
+	if err := runFetch([]string{"-o", path, server.URL + "/file"}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if out.Len() != 0 || !strings.HasPrefix(string(data), `{"method":"GET","url":"/file"`) {
+		t.Errorf("Unexpected output %q and file %q", out, data)
+	}
+	if err := runFetch([]string{"-d", "@missing.txt", server.URL}); !os.IsNotExist(err) {
+		t.Errorf("Expected a missing body file error, got %v", err)
+	}
+}
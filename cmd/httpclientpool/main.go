@@ -0,0 +1,157 @@
+// Command httpclientpool runs requests through a HttpClientPool.ClientPool.
+//
+// Usage:
+//
+//	httpclientpool <command> [flags]
+//
+// Commands:
+//   - fetch: Make a single request and print the response body.
+//   - batch: Run the requests of a JSONL file, writing the responses as JSONL.
+//   - check-proxies: Test a list of proxies and write out the working ones.
+//   - bench: Load test a URL.
+//   - serve: Run a local HTTP proxy which forwards requests through the pool.
+//   - config: Validate a config file or print the config JSON schema.
+//
+// Every command accepts the pool flags -config, -client-rate, -pool-rate and
+// -proxies. The config file is described by HttpClientPool.Config. Rates are given as requests per unit of time, e.g. 25/s or 500/min.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/RootInit/HttpClientPool"
+	"github.com/RootInit/HttpClientPool/Utils"
+)
+
+// command is a subcommand of httpclientpool.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+// stdout and stderr are where commands write their output.
+var (
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
+)
+
+var commands = []command{
+	{"fetch", "Make a single request and print the response body", runFetch},
+	{"batch", "Run the requests of a JSONL file, writing the responses as JSONL", runBatch},
+	{"check-proxies", "Test a list of proxies and write out the working ones", runCheckProxies},
+	{"bench", "Load test a URL", runBench},
+	{"serve", "Run a local HTTP proxy which forwards requests through the pool", runServe},
+	{"config", "Validate a config file or print the config JSON schema", runConfig},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	for _, cmd := range commands {
+		if cmd.name == os.Args[1] {
+			if err := cmd.run(os.Args[2:]); err != nil {
+				fmt.Fprintf(stderr, "httpclientpool %s: %v\n", cmd.name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	usage()
+	os.Exit(2)
+}
+
+// usage prints the list of commands.
+func usage() {
+	fmt.Fprintln(stderr, "Usage: httpclientpool <command> [flags]\n\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(stderr, "  %-14s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(stderr, "\nRun 'httpclientpool <command> -h' for the flags of a command.")
+}
+
+// poolFlags are the flags shared by every command to configure the pool.
+type poolFlags struct {
+	configPath  string
+	clientRate  string
+	poolRate    string
+	proxiesPath string
+}
+
+// register adds the pool flags to a flag set.
+func (flags *poolFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&flags.configPath, "config", "", "pool config `file`, overridden by HTTPCLIENTPOOL_* variables")
+	fs.StringVar(&flags.clientRate, "client-rate", "", "request `rate` of each client, e.g. 2/s (overrides config)")
+	fs.StringVar(&flags.poolRate, "pool-rate", "", "request `rate` of the whole pool, e.g. 25/s (overrides config)")
+	fs.StringVar(&flags.proxiesPath, "proxies", "", "`file` of proxy URLs, one per line (overrides config)")
+}
+
+// config returns the pool config described by the flags.
+//
+// HTTPCLIENTPOOL_* variables override the config file, or the defaults without one.
+func (flags *poolFlags) config() (HttpClientPool.Config, error) {
+	var config HttpClientPool.Config
+	if flags.configPath != "" {
+		var err error
+		if config, err = HttpClientPool.LoadConfig(flags.configPath); err != nil {
+			return config, err
+		}
+	} else if err := config.ApplyEnv(HttpClientPool.ConfigEnvPrefix); err != nil {
+		return config, err
+	}
+	if flags.clientRate != "" {
+		config.ClientRate = flags.clientRate
+	}
+	if flags.poolRate != "" {
+		config.PoolRate = flags.poolRate
+	}
+	if flags.proxiesPath != "" {
+		proxies, err := Utils.UrlsFromFile(flags.proxiesPath)
+		if err != nil {
+			return config, err
+		}
+		config.Proxies = nil
+		for _, proxy := range proxies {
+			config.Proxies = append(config.Proxies, proxy.String())
+		}
+	}
+	return config, nil
+}
+
+// pool creates the pool described by the flags.
+func (flags *poolFlags) pool() (*HttpClientPool.ClientPool, error) {
+	config, err := flags.config()
+	if err != nil {
+		return nil, err
+	}
+	return HttpClientPool.NewClientPoolFromConfig(config)
+}
+
+// headerFlag collects repeated "Name: value" flags.
+type headerFlag map[string][]string
+
+func (headers headerFlag) String() string {
+	var pairs []string
+	for name, values := range headers {
+		for _, value := range values {
+			pairs = append(pairs, name+": "+value)
+		}
+	}
+	return strings.Join(pairs, ", ")
+}
+
+func (headers headerFlag) Set(value string) error {
+	name, headerValue, found := strings.Cut(value, ":")
+	if !found {
+		return fmt.Errorf("expected \"Name: value\", got %q", value)
+	}
+	name = strings.TrimSpace(name)
+	headers[name] = append(headers[name], strings.TrimSpace(headerValue))
+	return nil
+}
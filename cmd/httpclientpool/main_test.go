@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/RootInit/HttpClientPool/pooltest"
+)
+
+// syncBuffer is a bytes.Buffer safe for the concurrent writes of check-proxies.
+type syncBuffer struct {
+	buffer bytes.Buffer
+	mu     sync.Mutex
+}
+
+func (buffer *syncBuffer) Write(p []byte) (int, error) {
+	buffer.mu.Lock()
+	defer buffer.mu.Unlock()
+	return buffer.buffer.Write(p)
+}
+
+func (buffer *syncBuffer) String() string {
+	buffer.mu.Lock()
+	defer buffer.mu.Unlock()
+	return buffer.buffer.String()
+}
+
+func (buffer *syncBuffer) Len() int {
+	buffer.mu.Lock()
+	defer buffer.mu.Unlock()
+	return buffer.buffer.Len()
+}
+
+func (buffer *syncBuffer) Reset() {
+	buffer.mu.Lock()
+	defer buffer.mu.Unlock()
+	buffer.buffer.Reset()
+}
+
+// captureOutput replaces stdout and stderr for the rest of the test.
+func captureOutput(t *testing.T) (*syncBuffer, *syncBuffer) {
+	out, errOut := &syncBuffer{}, &syncBuffer{}
+	stdout, stderr = out, errOut
+	t.Cleanup(func() { stdout, stderr = os.Stdout, os.Stderr })
+	return out, errOut
+}
+
+// writeProxies writes the URLs of proxies to a file for the -proxies flag.
+func writeProxies(t *testing.T, proxies ...*pooltest.Proxy) string {
+	var lines []string
+	for _, proxy := range proxies {
+		lines = append(lines, proxy.URL().String())
+	}
+	path := filepath.Join(t.TempDir(), "proxies.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Environment overrides apply without a config file and flags override them
+func TestPoolFlagsEnv(t *testing.T) {
+	t.Setenv("HTTPCLIENTPOOL_POOL_RATE", "5/s")
+	t.Setenv("HTTPCLIENTPOOL_CLIENT_RATE", "1/s")
+	flags := poolFlags{clientRate: "2/s"}
+	config, err := flags.config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.PoolRate != "5/s" || config.ClientRate != "2/s" {
+		t.Errorf("Unexpected rates %q %q", config.PoolRate, config.ClientRate)
+	}
+	t.Setenv("HTTPCLIENTPOOL_RETRY_ATTEMPTS", "many")
+	if _, err := flags.config(); err == nil || !strings.Contains(err.Error(), "HTTPCLIENTPOOL_RETRY_ATTEMPTS") {
+		t.Errorf("Expected an error naming the variable, got %v", err)
+	}
+}
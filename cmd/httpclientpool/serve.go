@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/RootInit/HttpClientPool"
+)
+
+// hopHeaders are the hop-by-hop headers which are not forwarded by a proxy.
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// runServe runs a local HTTP proxy which forwards requests through the pool.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	var flags poolFlags
+	flags.register(fs)
+	listen := fs.String("listen", "127.0.0.1:8080", "`address` to listen on")
+	fs.Parse(args)
+	pool, err := flags.pool()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stderr, "Proxying through %d clients on http://%s\n", len(pool.Clients), *listen)
+	return http.ListenAndServe(*listen, proxyHandler(pool))
+}
+
+// proxyHandler returns a forward proxy handler which sends requests through the pool.
+//
+// Only plain HTTP requests are supported. HTTPS requests tunnelled with CONNECT
+// are refused as the pool could not see or rate limit their contents.
+func proxyHandler(pool *HttpClientPool.ClientPool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodConnect {
+			http.Error(w, "CONNECT is not supported, send plain http:// requests", http.StatusNotImplemented)
+			return
+		}
+		if !r.URL.IsAbs() {
+			http.Error(w, "expected an absolute URL in the request line", http.StatusBadRequest)
+			return
+		}
+		headers := r.Header.Clone()
+		for _, name := range hopHeaders {
+			headers.Del(name)
+		}
+		reqData := HttpClientPool.RequestData{
+			Type:    r.Method,
+			Url:     r.URL.String(),
+			Headers: headers,
+		}
+		if r.ContentLength != 0 {
+			var body io.Reader = r.Body
+			reqData.RawData = &body
+		}
+		response, err := pool.QuickRequestContext(r.Context(), reqData)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		for name, values := range response.Headers {
+			w.Header()[name] = values
+		}
+		for _, name := range hopHeaders {
+			w.Header().Del(name)
+		}
+		w.Header().Del("Content-Length")
+		w.WriteHeader(response.StatusCode)
+		w.Write(response.Body)
+	})
+}
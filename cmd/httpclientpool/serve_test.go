@@ -0,0 +1,53 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/RootInit/HttpClientPool"
+)
+
+func TestProxyHandler(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Proxy-Connection") != "" {
+			t.Error("Hop-by-hop header was forwarded")
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.WriteHeader(http.StatusCreated)
+		w.Write(append([]byte(r.URL.Query().Get("q")+":"), body...))
+	}))
+	defer upstream.Close()
+	pool, err := HttpClientPool.NewClientPoolFromConfig(HttpClientPool.Config{ClientRate: "1000/s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := httptest.NewServer(proxyHandler(pool))
+	defer proxy.Close()
+	proxyUrl, _ := url.Parse(proxy.URL)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyUrl)}}
+	req, _ := http.NewRequest("POST", upstream.URL+"/?q=hello", strings.NewReader("body"))
+	req.Header.Set("Proxy-Connection", "keep-alive")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get("X-Method") != "POST" || string(body) != "hello:body" {
+		t.Fatalf("Unexpected response %d %v %q", resp.StatusCode, resp.Header, body)
+	}
+	// Relative URLs are not proxied
+	resp, err = http.Get(proxy.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected 400 for a relative URL, got %d", resp.StatusCode)
+	}
+}
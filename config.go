@@ -0,0 +1,610 @@
+package HttpClientPool
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/RootInit/HttpClientPool/Utils"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ConfigEnvPrefix is the prefix of the environment variables read by LoadConfig.
+const ConfigEnvPrefix = "HTTPCLIENTPOOL_"
+
+// Config describes a ClientPool.
+//
+// Fields tagged with schema:"rate" accept a rate such as "25/s burst 5" and fields
+// tagged with schema:"duration" a Go duration such as "1.5s".
+type Config struct {
+	// ClientRate is the request rate of each client in a form accepted by
+	// Utils.ParseRate, e.g. "2/s" or "500/min burst 10". Empty for no limit.
+	ClientRate string `json:"client_rate,omitempty" schema:"rate"`
+
+	// PoolRate is the request rate of the whole pool. Empty for no limit.
+	PoolRate string `json:"pool_rate,omitempty" schema:"rate"`
+
+	// Proxies are the proxy URLs, one client is created per proxy. Empty for a
+	// single client without a proxy.
+	Proxies []string `json:"proxies,omitempty"`
+
+	// UserAgents are the user agents with their respective weights.
+	UserAgents map[string]float32 `json:"user_agents,omitempty"`
+
+	// MaxInFlight limits concurrent requests across the pool. 0 for no limit.
+	MaxInFlight int `json:"max_in_flight,omitempty"`
+
+	// ClientMaxInFlight limits concurrent requests per client. Defaults to 1.
+	ClientMaxInFlight int `json:"client_max_in_flight,omitempty"`
+
+	// Timeout limits the time taken by each request. Empty for no timeout.
+	Timeout string `json:"timeout,omitempty" schema:"duration"`
+
+	// Retry retries failed requests. See Retry.
+	Retry RetryConfig `json:"retry,omitempty"`
+
+	// Hosts limits the requests made to each host. See HostLimits.
+	Hosts map[string]HostConfig `json:"hosts,omitempty"`
+
+	// Tenants configures the tenants sharing the pool. See ClientPool.SetTenant.
+	Tenants map[string]TenantConfig `json:"tenants,omitempty"`
+
+	// TLS configures the TLS connections of each client.
+	TLS TLSConfig `json:"tls,omitempty"`
+}
+
+// RetryConfig describes a RetryPolicy.
+type RetryConfig struct {
+	// Attempts is the maximum number of retries. 0 disables retries.
+	Attempts int `json:"attempts,omitempty"`
+	// Backoff is the delay before the first retry. Defaults to 1s.
+	Backoff string `json:"backoff,omitempty" schema:"duration"`
+	// MaxBackoff caps the delay between retries.
+	MaxBackoff string `json:"max_backoff,omitempty" schema:"duration"`
+	// Statuses are the status codes retried. Defaults to 429, 502, 503 and 504.
+	Statuses []int `json:"statuses,omitempty"`
+}
+
+// HostConfig describes a HostLimit.
+type HostConfig struct {
+	// Rate is the request rate to the host.
+	Rate string `json:"rate,omitempty" schema:"rate"`
+	// MaxInFlight limits concurrent requests to the host. 0 for no limit.
+	MaxInFlight int `json:"max_in_flight,omitempty"`
+}
+
+// TLSConfig describes the TLS options of each client.
+type TLSConfig struct {
+	// Profile is the TLS ClientHello profile: chrome, firefox, safari or match
+	// to match each client's user agent. Empty for the Go defaults.
+	Profile string `json:"profile,omitempty" schema:"enum=chrome|firefox|safari|match"`
+	// MinVersion is the minimum TLS version: 1.0, 1.1, 1.2 or 1.3.
+	MinVersion string `json:"min_version,omitempty" schema:"enum=1.0|1.1|1.2|1.3"`
+	// CAFile is a PEM file of root certificates used instead of the system roots.
+	CAFile string `json:"ca_file,omitempty"`
+	// InsecureSkipVerify disables certificate verification.
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+}
+
+// ConfigError describes an invalid Config field.
+type ConfigError struct {
+	// Field is the path of the field, e.g. hosts[api.example.com].rate.
+	Field string
+	// Err is the reason the field is invalid.
+	Err error
+}
+
+// Error implements the error interface.
+func (err *ConfigError) Error() string {
+	if err.Field == "" {
+		return fmt.Sprintf("invalid config: %v", err.Err)
+	}
+	return fmt.Sprintf("invalid config %s: %v", err.Field, err.Err)
+}
+
+// Unwrap returns the underlying reason.
+func (err *ConfigError) Unwrap() error {
+	return err.Err
+}
+
+// ConfigDecoder decodes a config file into v.
+type ConfigDecoder func(data []byte, v any) error
+
+var (
+	configDecoders = map[string]ConfigDecoder{
+		".json": decodeJSONConfig,
+		".yaml": decodeYAMLConfig,
+		".yml":  decodeYAMLConfig,
+		".toml": decodeTOMLConfig,
+	}
+	configDecodersMu sync.RWMutex
+)
+
+// RegisterConfigDecoder registers the decoder used by LoadConfig for a file extension.
+//
+// JSON, YAML and TOML are supported by default. Decoders must use the json
+// field names of Config and reject unknown fields. Unmarshal functions such as
+// yaml.Unmarshal ignore json tags, so decode other formats into a generic map
+// and pass it to the JSON decoder, as the YAML and TOML decoders do.
+//
+// Parameters:
+//   - extension (string): The file extension including the dot (e.g., .yaml).
+//   - decoder (ConfigDecoder): The decoder. Use nil to remove a decoder.
+func RegisterConfigDecoder(extension string, decoder ConfigDecoder) {
+	configDecodersMu.Lock()
+	defer configDecodersMu.Unlock()
+	extension = strings.ToLower(extension)
+	if decoder == nil {
+		delete(configDecoders, extension)
+		return
+	}
+	configDecoders[extension] = decoder
+}
+
+// LoadConfig reads a config file, applies environment overrides and validates it.
+//
+// The format is chosen by the file extension. See RegisterConfigDecoder.
+// Environment variables are applied with ApplyEnv using ConfigEnvPrefix.
+//
+// Parameters:
+//   - path (string): The path of the config file.
+//
+// Returns:
+//   - Config: The loaded config.
+//   - error: An error reading the file, or ConfigErrors describing invalid fields.
+func LoadConfig(path string) (Config, error) {
+	var config Config
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	configDecodersMu.RLock()
+	decoder, exists := configDecoders[strings.ToLower(filepath.Ext(path))]
+	configDecodersMu.RUnlock()
+	if !exists {
+		return config, fmt.Errorf("%s: no config decoder registered for %q", path, filepath.Ext(path))
+	}
+	if err := decoder(data, &config); err != nil {
+		return config, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := config.ApplyEnv(ConfigEnvPrefix); err != nil {
+		return config, err
+	}
+	if err := config.Validate(); err != nil {
+		return config, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// decodeYAMLConfig decodes a YAML config through decodeJSONConfig so the json
+// field names are used and unknown fields are rejected.
+func decodeYAMLConfig(data []byte, v any) error {
+	var fields map[string]any
+	if err := yaml.Unmarshal(data, &fields); err != nil {
+		return &ConfigError{Err: err}
+	}
+	return decodeConfigFields(fields, v)
+}
+
+// decodeTOMLConfig decodes a TOML config through decodeJSONConfig so the json
+// field names are used and unknown fields are rejected.
+func decodeTOMLConfig(data []byte, v any) error {
+	var fields map[string]any
+	if err := toml.Unmarshal(data, &fields); err != nil {
+		var decodeErr *toml.DecodeError
+		if errors.As(err, &decodeErr) {
+			line, _ := decodeErr.Position()
+			return &ConfigError{Field: fmt.Sprintf("line %d", line), Err: err}
+		}
+		return &ConfigError{Err: err}
+	}
+	return decodeConfigFields(fields, v)
+}
+
+// decodeConfigFields re-encodes the fields of a decoded config as JSON and
+// decodes them with decodeJSONConfig.
+func decodeConfigFields(fields map[string]any, v any) error {
+	if fields == nil {
+		fields = map[string]any{}
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return &ConfigError{Err: err}
+	}
+	return decodeJSONConfig(data, v)
+}
+
+// decodeJSONConfig decodes a JSON config, rejecting unknown fields.
+func decodeJSONConfig(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(v)
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &syntaxErr):
+		line := bytes.Count(data[:syntaxErr.Offset], []byte("\n")) + 1
+		return &ConfigError{Field: fmt.Sprintf("line %d", line), Err: err}
+	case errors.As(err, &typeErr):
+		return &ConfigError{Field: typeErr.Field, Err: fmt.Errorf("expected %s, got %s", typeErr.Type, typeErr.Value)}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return &ConfigError{Field: field, Err: errors.New("unknown field")}
+	}
+	return &ConfigError{Err: err}
+}
+
+// ApplyEnv overrides config fields from environment variables.
+//
+// Variable names are the prefix followed by the upper case json field path,
+// e.g. HTTPCLIENTPOOL_CLIENT_RATE or HTTPCLIENTPOOL_RETRY_ATTEMPTS. Lists are
+// comma separated. Map fields cannot be set from the environment.
+//
+// Parameters:
+//   - prefix (string): The variable name prefix (e.g., ConfigEnvPrefix).
+//
+// Returns:
+//   - error: ConfigErrors describing variables which could not be parsed.
+func (config *Config) ApplyEnv(prefix string) error {
+	var errs []error
+	walkConfigFields(reflect.ValueOf(config).Elem(), "", func(path string, value reflect.Value) {
+		name := prefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+		envValue, exists := os.LookupEnv(name)
+		if !exists {
+			return
+		}
+		if err := setConfigValue(value, envValue); err != nil {
+			errs = append(errs, &ConfigError{Field: path + " (" + name + ")", Err: err})
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// walkConfigFields calls visit with the json path of each non-struct field.
+func walkConfigFields(value reflect.Value, path string, visit func(path string, value reflect.Value)) {
+	for idx := 0; idx < value.NumField(); idx++ {
+		name := configFieldName(value.Type().Field(idx))
+		if name == "" {
+			continue
+		}
+		if path != "" {
+			name = path + "." + name
+		}
+		field := value.Field(idx)
+		if field.Kind() == reflect.Struct {
+			walkConfigFields(field, name, visit)
+		} else {
+			visit(name, field)
+		}
+	}
+}
+
+// configFieldName returns the json name of a struct field, empty if it is not encoded.
+func configFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" || !field.IsExported() {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// setConfigValue parses a string into a config field.
+func setConfigValue(value reflect.Value, text string) error {
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(text)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(strings.TrimSpace(text))
+		if err != nil {
+			return err
+		}
+		value.SetBool(parsed)
+	case reflect.Int:
+		parsed, err := strconv.Atoi(strings.TrimSpace(text))
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(parsed))
+	case reflect.Slice:
+		var parts []string
+		if strings.TrimSpace(text) != "" {
+			parts = strings.Split(text, ",")
+		}
+		slice := reflect.MakeSlice(value.Type(), len(parts), len(parts))
+		for idx, part := range parts {
+			if err := setConfigValue(slice.Index(idx), strings.TrimSpace(part)); err != nil {
+				return err
+			}
+		}
+		value.Set(slice)
+	default:
+		return fmt.Errorf("cannot be set from the environment")
+	}
+	return nil
+}
+
+// Validate checks the config for invalid values.
+//
+// Returns:
+//   - error: The ConfigErrors found joined together, nil if the config is valid.
+func (config Config) Validate() error {
+	var errs []error
+	invalid := func(field string, err error) {
+		errs = append(errs, &ConfigError{Field: field, Err: err})
+	}
+	checkRate := func(field, rate string) {
+		if _, err := parseRate(rate); err != nil {
+			invalid(field, err)
+		}
+	}
+	checkDuration := func(field, duration string) {
+		if duration == "" {
+			return
+		}
+		if parsed, err := time.ParseDuration(duration); err != nil {
+			invalid(field, err)
+		} else if parsed < 0 {
+			invalid(field, errors.New("must not be negative"))
+		}
+	}
+	checkMin := func(field string, value, min int) {
+		if value < min {
+			invalid(field, fmt.Errorf("must be at least %d", min))
+		}
+	}
+	checkRate("client_rate", config.ClientRate)
+	checkRate("pool_rate", config.PoolRate)
+	for idx, proxy := range config.Proxies {
+		field := fmt.Sprintf("proxies[%d]", idx)
+		if proxyUrl, err := url.Parse(proxy); err != nil {
+			invalid(field, err)
+		} else if proxyUrl.Scheme != "http" && proxyUrl.Scheme != "https" && proxyUrl.Scheme != "socks5" && proxyUrl.Scheme != "socks5h" {
+			invalid(field, fmt.Errorf("unsupported proxy scheme %q", proxyUrl.Scheme))
+		} else if proxyUrl.Host == "" {
+			invalid(field, errors.New("missing host"))
+		}
+	}
+	for _, userAgent := range sortedKeys(config.UserAgents) {
+		if config.UserAgents[userAgent] < 0 {
+			invalid(fmt.Sprintf("user_agents[%s]", userAgent), errors.New("weight must not be negative"))
+		}
+	}
+	checkMin("max_in_flight", config.MaxInFlight, 0)
+	checkMin("client_max_in_flight", config.ClientMaxInFlight, 0)
+	checkDuration("timeout", config.Timeout)
+	checkMin("retry.attempts", config.Retry.Attempts, 0)
+	checkDuration("retry.backoff", config.Retry.Backoff)
+	checkDuration("retry.max_backoff", config.Retry.MaxBackoff)
+	for idx, status := range config.Retry.Statuses {
+		if status < 100 || status > 599 {
+			invalid(fmt.Sprintf("retry.statuses[%d]", idx), fmt.Errorf("invalid status code %d", status))
+		}
+	}
+	for _, host := range sortedKeys(config.Hosts) {
+		field := fmt.Sprintf("hosts[%s]", host)
+		if host == "" || strings.ContainsAny(host, "/:") {
+			invalid(field, errors.New("expected a hostname without scheme or port"))
+		}
+		checkRate(field+".rate", config.Hosts[host].Rate)
+		checkMin(field+".max_in_flight", config.Hosts[host].MaxInFlight, 0)
+	}
+	for _, tenant := range sortedKeys(config.Tenants) {
+		field := fmt.Sprintf("tenants[%s]", tenant)
+		if config.Tenants[tenant].Weight < 0 {
+			invalid(field+".weight", errors.New("must not be negative"))
+		}
+		checkMin(field+".per_minute", config.Tenants[tenant].PerMinute, 0)
+		checkMin(field+".per_day", config.Tenants[tenant].PerDay, 0)
+	}
+	if _, err := tlsProfileByName(config.TLS.Profile); err != nil {
+		invalid("tls.profile", err)
+	}
+	if _, err := tlsVersionByName(config.TLS.MinVersion); err != nil {
+		invalid("tls.min_version", err)
+	}
+	if config.TLS.CAFile != "" {
+		if _, err := loadCertPool(config.TLS.CAFile); err != nil {
+			invalid("tls.ca_file", err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NewClientPoolFromConfig creates a pool of HTTP clients described by a Config.
+//
+// Parameters:
+//   - config (Config): The pool configuration.
+//
+// Returns:
+//   - *ClientPool: A pointer to the initialized client pool.
+//   - error: ConfigErrors describing invalid fields, if any.
+func NewClientPoolFromConfig(config Config) (*ClientPool, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	// Values have been validated
+	clientRate, _ := parseRate(config.ClientRate)
+	poolRate, _ := parseRate(config.PoolRate)
+	var proxies []*url.URL
+	for _, proxy := range config.Proxies {
+		proxyUrl, _ := url.Parse(proxy)
+		proxies = append(proxies, proxyUrl)
+	}
+	pool := NewClientPool(clientRate.Interval(), poolRate.Interval(), proxies, config.UserAgents)
+	pool.SetPoolRate(poolRate)
+	pool.SetClientRate(clientRate)
+	pool.SetMaxInFlight(config.MaxInFlight)
+	if config.ClientMaxInFlight != 0 {
+		pool.SetClientMaxInFlight(config.ClientMaxInFlight)
+	}
+	timeout, _ := time.ParseDuration(config.Timeout)
+	for _, client := range pool.Clients {
+		client.Timeout = timeout
+	}
+	// TLS
+	if config.TLS.Profile == "match" {
+		pool.MatchTLSProfiles()
+	} else if profile, _ := tlsProfileByName(config.TLS.Profile); profile != nil {
+		pool.SetTLSProfile(profile)
+	}
+	if config.TLS != (TLSConfig{Profile: config.TLS.Profile}) {
+		minVersion, _ := tlsVersionByName(config.TLS.MinVersion)
+		var rootCAs *x509.CertPool
+		if config.TLS.CAFile != "" {
+			rootCAs, _ = loadCertPool(config.TLS.CAFile)
+		}
+		for _, client := range pool.Clients {
+			client.updateTLSConfig(func(tlsConfig *tls.Config) {
+				if minVersion != 0 {
+					tlsConfig.MinVersion = minVersion
+				}
+				if rootCAs != nil {
+					tlsConfig.RootCAs = rootCAs
+				}
+				tlsConfig.InsecureSkipVerify = config.TLS.InsecureSkipVerify
+			})
+		}
+	}
+	// Middleware
+	if config.Retry.Attempts > 0 {
+		policy := RetryPolicy{Attempts: config.Retry.Attempts, Backoff: time.Second, Statuses: config.Retry.Statuses}
+		if config.Retry.Backoff != "" {
+			policy.Backoff, _ = time.ParseDuration(config.Retry.Backoff)
+		}
+		policy.MaxBackoff, _ = time.ParseDuration(config.Retry.MaxBackoff)
+		pool.Use(Retry(policy))
+	}
+	if len(config.Hosts) > 0 {
+		limits := make(map[string]HostLimit, len(config.Hosts))
+		for host, hostConfig := range config.Hosts {
+			rate, _ := parseRate(hostConfig.Rate)
+			limits[host] = HostLimit{Delay: rate.Interval(), Burst: rate.Burst, MaxInFlight: hostConfig.MaxInFlight}
+		}
+		pool.Use(HostLimits(limits))
+	}
+	for name, tenant := range config.Tenants {
+		pool.SetTenant(name, tenant)
+	}
+	return &pool, nil
+}
+
+// ConfigSchema returns a JSON schema describing Config files.
+//
+// Returns:
+//   - []byte: The indented JSON schema.
+func ConfigSchema() []byte {
+	schema := configTypeSchema(reflect.TypeOf(Config{}), "")
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "HttpClientPool config"
+	data, _ := json.MarshalIndent(schema, "", "  ")
+	return data
+}
+
+// configTypeSchema returns the JSON schema of a config type.
+func configTypeSchema(typ reflect.Type, tag string) map[string]any {
+	schema := map[string]any{}
+	switch typ.Kind() {
+	case reflect.Struct:
+		properties := map[string]any{}
+		for idx := 0; idx < typ.NumField(); idx++ {
+			field := typ.Field(idx)
+			if name := configFieldName(field); name != "" {
+				properties[name] = configTypeSchema(field.Type, field.Tag.Get("schema"))
+			}
+		}
+		schema["type"] = "object"
+		schema["properties"] = properties
+		schema["additionalProperties"] = false
+	case reflect.Map:
+		schema["type"] = "object"
+		schema["additionalProperties"] = configTypeSchema(typ.Elem(), "")
+	case reflect.Slice:
+		schema["type"] = "array"
+		schema["items"] = configTypeSchema(typ.Elem(), "")
+	case reflect.Int:
+		schema["type"] = "integer"
+		schema["minimum"] = 0
+	case reflect.Float32, reflect.Float64:
+		schema["type"] = "number"
+		schema["minimum"] = 0
+	case reflect.Bool:
+		schema["type"] = "boolean"
+	case reflect.String:
+		schema["type"] = "string"
+		switch {
+		case tag == "rate":
+			schema["description"] = "Requests per period such as 25/s, 500/min or 1 per 2s, optionally followed by burst <n>"
+		case tag == "duration":
+			schema["pattern"] = `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+		case strings.HasPrefix(tag, "enum="):
+			schema["enum"] = append([]string{""}, strings.Split(strings.TrimPrefix(tag, "enum="), "|")...)
+		}
+	}
+	return schema
+}
+
+// tlsProfileByName returns the built-in TLSProfile with a name.
+func tlsProfileByName(name string) (*TLSProfile, error) {
+	for _, profile := range []*TLSProfile{ChromeTLSProfile, FirefoxTLSProfile, SafariTLSProfile} {
+		if profile.Name == name {
+			return profile, nil
+		}
+	}
+	if name == "" || name == "match" {
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown TLS profile %q", name)
+}
+
+// tlsVersionByName returns the TLS version constant for a version such as "1.2".
+func tlsVersionByName(name string) (uint16, error) {
+	versions := map[string]uint16{
+		"":    0,
+		"1.0": tls.VersionTLS10,
+		"1.1": tls.VersionTLS11,
+		"1.2": tls.VersionTLS12,
+		"1.3": tls.VersionTLS13,
+	}
+	version, exists := versions[name]
+	if !exists {
+		return 0, fmt.Errorf("unknown TLS version %q", name)
+	}
+	return version, nil
+}
+
+// loadCertPool reads the PEM certificates of a file into a CertPool.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificates in %s", path)
+	}
+	return pool, nil
+}
+
+// parseRate parses an optional rate with Utils.ParseRate.
+func parseRate(rate string) (Utils.Rate, error) {
+	if strings.TrimSpace(rate) == "" {
+		return Utils.Rate{}, nil
+	}
+	return Utils.ParseRate(rate)
+}
@@ -0,0 +1,230 @@
+package HttpClientPool
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/RootInit/HttpClientPool/Utils"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pool.json")
+	os.WriteFile(path, []byte(`{
+		"client_rate": "10/s",
+		"pool_rate": "100/min",
+		"proxies": ["http://127.0.0.1:3128", "socks5://127.0.0.1:1080", "socks5h://127.0.0.1:1080"],
+		"timeout": "5s",
+		"retry": {"attempts": 2, "backoff": "1ms"},
+		"hosts": {"*.example.com": {"rate": "1/s", "max_in_flight": 2}},
+		"tenants": {"batch": {"weight": 0.5, "per_day": 1000}},
+		"tls": {"profile": "chrome", "min_version": "1.2"}
+	}`), 0644)
+	t.Setenv("HTTPCLIENTPOOL_POOL_RATE", "5/s")
+	t.Setenv("HTTPCLIENTPOOL_RETRY_STATUSES", "429, 503")
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.PoolRate != "5/s" || len(config.Retry.Statuses) != 2 || config.Retry.Statuses[1] != 503 {
+		t.Fatalf("Environment overrides not applied: %+v", config)
+	}
+	if config.Tenants["batch"].PerDay != 1000 || config.Hosts["*.example.com"].MaxInFlight != 2 {
+		t.Fatalf("Unexpected config %+v", config)
+	}
+	pool, err := NewClientPoolFromConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pool.Clients) != 3 || pool.GetPoolDelay() != 200*time.Millisecond {
+		t.Fatalf("Unexpected pool %d clients, pool delay %s", len(pool.Clients), pool.GetPoolDelay())
+	}
+	client := pool.Clients[0]
+	if client.GetDelay() != 100*time.Millisecond || client.Timeout != 5*time.Second || client.GetTLSProfile() != ChromeTLSProfile {
+		t.Fatalf("Unexpected client delay %s, timeout %s", client.GetDelay(), client.Timeout)
+	}
+	if pool.GetTenantUsage()["batch"].Config.Weight != 0.5 {
+		t.Fatal("Tenant not configured")
+	}
+	// Bad environment values point at the variable
+	t.Setenv("HTTPCLIENTPOOL_RETRY_ATTEMPTS", "many")
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "HTTPCLIENTPOOL_RETRY_ATTEMPTS") {
+		t.Fatalf("Expected an error naming the variable, got %v", err)
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		config string
+		fields []string
+	}{
+		{`{"client_rate": "fast"}`, []string{"client_rate"}},
+		{`{"retry": {"attempts": "3"}}`, []string{"retry.attempts"}},
+		{`{"tls": {"profile": "edge", "min_version": "2.0"}}`, []string{"tls.profile", "tls.min_version"}},
+		{`{"hosts": {"api.com": {"rate": "1/fortnight"}}, "proxies": ["ftp://x"]}`, []string{"proxies[0]", "hosts[api.com].rate"}},
+		{`{"unknown": 1}`, []string{"unknown"}},
+		{"{\n\"timeout\": \"1s\",\n}", []string{"line 3"}},
+	}
+	for _, test := range tests {
+		path := filepath.Join(dir, "pool.json")
+		os.WriteFile(path, []byte(test.config), 0644)
+		_, err := LoadConfig(path)
+		var configErr *ConfigError
+		if !errors.As(err, &configErr) {
+			t.Fatalf("Expected a ConfigError for %s, got %v", test.config, err)
+		}
+		for _, field := range test.fields {
+			if !strings.Contains(err.Error(), "invalid config "+field+":") {
+				t.Errorf("Expected an error for %s in %q", field, err)
+			}
+		}
+	}
+	// Unregistered formats
+	if _, err := LoadConfig(filepath.Join(dir, "pool.conf")); err == nil {
+		t.Fatal("Expected an error reading a missing file")
+	}
+	os.WriteFile(filepath.Join(dir, "pool.conf"), []byte("client_rate: 1/s"), 0644)
+	if _, err := LoadConfig(filepath.Join(dir, "pool.conf")); err == nil || !strings.Contains(err.Error(), "no config decoder") {
+		t.Fatalf("Expected an unregistered format error, got %v", err)
+	}
+	RegisterConfigDecoder(".conf", func(data []byte, v any) error {
+		name, value, _ := strings.Cut(string(data), ": ")
+		return json.Unmarshal([]byte(`{"`+name+`": "`+value+`"}`), v)
+	})
+	defer RegisterConfigDecoder(".conf", nil)
+	if config, err := LoadConfig(filepath.Join(dir, "pool.conf")); err != nil || config.ClientRate != "1/s" {
+		t.Fatalf("Registered decoder not used: %+v %v", config, err)
+	}
+}
+
+// YAML and TOML configs use the json field names and reject unknown fields
+func TestLoadConfigFormats(t *testing.T) {
+	dir := t.TempDir()
+	configs := map[string]string{
+		"pool.yaml": `
+client_rate: 10/s
+timeout: 5s
+retry:
+  attempts: 2
+  backoff: 1ms
+hosts:
+  "*.example.com":
+    rate: 1/s
+    max_in_flight: 2
+tenants:
+  batch: {weight: 0.5, per_day: 1000}
+`,
+		"pool.toml": `
+client_rate = "10/s"
+timeout = "5s"
+
+[retry]
+attempts = 2
+backoff = "1ms"
+
+[hosts."*.example.com"]
+rate = "1/s"
+max_in_flight = 2
+
+[tenants.batch]
+weight = 0.5
+per_day = 1000
+`,
+	}
+	for name, data := range configs {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(data), 0644)
+		config, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if config.ClientRate != "10/s" || config.Retry.Attempts != 2 || config.Hosts["*.example.com"].MaxInFlight != 2 || config.Tenants["batch"].PerDay != 1000 {
+			t.Errorf("%s: unexpected config %+v", name, config)
+		}
+	}
+	unknown := map[string]string{
+		"unknown.yml":  "client_rate: 10/s\nclientRate: 5/s\n",
+		"unknown.toml": "client_rate = \"10/s\"\nclientRate = \"5/s\"\n",
+	}
+	for name, data := range unknown {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(data), 0644)
+		_, err := LoadConfig(path)
+		var configErr *ConfigError
+		if !errors.As(err, &configErr) || configErr.Field != "clientRate" {
+			t.Errorf("%s: expected an unknown field error, got %v", name, err)
+		}
+	}
+}
+
+func TestConfigSchema(t *testing.T) {
+	var schema map[string]any
+	if err := json.Unmarshal(ConfigSchema(), &schema); err != nil {
+		t.Fatal(err)
+	}
+	properties := schema["properties"].(map[string]any)
+	tls := properties["tls"].(map[string]any)["properties"].(map[string]any)
+	if tls["profile"].(map[string]any)["enum"] == nil {
+		t.Fatal("Expected tls.profile to be an enum")
+	}
+	if properties["hosts"].(map[string]any)["additionalProperties"].(map[string]any)["type"] != "object" {
+		t.Fatal("Expected hosts to map to objects")
+	}
+}
+
+func TestParseRateConfig(t *testing.T) {
+	for _, rate := range []string{"bogus", "0/s", "5/fortnight"} {
+		if _, err := parseRate(rate); err == nil {
+			t.Errorf("Expected an error for rate %q", rate)
+		}
+		if _, err := NewClientPoolFromConfig(Config{PoolRate: rate}); err == nil {
+			t.Errorf("Expected a pool error for rate %q", rate)
+		}
+	}
+	// Unset rates are unlimited
+	if rate, err := parseRate(" "); err != nil || rate != (Utils.Rate{}) {
+		t.Errorf("Expected no limit, got %v %v", rate, err)
+	}
+}
+
+func TestRetryAndHostLimits(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	clock := NewFakeClock(time.Unix(0, 0))
+	clock.SetAutoAdvance(true)
+	pool := NewClientPool(0, 0, nil, nil)
+	pool.SetClock(clock)
+	pool.Use(Retry(RetryPolicy{Attempts: 3, Backoff: time.Millisecond}))
+	pool.Use(HostLimits(map[string]HostLimit{"127.0.0.1": {Delay: 20 * time.Millisecond}}))
+	start := clock.Now()
+	response, err := pool.QuickRequest(RequestData{Type: "GET", Url: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != http.StatusOK || hits.Load() != 3 {
+		t.Fatalf("Expected success on the third attempt, got %d after %d", response.StatusCode, hits.Load())
+	}
+	// The retry backoffs are shorter than the host delay, so the attempts are 20ms apart
+	if elapsed := clock.Now().Sub(start); elapsed != 40*time.Millisecond {
+		t.Fatalf("Expected the host delay between attempts, took %s", elapsed)
+	}
+	// Validation errors are not retried
+	hits.Store(0)
+	if _, err := pool.QuickRequest(RequestData{Type: "BOGUS", Url: server.URL}); err == nil || hits.Load() != 0 {
+		t.Fatalf("Expected a validation error without requests, got %v", err)
+	}
+}
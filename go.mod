@@ -1,3 +1,18 @@
 module github.com/RootInit/HttpClientPool
 
-go 1.22.0
+go 1.24
+
+require (
+	github.com/pelletier/go-toml/v2 v2.2.4
+	github.com/refraction-networking/utls v1.8.2
+	golang.org/x/net v0.38.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/andybalholm/brotli v1.0.6 // indirect
+	github.com/klauspost/compress v1.17.4 // indirect
+	golang.org/x/crypto v0.36.0 // indirect
+	golang.org/x/sys v0.31.0 // indirect
+	golang.org/x/text v0.23.0 // indirect
+)
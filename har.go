@@ -0,0 +1,718 @@
+package HttpClientPool
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// HAR is an HTTP Archive 1.2 document as opened by browser devtools.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog is the root of a HAR document.
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+// HARCreator identifies the application which created a HAR document.
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is a request and its response.
+type HAREntry struct {
+	StartedDateTime time.Time `json:"startedDateTime"`
+	// Time is the total time of the request in milliseconds.
+	Time     float64     `json:"time"`
+	Request  HARRequest  `json:"request"`
+	Response HARResponse `json:"response"`
+	Cache    struct{}    `json:"cache"`
+	Timings  HARTimings  `json:"timings"`
+	// Proxy is the proxy used for the request without its password.
+	Proxy string `json:"_proxy,omitempty"`
+	// ClientID is the id of the Client which made the request.
+	ClientID uint64 `json:"_clientId,omitempty"`
+	// Error is the error returned instead of a response.
+	Error string `json:"_error,omitempty"`
+}
+
+// HARRequest is a recorded request.
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARResponse is a recorded response.
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARNameValue is a header or query parameter.
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARCookie is a request or response cookie.
+type HARCookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData is a request body.
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	// Params are the fields of a form body when Text is empty.
+	Params []HARNameValue `json:"params,omitempty"`
+}
+
+// HARContent is a response body.
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	// Encoding is "base64" for binary bodies.
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// HARTimings are the durations of the phases of a request in milliseconds.
+// Phases which do not apply, such as dns on a reused connection, are -1.
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	// SSL is included in Connect.
+	SSL float64 `json:"ssl"`
+}
+
+// HARRecorder records requests as HAR entries for inspection in browser devtools.
+//
+// Example:
+//
+//	recorder := HttpClientPool.NewHARRecorder()
+//	pool.RecordHAR(recorder)
+//	pool.Use(recorder.Middleware())
+//	...
+//	recorder.Save("scrape.har")
+type HARRecorder struct {
+	entries []HAREntry
+	// pending are the response bodies of requests not yet recorded
+	pending map[*harBody]struct{}
+	mu      sync.Mutex
+}
+
+// NewHARRecorder creates an empty HARRecorder.
+//
+// Returns:
+//   - *HARRecorder: A pointer to the initialized recorder.
+func NewHARRecorder() *HARRecorder {
+	return &HARRecorder{}
+}
+
+// Entries returns the recorded entries in the order the requests finished.
+//
+// Requests sent through a recording transport are recorded once their response
+// body is fully read or closed. Requests whose response body is still open are
+// recorded by Entries with the part of the body read so far, later reads are
+// not recorded.
+//
+// Returns:
+//   - []HAREntry: The recorded entries.
+func (recorder *HARRecorder) Entries() []HAREntry {
+	recorder.flush()
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	return append([]HAREntry(nil), recorder.entries...)
+}
+
+// HAR returns a HAR document of the recorded entries sorted by start time.
+//
+// Returns:
+//   - HAR: The HAR document.
+func (recorder *HARRecorder) HAR() HAR {
+	entries := recorder.Entries()
+	// Insertion sort as entries finish roughly in start order
+	for idx := 1; idx < len(entries); idx++ {
+		for j := idx; j > 0 && entries[j].StartedDateTime.Before(entries[j-1].StartedDateTime); j-- {
+			entries[j], entries[j-1] = entries[j-1], entries[j]
+		}
+	}
+	if entries == nil {
+		entries = []HAREntry{}
+	}
+	return HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "HttpClientPool", Version: harCreatorVersion()},
+		Entries: entries,
+	}}
+}
+
+// Save writes the HAR document to a file.
+//
+// Parameters:
+//   - path (string): The path of the file, conventionally ending in ".har".
+//
+// Returns:
+//   - error: An error encoding or writing the file.
+func (recorder *HARRecorder) Save(path string) error {
+	data, err := json.MarshalIndent(recorder.HAR(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// add appends an entry.
+func (recorder *HARRecorder) add(entry HAREntry) {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.entries = append(recorder.entries, entry)
+}
+
+// track adds the response body of a request which is recorded once the body is finished.
+func (recorder *HARRecorder) track(body *harBody) {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if recorder.pending == nil {
+		recorder.pending = map[*harBody]struct{}{}
+	}
+	recorder.pending[body] = struct{}{}
+}
+
+// untrack removes a finished response body.
+func (recorder *HARRecorder) untrack(body *harBody) {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	delete(recorder.pending, body)
+}
+
+// flush records the requests whose response bodies are still open.
+func (recorder *HARRecorder) flush() {
+	recorder.mu.Lock()
+	pending := make([]*harBody, 0, len(recorder.pending))
+	for body := range recorder.pending {
+		pending = append(pending, body)
+	}
+	recorder.mu.Unlock()
+	for _, body := range pending {
+		body.finish()
+	}
+}
+
+// harMarkerKey is the context key of the harMarker of a QuickRequest.
+type harMarkerKey struct{}
+
+// harMarker tells the recorder middleware a request was recorded by a transport.
+type harMarker struct {
+	recorder *HARRecorder
+	recorded atomic.Bool
+}
+
+// Middleware returns middleware recording requests made with QuickRequest.
+//
+// Requests sent by a Client recording with the same recorder are recorded by
+// the Client with detailed timings. Other requests, including responses from
+// middleware such as a Cassette, are recorded with only their total time.
+//
+// Returns:
+//   - Middleware: The HAR middleware.
+func (recorder *HARRecorder) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, reqData RequestData) (ResponseData, error) {
+			marker := &harMarker{recorder: recorder}
+			clock := clockFromContext(ctx)
+			start := clock.Now()
+			// Capture the body before next reads it
+			body, contentType, _ := reqData.bodyBytes()
+			response, err := next(context.WithValue(ctx, harMarkerKey{}, marker), reqData)
+			if !marker.recorded.Load() {
+				recorder.add(harEntryFromQuickRequest(reqData, body, contentType, response, err, start, clock.Now().Sub(start)))
+			}
+			return response, err
+		}
+	}
+}
+
+// RecordHAR records every request sent by the client, including requests made
+// with QuickRequest and directly with the embedded http.Client.
+//
+// Parameters:
+//   - recorder (*HARRecorder): The recorder. Use nil to stop recording.
+func (client *Client) RecordHAR(recorder *HARRecorder) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.harRecorder = recorder
+	client.updateTransport()
+}
+
+// RecordHAR records every request sent by each client in the pool.
+//
+// Parameters:
+//   - recorder (*HARRecorder): The recorder.
+func (pool *ClientPool) RecordHAR(recorder *HARRecorder) {
+	for _, client := range pool.getClients() {
+		client.RecordHAR(recorder)
+	}
+}
+
+// Transport wraps a RoundTripper to record the requests it sends with detailed timings.
+//
+// Requests are recorded once their response body is fully read or closed, or
+// when the entries are read with Entries, HAR or Save.
+//
+// Parameters:
+//   - next (http.RoundTripper): The RoundTripper sending requests, http.DefaultTransport if nil.
+//
+// Returns:
+//   - http.RoundTripper: The recording RoundTripper.
+func (recorder *HARRecorder) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &harTransport{recorder: recorder, next: next}
+}
+
+// harTransport records the requests sent by a RoundTripper.
+type harTransport struct {
+	recorder *HARRecorder
+	next     http.RoundTripper
+}
+
+// harTimes are the times of the events of a request.
+type harTimes struct {
+	start, getConn, dnsStart, dnsDone, connectStart, connectDone   time.Time
+	tlsStart, tlsDone, gotConn, wroteRequest, firstByte, responded time.Time
+	clock                                                          Clock
+	mu                                                             sync.Mutex
+}
+
+// RoundTrip implements http.RoundTripper.
+func (transport *harTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	clock := clockFromContext(req.Context())
+	times := &harTimes{start: clock.Now(), clock: clock}
+	req = req.Clone(httptrace.WithClientTrace(req.Context(), times.trace()))
+	requestBody := &harBody{}
+	if req.Body != nil && req.Body != http.NoBody {
+		requestBody.ReadCloser = req.Body
+		req.Body = requestBody
+	}
+	entry := HAREntry{StartedDateTime: times.start}
+	var proxyFunc func(*http.Request) (*url.URL, error)
+	switch next := transport.next.(type) {
+	case *http.Transport:
+		proxyFunc = next.Proxy
+	case *profileTransport:
+		proxyFunc = next.base.Proxy
+	}
+	if proxyFunc != nil {
+		if proxy, err := proxyFunc(req); err == nil && proxy != nil {
+			entry.Proxy = proxy.Redacted()
+		}
+	}
+	if marker, ok := req.Context().Value(harMarkerKey{}).(*harMarker); ok && marker.recorder == transport.recorder {
+		marker.recorded.Store(true)
+	}
+	res, err := transport.next.RoundTrip(req)
+	times.mark(&times.responded)
+	if err != nil {
+		entry.Request = harRequest(req, requestBody.bytes(), "HTTP/1.1")
+		entry.Response = HARResponse{Cookies: []HARCookie{}, Headers: []HARNameValue{}, HeadersSize: -1, BodySize: -1}
+		entry.Error = err.Error()
+		entry.Timings, entry.Time = times.timings(times.responded)
+		transport.recorder.add(entry)
+		return nil, err
+	}
+	responseBody := &harBody{ReadCloser: res.Body}
+	responseBody.done = func() {
+		end := clock.Now()
+		entry.Request = harRequest(req, requestBody.bytes(), res.Proto)
+		entry.Response = harResponse(res, responseBody.bytes())
+		entry.Timings, entry.Time = times.timings(end)
+		transport.recorder.untrack(responseBody)
+		transport.recorder.add(entry)
+	}
+	transport.recorder.track(responseBody)
+	res.Body = responseBody
+	return res, nil
+}
+
+// trace returns a ClientTrace recording the times of the request events.
+func (times *harTimes) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn:              func(string) { times.mark(&times.getConn) },
+		DNSStart:             func(httptrace.DNSStartInfo) { times.mark(&times.dnsStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { times.mark(&times.dnsDone) },
+		ConnectStart:         func(string, string) { times.mark(&times.connectStart) },
+		ConnectDone:          func(string, string, error) { times.mark(&times.connectDone) },
+		TLSHandshakeStart:    func() { times.mark(&times.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { times.mark(&times.tlsDone) },
+		GotConn:              func(httptrace.GotConnInfo) { times.mark(&times.gotConn) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { times.mark(&times.wroteRequest) },
+		GotFirstResponseByte: func() { times.mark(&times.firstByte) },
+	}
+}
+
+// mark sets an event time. Start events keep their first time and others their last.
+func (times *harTimes) mark(event *time.Time) {
+	times.mu.Lock()
+	defer times.mu.Unlock()
+	if event.IsZero() || (event != &times.dnsStart && event != &times.connectStart && event != &times.tlsStart) {
+		*event = times.clock.Now()
+	}
+}
+
+// timings returns the HAR timings and total time of a request which ended at end.
+func (times *harTimes) timings(end time.Time) (HARTimings, float64) {
+	times.mu.Lock()
+	defer times.mu.Unlock()
+	ms := func(from, to time.Time) float64 {
+		if from.IsZero() || to.IsZero() || to.Before(from) {
+			return -1
+		}
+		return float64(to.Sub(from)) / float64(time.Millisecond)
+	}
+	firstByte := times.firstByte
+	if firstByte.IsZero() {
+		firstByte = times.responded
+	}
+	timings := HARTimings{
+		DNS:     ms(times.dnsStart, times.dnsDone),
+		Connect: ms(times.connectStart, later(times.connectDone, times.tlsDone)),
+		SSL:     ms(times.tlsStart, times.tlsDone),
+		Send:    max(ms(times.gotConn, times.wroteRequest), 0),
+		Wait:    max(ms(later(times.wroteRequest, times.start), firstByte), 0),
+		Receive: max(ms(firstByte, end), 0),
+	}
+	// Blocked until the connection was being set up or handed out
+	blockedEnd := times.gotConn
+	for _, event := range []time.Time{times.connectStart, times.dnsStart} {
+		if !event.IsZero() {
+			blockedEnd = event
+		}
+	}
+	timings.Blocked = ms(times.start, blockedEnd)
+	if timings.Blocked < 0 {
+		timings.Blocked = -1
+	}
+	return timings, ms(times.start, end)
+}
+
+// later returns the later of two times.
+func later(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// harBody captures a body as it is read and calls done once it is fully read or closed.
+type harBody struct {
+	io.ReadCloser
+	buffer bytes.Buffer
+	done   func()
+	once   sync.Once
+	mu     sync.Mutex
+}
+
+func (body *harBody) Read(p []byte) (int, error) {
+	n, err := body.ReadCloser.Read(p)
+	body.mu.Lock()
+	body.buffer.Write(p[:n])
+	body.mu.Unlock()
+	if err == io.EOF {
+		body.finish()
+	}
+	return n, err
+}
+
+func (body *harBody) Close() error {
+	err := body.ReadCloser.Close()
+	body.finish()
+	return err
+}
+
+// finish calls done once.
+func (body *harBody) finish() {
+	body.once.Do(func() {
+		if body.done != nil {
+			body.done()
+		}
+	})
+}
+
+// bytes returns the captured body.
+func (body *harBody) bytes() []byte {
+	body.mu.Lock()
+	defer body.mu.Unlock()
+	return bytes.Clone(body.buffer.Bytes())
+}
+
+// harRequest converts a sent request.
+func harRequest(req *http.Request, body []byte, proto string) HARRequest {
+	request := HARRequest{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: proto,
+		Cookies:     harCookies(req.Cookies()),
+		Headers:     harHeaders(req.Header),
+		QueryString: harQuery(req.URL),
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+	if len(body) > 0 {
+		request.PostData = &HARPostData{MimeType: req.Header.Get("Content-Type"), Text: string(body)}
+	}
+	return request
+}
+
+// harResponse converts a received response.
+func harResponse(res *http.Response, body []byte) HARResponse {
+	response := HARResponse{
+		Status:      res.StatusCode,
+		StatusText:  strings.TrimSpace(strings.TrimPrefix(res.Status, fmt.Sprint(res.StatusCode))),
+		HTTPVersion: res.Proto,
+		Cookies:     harCookies(res.Cookies()),
+		Headers:     harHeaders(res.Header),
+		Content:     harContent(body, res.Header.Get("Content-Type")),
+		RedirectURL: res.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+	return response
+}
+
+// harEntryFromQuickRequest converts a QuickRequest which was not recorded by a
+// transport. The body and its content type are captured before the request is sent.
+func harEntryFromQuickRequest(reqData RequestData, body []byte, contentType string, response ResponseData, err error, start time.Time, elapsed time.Duration) HAREntry {
+	req, reqErr := http.NewRequest(strings.ToUpper(reqData.Type), reqData.fullUrl(), nil)
+	if reqErr != nil {
+		req = &http.Request{Method: strings.ToUpper(reqData.Type), URL: &url.URL{Opaque: reqData.Url}, Header: http.Header{}}
+	}
+	for key, values := range reqData.Headers {
+		req.Header[http.CanonicalHeaderKey(key)] = values
+	}
+	for _, name := range sortedKeys(reqData.Cookies) {
+		req.AddCookie(&http.Cookie{Name: name, Value: reqData.Cookies[name]})
+	}
+	if contentType != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	total := float64(elapsed) / float64(time.Millisecond)
+	entry := HAREntry{
+		StartedDateTime: start,
+		Time:            total,
+		Request:         harRequest(req, body, "HTTP/1.1"),
+		Timings:         HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1, Wait: total},
+	}
+	if response.Client != nil {
+		entry.ClientID = response.Client.ID()
+		if proxy := response.Client.GetProxy(); proxy != nil {
+			entry.Proxy = proxy.Redacted()
+		}
+	}
+	cookies := make([]HARCookie, 0, len(response.Cookies))
+	for _, name := range sortedKeys(response.Cookies) {
+		cookies = append(cookies, HARCookie{Name: name, Value: response.Cookies[name]})
+	}
+	entry.Response = HARResponse{
+		Status:      response.StatusCode,
+		StatusText:  strings.TrimSpace(strings.TrimPrefix(response.Status, fmt.Sprint(response.StatusCode))),
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     cookies,
+		Headers:     harHeaders(response.Headers),
+		Content:     harContent(response.Body, http.Header(response.Headers).Get("Content-Type")),
+		RedirectURL: http.Header(response.Headers).Get("Location"),
+		HeadersSize: -1,
+		BodySize:    len(response.Body),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	return entry
+}
+
+// harContent converts a response body, base64 encoding binary bodies.
+func harContent(body []byte, mimeType string) HARContent {
+	content := HARContent{Size: len(body), MimeType: mimeType}
+	if utf8.Valid(body) {
+		content.Text = string(body)
+	} else {
+		content.Text = base64.StdEncoding.EncodeToString(body)
+		content.Encoding = "base64"
+	}
+	return content
+}
+
+// harHeaders converts headers sorted by name.
+func harHeaders(header map[string][]string) []HARNameValue {
+	headers := []HARNameValue{}
+	for _, name := range sortedKeys(header) {
+		for _, value := range header[name] {
+			headers = append(headers, HARNameValue{Name: name, Value: value})
+		}
+	}
+	return headers
+}
+
+// harQuery converts the query parameters of a URL.
+func harQuery(requestUrl *url.URL) []HARNameValue {
+	query := requestUrl.Query()
+	params := []HARNameValue{}
+	for _, name := range sortedKeys(query) {
+		for _, value := range query[name] {
+			params = append(params, HARNameValue{Name: name, Value: value})
+		}
+	}
+	return params
+}
+
+// harCookies converts cookies.
+func harCookies(cookies []*http.Cookie) []HARCookie {
+	converted := make([]HARCookie, len(cookies))
+	for idx, cookie := range cookies {
+		converted[idx] = HARCookie{Name: cookie.Name, Value: cookie.Value}
+	}
+	return converted
+}
+
+// harCreatorVersion returns the version of this module from the build info.
+func harCreatorVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, module := range append([]*debug.Module{&info.Main}, info.Deps...) {
+			if module.Path == "github.com/RootInit/HttpClientPool" && module.Version != "" {
+				return module.Version
+			}
+		}
+	}
+	return "devel"
+}
+
+// harSkippedHeaders are request headers not copied by ImportHAR as they are
+// set by the transport or describe the browser's connection.
+var harSkippedHeaders = map[string]bool{
+	"Host": true, "Content-Length": true, "Connection": true, "Keep-Alive": true,
+	"Proxy-Connection": true, "Transfer-Encoding": true, "Upgrade": true, "Te": true,
+	"Accept-Encoding": true, "Cookie": true,
+}
+
+// ImportHAR converts the entries of a HAR document into RequestData, for example
+// to replay a flow captured in browser devtools through a pool.
+//
+// Entries which are not HTTP or HTTPS requests are skipped. Cookies become
+// RequestData.Cookies and bodies are sent unchanged with their original
+// Content-Type. Headers set by the transport such as Host, Content-Length and
+// Accept-Encoding are dropped.
+//
+// Parameters:
+//   - reader (io.Reader): The HAR document.
+//
+// Returns:
+//   - []RequestData: The requests in the order of the entries.
+//   - error: An error decoding the document.
+func ImportHAR(reader io.Reader) ([]RequestData, error) {
+	var har HAR
+	if err := json.NewDecoder(reader).Decode(&har); err != nil {
+		return nil, fmt.Errorf("invalid HAR: %w", err)
+	}
+	var requests []RequestData
+	for _, entry := range har.Log.Entries {
+		parsedUrl, err := url.Parse(entry.Request.URL)
+		if err != nil || (parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https") {
+			continue
+		}
+		reqData := RequestData{Type: entry.Request.Method, Url: entry.Request.URL}
+		for _, header := range entry.Request.Headers {
+			name := http.CanonicalHeaderKey(header.Name)
+			if strings.HasPrefix(header.Name, ":") || harSkippedHeaders[name] {
+				continue
+			}
+			if reqData.Headers == nil {
+				reqData.Headers = make(map[string][]string)
+			}
+			reqData.Headers[name] = append(reqData.Headers[name], header.Value)
+		}
+		for _, cookie := range entry.Request.Cookies {
+			if reqData.Cookies == nil {
+				reqData.Cookies = make(map[string]string)
+			}
+			reqData.Cookies[cookie.Name] = cookie.Value
+		}
+		if postData := entry.Request.PostData; postData != nil {
+			text := postData.Text
+			if text == "" && len(postData.Params) > 0 {
+				form := url.Values{}
+				for _, param := range postData.Params {
+					form.Add(param.Name, param.Value)
+				}
+				text = form.Encode()
+			}
+			if text != "" {
+				var body io.Reader = strings.NewReader(text)
+				reqData.RawData = &body
+				if reqData.Headers["Content-Type"] == nil && postData.MimeType != "" {
+					if reqData.Headers == nil {
+						reqData.Headers = make(map[string][]string)
+					}
+					reqData.Headers["Content-Type"] = []string{postData.MimeType}
+				}
+			}
+		}
+		requests = append(requests, reqData)
+	}
+	return requests, nil
+}
+
+// LoadHAR reads a HAR file and converts its entries into RequestData. See ImportHAR.
+//
+// Parameters:
+//   - path (string): The path of the HAR file.
+//
+// Returns:
+//   - []RequestData: The requests in the order of the entries.
+//   - error: An error reading or decoding the file.
+func LoadHAR(path string) ([]RequestData, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ImportHAR(file)
+}
@@ -0,0 +1,179 @@
+package HttpClientPool
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RootInit/HttpClientPool/pooltest"
+)
+
+func TestHARRecorder(t *testing.T) {
+	server := pooltest.NewEchoServer(t)
+	proxy := pooltest.NewHTTPProxy(t)
+	proxyUrl := proxy.URL()
+	proxyUrl.User = url.UserPassword("user", "secret")
+	pool := NewClientPool(0, 0, []*url.URL{proxyUrl}, nil)
+	recorder := NewHARRecorder()
+	pool.RecordHAR(recorder)
+	pool.Use(recorder.Middleware())
+	response, err := pool.QuickRequest(RequestData{
+		Type:     "POST",
+		Url:      server.URL + "/login",
+		Params:   map[string][]string{"next": {"/home"}},
+		Cookies:  map[string]string{"session": "abc"},
+		JsonData: map[string]string{"user": "name"},
+	})
+	if err != nil || response.StatusCode != http.StatusOK {
+		t.Fatalf("Unexpected response %d %v", response.StatusCode, err)
+	}
+	// Requests made with the http.Client are recorded too
+	client := pool.GetClient()
+	res, err := client.Get(server.URL + "/direct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	client.SetInactive()
+	entries := recorder.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry.Request.Method != "POST" || !strings.HasSuffix(entry.Request.URL, "/login?next=%2Fhome") ||
+		entry.Request.PostData == nil || entry.Request.PostData.Text != `{"user":"name"}` ||
+		len(entry.Request.Cookies) != 1 || entry.Request.QueryString[0].Value != "/home" {
+		t.Errorf("Unexpected request %+v", entry.Request)
+	}
+	if entry.Response.Status != http.StatusOK || !strings.Contains(entry.Response.Content.Text, `"method":"POST"`) {
+		t.Errorf("Unexpected response %+v", entry.Response)
+	}
+	if entry.Proxy != proxyUrl.Redacted() || strings.Contains(entry.Proxy, "secret") {
+		t.Errorf("Expected the redacted proxy, got %q", entry.Proxy)
+	}
+	timings := entry.Timings
+	if timings.Send < 0 || timings.Wait < 0 || timings.Receive < 0 || entry.Time <= 0 ||
+		timings.Wait > entry.Time || timings.Connect == 0 {
+		t.Errorf("Unexpected timings %+v in %fms", timings, entry.Time)
+	}
+	if entries[1].Request.Method != "GET" || !strings.HasSuffix(entries[1].Request.URL, "/direct") {
+		t.Errorf("Unexpected direct request %+v", entries[1].Request)
+	}
+	// The document is valid HAR 1.2
+	path := filepath.Join(t.TempDir(), "test.har")
+	if err := recorder.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	requests, err := LoadHAR(path)
+	if err != nil || len(requests) != 2 {
+		t.Fatalf("Expected 2 imported requests, got %d %v", len(requests), err)
+	}
+	// Replaying the import sends the same request
+	server.Close()
+	replayServer := pooltest.NewEchoServer(t)
+	replayPool := NewClientPool(0, 0, nil, nil)
+	requests[0].Url = strings.Replace(requests[0].Url, server.URL, replayServer.URL, 1)
+	if _, err := replayPool.QuickRequest(requests[0]); err != nil {
+		t.Fatal(err)
+	}
+	sent := replayServer.Requests()[0]
+	if sent.Method != "POST" || sent.URL != "/login?next=%2Fhome" || string(sent.Body) != `{"user":"name"}` ||
+		sent.Header.Get("Content-Type") != "application/json" || !strings.Contains(sent.Header.Get("Cookie"), "session=abc") {
+		t.Fatalf("Unexpected replayed request %+v", sent)
+	}
+}
+
+func TestHARMiddlewareOnly(t *testing.T) {
+	// Responses not sent by a recording client are recorded by the middleware
+	recorder := NewHARRecorder()
+	pool := NewClientPool(0, 0, nil, nil)
+	pool.SetClock(NewFakeClock(time.Unix(1700000000, 0)))
+	pool.Use(recorder.Middleware())
+	pool.Use(func(next Handler) Handler {
+		return func(ctx context.Context, reqData RequestData) (ResponseData, error) {
+			// Sending the request consumes the body
+			io.Copy(io.Discard, *reqData.RawData)
+			return ResponseData{StatusCode: http.StatusOK, Status: "200 OK", Body: []byte{0xff, 0xfe}}, nil
+		}
+	})
+	var body io.Reader = strings.NewReader("payload")
+	if _, err := pool.QuickRequest(RequestData{Type: "POST", Url: "https://example.com/binary", RawData: &body}); err != nil {
+		t.Fatal(err)
+	}
+	entries := recorder.Entries()
+	if len(entries) != 1 || entries[0].Response.Content.Encoding != "base64" || entries[0].Response.StatusText != "OK" {
+		t.Fatalf("Unexpected entries %+v", entries)
+	}
+	// Entries are timed by the pool clock
+	if started := entries[0].StartedDateTime; !started.Equal(time.Unix(1700000000, 0)) || entries[0].Time != 0 {
+		t.Errorf("Unexpected start %s and time %fms", started, entries[0].Time)
+	}
+	// The body was captured before it was sent
+	if postData := entries[0].Request.PostData; postData == nil || postData.Text != "payload" {
+		t.Errorf("Unexpected request body %+v", postData)
+	}
+}
+
+func TestHARPendingResponse(t *testing.T) {
+	server := pooltest.NewEchoServer(t)
+	recorder := NewHARRecorder()
+	client := &http.Client{Transport: recorder.Transport(nil)}
+	res, err := client.Get(server.URL + "/open")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	partial := make([]byte, 5)
+	if _, err := io.ReadFull(res.Body, partial); err != nil {
+		t.Fatal(err)
+	}
+	// Responses still being read are recorded with the body read so far
+	entries := recorder.HAR().Log.Entries
+	if len(entries) != 1 || entries[0].Response.Content.Text != string(partial) {
+		t.Fatalf("Expected the open response to be recorded, got %+v", entries)
+	}
+	// Finishing the body does not record it again
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	if entries := recorder.Entries(); len(entries) != 1 {
+		t.Errorf("Expected 1 entry, got %d", len(entries))
+	}
+}
+
+func TestImportHAR(t *testing.T) {
+	har := `{"log":{"version":"1.2","entries":[
+		{"request":{"method":"GET","url":"wss://example.com/socket","headers":[]}},
+		{"request":{"method":"POST","url":"https://example.com/form?a=1",
+			"headers":[{"name":":authority","value":"example.com"},{"name":"host","value":"example.com"},
+				{"name":"accept-encoding","value":"gzip"},{"name":"cookie","value":"session=abc"},
+				{"name":"x-requested-with","value":"XMLHttpRequest"}],
+			"cookies":[{"name":"session","value":"abc"}],
+			"postData":{"mimeType":"application/x-www-form-urlencoded","params":[{"name":"b","value":"2"}]}}}
+	]}}`
+	requests, err := ImportHAR(strings.NewReader(har))
+	if err != nil || len(requests) != 1 {
+		t.Fatalf("Expected 1 request, got %d %v", len(requests), err)
+	}
+	reqData := requests[0]
+	if reqData.Type != "POST" || reqData.Url != "https://example.com/form?a=1" || reqData.Cookies["session"] != "abc" {
+		t.Errorf("Unexpected request %+v", reqData)
+	}
+	if len(reqData.Headers) != 2 || reqData.Headers["X-Requested-With"] == nil ||
+		reqData.Headers["Content-Type"][0] != "application/x-www-form-urlencoded" {
+		t.Errorf("Unexpected headers %v", reqData.Headers)
+	}
+	body, _ := io.ReadAll(*reqData.RawData)
+	if string(body) != "b=2" {
+		t.Errorf("Unexpected body %q", body)
+	}
+	if _, err := ImportHAR(bytes.NewReader([]byte("not json"))); err == nil {
+		t.Error("Expected invalid HAR to fail")
+	}
+}
@@ -0,0 +1,109 @@
+package HttpClientPool
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HostLimit limits the requests made to a host.
+type HostLimit struct {
+	// Delay is the minimum time between requests to the host. Use 0 for no delay.
+	Delay time.Duration
+	// Burst is the number of requests which may be sent to the host back to back.
+	Burst int
+	// MaxInFlight limits concurrent requests to the host. Use 0 for no limit.
+	MaxInFlight int
+}
+
+// hostLimiter tracks the requests made to a host.
+type hostLimiter struct {
+	limit    HostLimit
+	inFlight int
+	// tat is the theoretical arrival time of the next request.
+	tat time.Time
+	mu  sync.Mutex
+}
+
+// HostLimits returns middleware which limits the requests made to each host.
+//
+// Hosts are matched by hostname without the port. A key starting with "*."
+// matches every subdomain, e.g. "*.example.com" matches "api.example.com".
+// Exact matches take precedence over wildcards. Requests to hosts without a
+// limit are not affected.
+//
+// Parameters:
+//   - limits (map[string]HostLimit): Map of host to limit.
+//
+// Returns:
+//   - Middleware: The host limit middleware.
+func HostLimits(limits map[string]HostLimit) Middleware {
+	limiters := make(map[string]*hostLimiter, len(limits))
+	for host, limit := range limits {
+		limiters[strings.ToLower(host)] = &hostLimiter{limit: limit}
+	}
+	find := func(rawUrl string) *hostLimiter {
+		parsedUrl, err := url.Parse(rawUrl)
+		if err != nil {
+			return nil
+		}
+		host := strings.ToLower(parsedUrl.Hostname())
+		if limiter, exists := limiters[host]; exists {
+			return limiter
+		}
+		// Check wildcards from the most specific
+		for dot := strings.IndexByte(host, '.'); dot >= 0; dot = strings.IndexByte(host, '.') {
+			host = host[dot+1:]
+			if limiter, exists := limiters["*."+host]; exists {
+				return limiter
+			}
+		}
+		return nil
+	}
+	return func(next Handler) Handler {
+		return func(ctx context.Context, reqData RequestData) (ResponseData, error) {
+			limiter := find(reqData.Url)
+			if limiter == nil {
+				return next(ctx, reqData)
+			}
+			if err := limiter.acquire(ctx); err != nil {
+				return ResponseData{}, err
+			}
+			defer limiter.release()
+			return next(ctx, reqData)
+		}
+	}
+}
+
+// acquire blocks until a request to the host is allowed.
+func (limiter *hostLimiter) acquire(ctx context.Context) error {
+	clock := clockFromContext(ctx)
+	for {
+		limiter.mu.Lock()
+		now := clock.Now()
+		limit := limiter.limit
+		wait := burstWait(limiter.tat, now, limit.Delay, max(limit.Burst, 1))
+		if (limit.MaxInFlight <= 0 || limiter.inFlight < limit.MaxInFlight) && wait <= 0 {
+			limiter.inFlight++
+			limiter.tat = nextArrival(limiter.tat, now, limit.Delay)
+			limiter.mu.Unlock()
+			return nil
+		}
+		if wait < time.Millisecond {
+			wait = time.Millisecond
+		}
+		limiter.mu.Unlock()
+		if err := sleepContext(ctx, clock, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// release marks the end of a request to the host.
+func (limiter *hostLimiter) release() {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	limiter.inFlight--
+}
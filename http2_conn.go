@@ -0,0 +1,856 @@
+package HttpClientPool
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// errHTTP2Retry is returned for requests the server did not process, which may
+// be retried on a new connection.
+var errHTTP2Retry = errors.New("http2: request not processed by the server, retry on a new connection")
+
+// errHTTP2BodyClosed is returned when reading a response body after it was closed.
+var errHTTP2BodyClosed = errors.New("http2: response body closed")
+
+// errHTTP2HeaderTimeout is returned when the response headers did not arrive
+// within the ResponseHeaderTimeout of the transport.
+var errHTTP2HeaderTimeout = errors.New("http2: timeout awaiting response headers")
+
+// http2DefaultPseudoHeaderOrder is the pseudo-header order of net/http.
+var http2DefaultPseudoHeaderOrder = []string{":method", ":authority", ":scheme", ":path"}
+
+// http2SkippedHeaders are connection specific headers which are not sent over HTTP/2.
+var http2SkippedHeaders = map[string]bool{
+	"connection": true, "host": true, "keep-alive": true, "proxy-connection": true,
+	"transfer-encoding": true, "upgrade": true,
+}
+
+// http2Conn is an HTTP/2 client connection opened with the SETTINGS,
+// WINDOW_UPDATE and header order of an HTTP2Fingerprint.
+//
+// Unlike net/http, whose HTTP/2 frames identify it as Go, every frame sent
+// to the server is chosen by the fingerprint or required by the protocol.
+type http2Conn struct {
+	conn        net.Conn
+	tlsState    *tls.ConnectionState
+	fingerprint *HTTP2Fingerprint
+	// wmu guards writes to the framer and the header encoder.
+	wmu    sync.Mutex
+	bw     *bufio.Writer
+	framer *http2.Framer
+	henc   *hpack.Encoder
+	hbuf   bytes.Buffer
+	// mu guards the fields below. cond is broadcast whenever they change.
+	mu           sync.Mutex
+	cond         *sync.Cond
+	streams      map[uint32]*http2Stream
+	nextStreamID uint32
+	// reserved counts the streams about to be opened.
+	reserved             int
+	maxConcurrentStreams uint32
+	peerMaxFrameSize     uint32
+	peerInitialWindow    int64
+	// sendWindow is the connection flow control window of the server.
+	sendWindow int64
+	// recvWindow is the connection flow control window announced to the server
+	// and unackedRecv the bytes received since the window was last extended.
+	recvWindow       int64
+	unackedRecv      int64
+	streamRecvWindow int64
+	goAway           bool
+	err              error
+	// idleTimeout closes the connection once it has had no streams for that
+	// long, the IdleConnTimeout of the transport. idleTimer is nil without one.
+	idleTimeout time.Duration
+	idleTimer   *time.Timer
+	// responseHeaderTimeout limits the wait for response headers.
+	responseHeaderTimeout time.Duration
+	// onClose is called once when the connection is closed.
+	onClose   func(cc *http2Conn)
+	closeOnce sync.Once
+}
+
+// http2Stream is a request on an http2Conn.
+type http2Stream struct {
+	cc  *http2Conn
+	id  uint32
+	req *http.Request
+	// respc is closed once res or resErr is set.
+	respc  chan struct{}
+	res    *http.Response
+	resErr error
+	// sendWindow is the stream flow control window of the server.
+	sendWindow int64
+	// recvBuf holds received body data until it is read. recvErr is returned
+	// once it is empty, io.EOF when the server ended the stream.
+	recvBuf     bytes.Buffer
+	recvErr     error
+	unackedRecv int64
+	// sendErr stops sending the request body.
+	sendErr error
+	// sending is true until the request body is sent.
+	sending bool
+	// stop ends the cancellation of the stream by the request context.
+	stop func() bool
+}
+
+// newHTTP2Conn starts an HTTP/2 connection over an established TLS connection.
+//
+// Parameters:
+//   - conn (net.Conn): The connection, which negotiated "h2".
+//   - tlsState (*tls.ConnectionState): The state of the TLS connection.
+//   - fingerprint (*HTTP2Fingerprint): The fingerprint to present.
+//   - base (*http.Transport): The transport whose IdleConnTimeout and ResponseHeaderTimeout apply.
+//   - onClose (func(cc *http2Conn)): Called once when the connection is closed, may be nil.
+//
+// Returns:
+//   - *http2Conn: The connection.
+//   - error: An error writing the connection preface.
+func newHTTP2Conn(conn net.Conn, tlsState *tls.ConnectionState, fingerprint *HTTP2Fingerprint, base *http.Transport, onClose func(cc *http2Conn)) (*http2Conn, error) {
+	cc := &http2Conn{
+		idleTimeout:           base.IdleConnTimeout,
+		responseHeaderTimeout: base.ResponseHeaderTimeout,
+		onClose:               onClose,
+		conn:                  conn,
+		tlsState:              tlsState,
+		fingerprint:           fingerprint,
+		bw:                    bufio.NewWriter(conn),
+		streams:               make(map[uint32]*http2Stream),
+		nextStreamID:          1,
+		maxConcurrentStreams:  100,
+		peerMaxFrameSize:      16384,
+		peerInitialWindow:     65535,
+		sendWindow:            65535,
+		recvWindow:            65535 + int64(fingerprint.WindowUpdate),
+		streamRecvWindow:      int64(fingerprint.setting(http2.SettingInitialWindowSize, 65535)),
+	}
+	cc.cond = sync.NewCond(&cc.mu)
+	cc.framer = http2.NewFramer(cc.bw, conn)
+	cc.framer.ReadMetaHeaders = hpack.NewDecoder(fingerprint.setting(http2.SettingHeaderTableSize, 4096), nil)
+	cc.framer.MaxHeaderListSize = fingerprint.setting(http2.SettingMaxHeaderListSize, 10<<20)
+	cc.framer.SetMaxReadFrameSize(fingerprint.setting(http2.SettingMaxFrameSize, 16384))
+	cc.henc = hpack.NewEncoder(&cc.hbuf)
+	// Connection preface
+	cc.bw.WriteString(http2.ClientPreface)
+	cc.framer.WriteSettings(fingerprint.Settings...)
+	if fingerprint.WindowUpdate > 0 {
+		cc.framer.WriteWindowUpdate(0, fingerprint.WindowUpdate)
+	}
+	if err := cc.bw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	go cc.readLoop()
+	return cc, nil
+}
+
+// reserve reserves a stream for a request if the connection can take one.
+//
+// Returns:
+//   - bool: True if a stream was reserved.
+func (cc *http2Conn) reserve() bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.err != nil || cc.goAway || uint32(len(cc.streams)+cc.reserved) >= cc.maxConcurrentStreams {
+		return false
+	}
+	cc.reserved++
+	cc.updateIdleTimer()
+	return true
+}
+
+// updateIdleTimer starts the idle timer when the connection has no streams and
+// stops it otherwise. The caller must hold cc.mu.
+func (cc *http2Conn) updateIdleTimer() {
+	if cc.idleTimeout <= 0 || cc.err != nil {
+		return
+	}
+	if len(cc.streams) > 0 || cc.reserved > 0 {
+		if cc.idleTimer != nil {
+			cc.idleTimer.Stop()
+		}
+		return
+	}
+	if cc.idleTimer == nil {
+		cc.idleTimer = time.AfterFunc(cc.idleTimeout, cc.closeIfIdle)
+	} else {
+		cc.idleTimer.Reset(cc.idleTimeout)
+	}
+}
+
+// closeIfIdle closes the connection if it still has no streams.
+func (cc *http2Conn) closeIfIdle() {
+	cc.mu.Lock()
+	if len(cc.streams) > 0 || cc.reserved > 0 || cc.err != nil {
+		cc.mu.Unlock()
+		return
+	}
+	// Stop reserve handing out the connection before it is closed
+	cc.err = errHTTP2Retry
+	cc.mu.Unlock()
+	cc.close(errHTTP2Retry)
+}
+
+// isIdle returns true if the connection has no streams.
+func (cc *http2Conn) isIdle() bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return len(cc.streams) == 0 && cc.reserved == 0
+}
+
+// isClosed returns true if the connection can no longer be used.
+func (cc *http2Conn) isClosed() bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.err != nil || (cc.goAway && len(cc.streams) == 0 && cc.reserved == 0)
+}
+
+// roundTrip sends a request on a stream reserved with reserve.
+//
+// Parameters:
+//   - req (*http.Request): The request.
+//
+// Returns:
+//   - *http.Response: The response.
+//   - error: An error sending the request, errHTTP2Retry if it may be retried.
+func (cc *http2Conn) roundTrip(req *http.Request) (*http.Response, error) {
+	trace := httptrace.ContextClientTrace(req.Context())
+	hasBody := req.Body != nil && req.Body != http.NoBody
+	fields, err := cc.requestHeaders(req)
+	if err != nil {
+		cc.unreserve()
+		closeRequestBody(req)
+		return nil, err
+	}
+	// Stream ids must increase in the order the HEADERS frames are sent
+	cc.wmu.Lock()
+	cc.mu.Lock()
+	cc.reserved--
+	if cc.err != nil || cc.goAway {
+		cc.updateIdleTimer()
+		cc.mu.Unlock()
+		cc.wmu.Unlock()
+		closeRequestBody(req)
+		return nil, errHTTP2Retry
+	}
+	stream := &http2Stream{
+		cc:         cc,
+		id:         cc.nextStreamID,
+		req:        req,
+		respc:      make(chan struct{}),
+		sendWindow: cc.peerInitialWindow,
+		sending:    hasBody,
+	}
+	stream.stop = context.AfterFunc(req.Context(), func() {
+		cc.resetStream(stream, http2.ErrCodeCancel, req.Context().Err(), true)
+	})
+	cc.nextStreamID += 2
+	cc.streams[stream.id] = stream
+	maxFrameSize := cc.peerMaxFrameSize
+	cc.mu.Unlock()
+	err = cc.writeHeaders(stream.id, !hasBody, fields, maxFrameSize)
+	cc.wmu.Unlock()
+	if err != nil {
+		cc.close(err)
+		closeRequestBody(req)
+		return nil, err
+	}
+	if trace != nil && trace.WroteHeaders != nil {
+		trace.WroteHeaders()
+	}
+	if hasBody {
+		go cc.writeBody(stream, req.Body, trace)
+	} else if trace != nil && trace.WroteRequest != nil {
+		trace.WroteRequest(httptrace.WroteRequestInfo{})
+	}
+	if cc.responseHeaderTimeout > 0 {
+		timer := time.NewTimer(cc.responseHeaderTimeout)
+		select {
+		case <-stream.respc:
+		case <-timer.C:
+			cc.resetStream(stream, http2.ErrCodeCancel, errHTTP2HeaderTimeout, true)
+		}
+		timer.Stop()
+	}
+	<-stream.respc
+	if stream.resErr != nil {
+		return nil, stream.resErr
+	}
+	if trace != nil && trace.GotFirstResponseByte != nil {
+		trace.GotFirstResponseByte()
+	}
+	return stream.res, nil
+}
+
+// unreserve releases a stream reserved with reserve.
+func (cc *http2Conn) unreserve() {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.reserved--
+	cc.updateIdleTimer()
+	cc.cond.Broadcast()
+}
+
+// requestHeaders returns the header fields of a request in the order of the fingerprint.
+func (cc *http2Conn) requestHeaders(req *http.Request) ([]hpack.HeaderField, error) {
+	if len(req.Trailer) > 0 {
+		return nil, errors.New("http2: request trailers are not supported")
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	host = strings.TrimSuffix(host, ":443")
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	if method == http.MethodConnect {
+		return nil, errors.New("http2: CONNECT requests are not supported")
+	}
+	pseudo := map[string]string{
+		":method":    method,
+		":authority": host,
+		":scheme":    "https",
+		":path":      req.URL.RequestURI(),
+	}
+	order := slices.Clone(cc.fingerprint.PseudoHeaderOrder)
+	for _, name := range http2DefaultPseudoHeaderOrder {
+		if !slices.Contains(order, name) {
+			order = append(order, name)
+		}
+	}
+	var fields []hpack.HeaderField
+	for _, name := range order {
+		if value, exists := pseudo[name]; exists {
+			fields = append(fields, hpack.HeaderField{Name: name, Value: value})
+		}
+	}
+	// Regular headers
+	var regular []hpack.HeaderField
+	for key, values := range req.Header {
+		name := strings.ToLower(key)
+		if http2SkippedHeaders[name] {
+			continue
+		}
+		if !httpguts.ValidHeaderFieldName(key) {
+			return nil, fmt.Errorf("http2: invalid header name %q", key)
+		}
+		for _, value := range values {
+			if !httpguts.ValidHeaderFieldValue(value) {
+				return nil, fmt.Errorf("http2: invalid value for header %q", key)
+			}
+			if name == "te" && value != "trailers" {
+				continue
+			}
+			regular = append(regular, hpack.HeaderField{Name: name, Value: value})
+		}
+	}
+	if req.ContentLength > 0 && req.Header.Get("Content-Length") == "" {
+		regular = append(regular, hpack.HeaderField{Name: "content-length", Value: strconv.FormatInt(req.ContentLength, 10)})
+	}
+	rank := func(name string) int {
+		if idx := slices.Index(cc.fingerprint.HeaderOrder, name); idx >= 0 {
+			return idx
+		}
+		return len(cc.fingerprint.HeaderOrder)
+	}
+	slices.SortStableFunc(regular, func(a, b hpack.HeaderField) int {
+		if rankA, rankB := rank(a.Name), rank(b.Name); rankA != rankB {
+			return rankA - rankB
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return append(fields, regular...), nil
+}
+
+// writeHeaders writes a HEADERS frame and any CONTINUATION frames. The caller must hold cc.wmu.
+func (cc *http2Conn) writeHeaders(streamID uint32, endStream bool, fields []hpack.HeaderField, maxFrameSize uint32) error {
+	cc.hbuf.Reset()
+	for _, field := range fields {
+		cc.henc.WriteField(field)
+	}
+	block := cc.hbuf.Bytes()
+	first := block[:min(len(block), int(maxFrameSize))]
+	block = block[len(first):]
+	param := http2.HeadersFrameParam{
+		StreamID:      streamID,
+		BlockFragment: first,
+		EndStream:     endStream,
+		EndHeaders:    len(block) == 0,
+	}
+	if cc.fingerprint.Priority != nil {
+		param.Priority = *cc.fingerprint.Priority
+	}
+	if err := cc.framer.WriteHeaders(param); err != nil {
+		return err
+	}
+	for len(block) > 0 {
+		fragment := block[:min(len(block), int(maxFrameSize))]
+		block = block[len(fragment):]
+		if err := cc.framer.WriteContinuation(streamID, len(block) == 0, fragment); err != nil {
+			return err
+		}
+	}
+	return cc.bw.Flush()
+}
+
+// writeBody sends a request body within the flow control windows of the server.
+func (cc *http2Conn) writeBody(stream *http2Stream, body io.ReadCloser, trace *httptrace.ClientTrace) {
+	defer body.Close()
+	buf := make([]byte, 16384)
+	var err error
+	for err == nil {
+		var n int
+		n, err = body.Read(buf)
+		for data := buf[:n]; len(data) > 0; {
+			allowed, sendErr := cc.awaitSendWindow(stream, len(data))
+			if sendErr != nil {
+				return
+			}
+			if writeErr := cc.write(func() error {
+				return cc.framer.WriteData(stream.id, false, data[:allowed])
+			}); writeErr != nil {
+				cc.close(writeErr)
+				return
+			}
+			data = data[allowed:]
+		}
+	}
+	cc.mu.Lock()
+	stream.sending = false
+	stopped := stream.sendErr != nil
+	cc.mu.Unlock()
+	if stopped {
+		return
+	}
+	if err != io.EOF {
+		cc.resetStream(stream, http2.ErrCodeCancel, err, true)
+		return
+	}
+	if writeErr := cc.write(func() error { return cc.framer.WriteData(stream.id, true, nil) }); writeErr != nil {
+		cc.close(writeErr)
+		return
+	}
+	if trace != nil && trace.WroteRequest != nil {
+		trace.WroteRequest(httptrace.WroteRequestInfo{})
+	}
+}
+
+// awaitSendWindow blocks until data may be sent on a stream.
+//
+// Returns:
+//   - int: The number of bytes, at most want, which may be sent.
+//   - error: The error which stopped the stream.
+func (cc *http2Conn) awaitSendWindow(stream *http2Stream, want int) (int, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	for {
+		if stream.sendErr != nil {
+			return 0, stream.sendErr
+		}
+		if cc.err != nil {
+			return 0, cc.err
+		}
+		if available := min(cc.sendWindow, stream.sendWindow, int64(cc.peerMaxFrameSize)); available > 0 {
+			allowed := int(min(available, int64(want)))
+			cc.sendWindow -= int64(allowed)
+			stream.sendWindow -= int64(allowed)
+			return allowed, nil
+		}
+		cc.cond.Wait()
+	}
+}
+
+// write runs a framer write and flushes it.
+func (cc *http2Conn) write(write func() error) error {
+	cc.wmu.Lock()
+	defer cc.wmu.Unlock()
+	if err := write(); err != nil {
+		return err
+	}
+	return cc.bw.Flush()
+}
+
+// readLoop reads the frames sent by the server until the connection is closed.
+func (cc *http2Conn) readLoop() {
+	for {
+		frame, err := cc.framer.ReadFrame()
+		var streamErr http2.StreamError
+		if errors.As(err, &streamErr) {
+			cc.resetStream(cc.stream(streamErr.StreamID), streamErr.Code, streamErr, true)
+			continue
+		}
+		if err != nil {
+			cc.close(err)
+			return
+		}
+		switch frame := frame.(type) {
+		case *http2.SettingsFrame:
+			if !frame.IsAck() {
+				cc.handleSettings(frame)
+			}
+		case *http2.MetaHeadersFrame:
+			cc.handleHeaders(frame)
+		case *http2.DataFrame:
+			cc.handleData(frame)
+		case *http2.WindowUpdateFrame:
+			cc.mu.Lock()
+			if frame.StreamID == 0 {
+				cc.sendWindow += int64(frame.Increment)
+			} else if stream := cc.streams[frame.StreamID]; stream != nil {
+				stream.sendWindow += int64(frame.Increment)
+			}
+			cc.cond.Broadcast()
+			cc.mu.Unlock()
+		case *http2.PingFrame:
+			if !frame.IsAck() {
+				cc.write(func() error { return cc.framer.WritePing(true, frame.Data) })
+			}
+		case *http2.RSTStreamFrame:
+			cc.resetStream(cc.stream(frame.StreamID), frame.ErrCode, http2.StreamError{StreamID: frame.StreamID, Code: frame.ErrCode}, false)
+		case *http2.GoAwayFrame:
+			cc.handleGoAway(frame)
+		case *http2.PushPromiseFrame:
+			// Push is disabled by the fingerprints which allow it to be
+			cc.write(func() error { return cc.framer.WriteGoAway(0, http2.ErrCodeProtocol, nil) })
+			cc.close(http2.ConnectionError(http2.ErrCodeProtocol))
+			return
+		}
+	}
+}
+
+// stream returns the open stream with an id, nil if there is none.
+func (cc *http2Conn) stream(id uint32) *http2Stream {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.streams[id]
+}
+
+// handleSettings applies the settings of the server and acknowledges them.
+func (cc *http2Conn) handleSettings(frame *http2.SettingsFrame) {
+	var headerTableSize *uint32
+	cc.mu.Lock()
+	frame.ForeachSetting(func(setting http2.Setting) error {
+		switch setting.ID {
+		case http2.SettingInitialWindowSize:
+			delta := int64(setting.Val) - cc.peerInitialWindow
+			for _, stream := range cc.streams {
+				stream.sendWindow += delta
+			}
+			cc.peerInitialWindow = int64(setting.Val)
+		case http2.SettingMaxFrameSize:
+			cc.peerMaxFrameSize = setting.Val
+		case http2.SettingMaxConcurrentStreams:
+			cc.maxConcurrentStreams = setting.Val
+		case http2.SettingHeaderTableSize:
+			headerTableSize = &setting.Val
+		}
+		return nil
+	})
+	cc.cond.Broadcast()
+	cc.mu.Unlock()
+	cc.write(func() error {
+		if headerTableSize != nil {
+			cc.henc.SetMaxDynamicTableSizeLimit(*headerTableSize)
+		}
+		return cc.framer.WriteSettingsAck()
+	})
+}
+
+// handleHeaders delivers the response headers or trailers of a stream.
+func (cc *http2Conn) handleHeaders(frame *http2.MetaHeadersFrame) {
+	cc.mu.Lock()
+	stream := cc.streams[frame.StreamID]
+	if stream == nil {
+		cc.mu.Unlock()
+		return
+	}
+	if stream.res != nil {
+		// Trailers
+		stream.res.Trailer = make(http.Header)
+		for _, field := range frame.RegularFields() {
+			stream.res.Trailer.Add(http.CanonicalHeaderKey(field.Name), field.Value)
+		}
+		cc.mu.Unlock()
+		cc.endStream(stream)
+		return
+	}
+	status, err := strconv.Atoi(frame.PseudoValue("status"))
+	if err != nil || status < 100 || status > 999 {
+		cc.mu.Unlock()
+		cc.resetStream(stream, http2.ErrCodeProtocol, fmt.Errorf("http2: invalid status %q", frame.PseudoValue("status")), true)
+		return
+	}
+	if status < 200 {
+		// Informational responses are skipped
+		cc.mu.Unlock()
+		return
+	}
+	header := make(http.Header)
+	for _, field := range frame.RegularFields() {
+		header.Add(http.CanonicalHeaderKey(field.Name), field.Value)
+	}
+	res := &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/2.0",
+		ProtoMajor:    2,
+		Header:        header,
+		ContentLength: -1,
+		Request:       stream.req,
+		TLS:           cc.tlsState,
+		Body:          &http2Body{stream: stream},
+	}
+	if contentLength, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil {
+		res.ContentLength = contentLength
+	} else if frame.StreamEnded() {
+		res.ContentLength = 0
+	}
+	stream.res = res
+	close(stream.respc)
+	cc.mu.Unlock()
+	if frame.StreamEnded() {
+		cc.endStream(stream)
+	}
+}
+
+// handleData buffers body data and extends the connection window as it is received.
+//
+// Data beyond the flow control windows announced to the server is a
+// FLOW_CONTROL_ERROR of the connection or the stream.
+func (cc *http2Conn) handleData(frame *http2.DataFrame) {
+	length := int64(frame.Length)
+	var connUpdate int64
+	cc.mu.Lock()
+	if cc.unackedRecv+length > cc.recvWindow {
+		cc.mu.Unlock()
+		cc.write(func() error { return cc.framer.WriteGoAway(0, http2.ErrCodeFlowControl, nil) })
+		cc.close(http2.ConnectionError(http2.ErrCodeFlowControl))
+		return
+	}
+	cc.unackedRecv += length
+	if cc.unackedRecv >= cc.recvWindow/2 {
+		connUpdate, cc.unackedRecv = cc.unackedRecv, 0
+	}
+	stream := cc.streams[frame.StreamID]
+	overrun := false
+	if stream != nil && stream.res != nil {
+		// The server may use the default window until it acknowledges the settings
+		window := max(cc.streamRecvWindow, 65535)
+		if int64(stream.recvBuf.Len())+stream.unackedRecv+length > window {
+			overrun = true
+		} else {
+			data := frame.Data()
+			stream.recvBuf.Write(data)
+			// Padding is never read so counts as read straight away
+			stream.unackedRecv += length - int64(len(data))
+			cc.cond.Broadcast()
+		}
+	}
+	cc.mu.Unlock()
+	if connUpdate > 0 {
+		cc.write(func() error { return cc.framer.WriteWindowUpdate(0, uint32(connUpdate)) })
+	}
+	switch {
+	case overrun:
+		cc.resetStream(stream, http2.ErrCodeFlowControl, http2.StreamError{StreamID: stream.id, Code: http2.ErrCodeFlowControl}, true)
+	case stream != nil && stream.res == nil:
+		cc.resetStream(stream, http2.ErrCodeProtocol, errors.New("http2: DATA before response headers"), true)
+	case stream != nil && frame.StreamEnded():
+		cc.endStream(stream)
+	}
+}
+
+// handleGoAway stops new requests and fails those the server did not process.
+func (cc *http2Conn) handleGoAway(frame *http2.GoAwayFrame) {
+	cc.mu.Lock()
+	cc.goAway = true
+	var unprocessed []*http2Stream
+	for id, stream := range cc.streams {
+		if id > frame.LastStreamID {
+			unprocessed = append(unprocessed, stream)
+		}
+	}
+	idle := len(cc.streams) == len(unprocessed)
+	cc.cond.Broadcast()
+	cc.mu.Unlock()
+	for _, stream := range unprocessed {
+		cc.resetStream(stream, http2.ErrCodeRefusedStream, errHTTP2Retry, false)
+	}
+	if idle {
+		cc.close(errHTTP2Retry)
+	}
+}
+
+// endStream marks the response of a stream as complete.
+func (cc *http2Conn) endStream(stream *http2Stream) {
+	cc.mu.Lock()
+	if stream.recvErr == nil {
+		stream.recvErr = io.EOF
+	}
+	// Stop sending a request body the server no longer needs
+	stillSending := stream.sending && stream.sendErr == nil
+	if stream.sendErr == nil {
+		stream.sendErr = io.EOF
+	}
+	delete(cc.streams, stream.id)
+	closeIdle := cc.goAway && len(cc.streams) == 0
+	cc.updateIdleTimer()
+	cc.cond.Broadcast()
+	cc.mu.Unlock()
+	stream.stop()
+	if stillSending {
+		cc.write(func() error { return cc.framer.WriteRSTStream(stream.id, http2.ErrCodeNo) })
+	}
+	if closeIdle {
+		cc.close(errHTTP2Retry)
+	}
+}
+
+// resetStream fails a stream and optionally sends RST_STREAM to the server.
+func (cc *http2Conn) resetStream(stream *http2Stream, code http2.ErrCode, err error, send bool) {
+	if stream == nil {
+		return
+	}
+	cc.mu.Lock()
+	if _, open := cc.streams[stream.id]; !open {
+		cc.mu.Unlock()
+		return
+	}
+	delete(cc.streams, stream.id)
+	stream.fail(err)
+	closeIdle := cc.goAway && len(cc.streams) == 0
+	cc.updateIdleTimer()
+	cc.cond.Broadcast()
+	cc.mu.Unlock()
+	stream.stop()
+	if send {
+		cc.write(func() error { return cc.framer.WriteRSTStream(stream.id, code) })
+	}
+	if closeIdle {
+		cc.close(errHTTP2Retry)
+	}
+}
+
+// fail sets the error of a stream. The caller must hold cc.mu.
+func (stream *http2Stream) fail(err error) {
+	if stream.recvErr == nil {
+		stream.recvErr = err
+	}
+	if stream.sendErr == nil {
+		stream.sendErr = err
+	}
+	if stream.res == nil && stream.resErr == nil {
+		stream.resErr = err
+		close(stream.respc)
+	}
+}
+
+// close closes the connection and fails its streams.
+func (cc *http2Conn) close(err error) {
+	cc.mu.Lock()
+	if cc.err == nil {
+		cc.err = err
+	}
+	streams := cc.streams
+	cc.streams = make(map[uint32]*http2Stream)
+	for _, stream := range streams {
+		if stream.res == nil && cc.goAway {
+			// Unanswered requests on a closing connection are retried
+			stream.fail(errHTTP2Retry)
+		} else {
+			stream.fail(err)
+		}
+	}
+	if cc.idleTimer != nil {
+		cc.idleTimer.Stop()
+	}
+	cc.cond.Broadcast()
+	cc.mu.Unlock()
+	for _, stream := range streams {
+		stream.stop()
+	}
+	cc.conn.Close()
+	cc.closeOnce.Do(func() {
+		if cc.onClose != nil {
+			cc.onClose(cc)
+		}
+	})
+}
+
+// http2Body is the body of a response received on an http2Conn.
+type http2Body struct {
+	stream *http2Stream
+	closed bool
+}
+
+func (body *http2Body) Read(p []byte) (int, error) {
+	stream := body.stream
+	cc := stream.cc
+	cc.mu.Lock()
+	for stream.recvBuf.Len() == 0 && stream.recvErr == nil {
+		cc.cond.Wait()
+	}
+	if stream.recvBuf.Len() == 0 {
+		err := stream.recvErr
+		cc.mu.Unlock()
+		return 0, err
+	}
+	n, _ := stream.recvBuf.Read(p)
+	stream.unackedRecv += int64(n)
+	var update int64
+	if stream.recvErr == nil && stream.unackedRecv >= cc.streamRecvWindow/2 {
+		update, stream.unackedRecv = stream.unackedRecv, 0
+	}
+	cc.mu.Unlock()
+	if update > 0 {
+		cc.write(func() error { return cc.framer.WriteWindowUpdate(stream.id, uint32(update)) })
+	}
+	return n, nil
+}
+
+func (body *http2Body) Close() error {
+	stream := body.stream
+	cc := stream.cc
+	cc.mu.Lock()
+	if body.closed {
+		cc.mu.Unlock()
+		return nil
+	}
+	body.closed = true
+	complete := stream.recvErr != nil
+	cc.mu.Unlock()
+	if !complete {
+		cc.resetStream(stream, http2.ErrCodeCancel, errHTTP2BodyClosed, true)
+	}
+	cc.mu.Lock()
+	stream.recvBuf.Reset()
+	stream.recvErr = errHTTP2BodyClosed
+	cc.mu.Unlock()
+	return nil
+}
+
+// closeRequestBody closes the body of a request which will not be sent.
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
@@ -0,0 +1,91 @@
+package HttpClientPool
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Jitter randomises the spacing between requests so they are not perfectly periodic.
+//
+// A Jitter is called once per request with the configured delay and returns the
+// delay to wait before the next request. To keep the configured rate over time
+// the returned delays should average the configured delay. Negative results are
+// treated as 0.
+//
+// Parameters:
+//   - delay (time.Duration): The configured delay between requests.
+//   - rng (*rand.Rand): The random source to sample from.
+//
+// Returns:
+//   - time.Duration: The delay before the next request.
+type Jitter func(delay time.Duration, rng *rand.Rand) time.Duration
+
+// UniformJitter returns a Jitter spreading delays evenly within ±fraction of the delay.
+//
+// Parameters:
+//   - fraction (float64): The maximum deviation as a fraction of the delay, e.g.
+//     0.2 for ±20%. Values are clamped to between 0 and 1.
+//
+// Returns:
+//   - Jitter: The uniform jitter.
+func UniformJitter(fraction float64) Jitter {
+	fraction = min(max(fraction, 0), 1)
+	return func(delay time.Duration, rng *rand.Rand) time.Duration {
+		return time.Duration(float64(delay) * (1 + fraction*(2*rng.Float64()-1)))
+	}
+}
+
+// ExponentialJitter returns a Jitter with exponentially distributed delays,
+// making requests arrive as a Poisson process at the configured rate.
+//
+// Returns:
+//   - Jitter: The exponential jitter.
+func ExponentialJitter() Jitter {
+	return func(delay time.Duration, rng *rand.Rand) time.Duration {
+		return time.Duration(float64(delay) * rng.ExpFloat64())
+	}
+}
+
+// LogNormalJitter returns a Jitter with log-normally distributed delays, which
+// resemble human think time: mostly near the delay with occasional long pauses.
+//
+// Parameters:
+//   - sigma (float64): The standard deviation of the delay's logarithm. Larger
+//     values give longer pauses, 0.5 is a reasonable start.
+//
+// Returns:
+//   - Jitter: The log-normal jitter.
+func LogNormalJitter(sigma float64) Jitter {
+	sigma = max(sigma, 0)
+	return func(delay time.Duration, rng *rand.Rand) time.Duration {
+		// Shift the mean of the logarithm so the delays average 1x the delay
+		return time.Duration(float64(delay) * math.Exp(sigma*rng.NormFloat64()-sigma*sigma/2))
+	}
+}
+
+// sample returns the delay before the next request.
+//
+// Parameters:
+//   - delay (time.Duration): The configured delay between requests.
+//   - rng (*rand.Rand): The random source to sample from.
+//
+// Returns:
+//   - time.Duration: The jittered delay, delay itself if jitter is nil.
+func (jitter Jitter) sample(delay time.Duration, rng *rand.Rand) time.Duration {
+	if jitter == nil || delay <= 0 {
+		return delay
+	}
+	return max(jitter(delay, rng), 0)
+}
+
+// newJitterRand returns the random source for a jitter.
+//
+// Parameters:
+//   - seed (int64): The seed. Equal seeds give equal delays.
+//
+// Returns:
+//   - *rand.Rand: The random source.
+func newJitterRand(seed int64) *rand.Rand {
+	return rand.New(rand.NewSource(seed))
+}
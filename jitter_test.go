@@ -0,0 +1,120 @@
+package HttpClientPool
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestJitterMean(t *testing.T) {
+	delay := 100 * time.Millisecond
+	jitters := map[string]Jitter{
+		"uniform":     UniformJitter(0.5),
+		"exponential": ExponentialJitter(),
+		"lognormal":   LogNormalJitter(0.5),
+	}
+	for name, jitter := range jitters {
+		rng := newJitterRand(1)
+		var total time.Duration
+		distinct := make(map[time.Duration]bool)
+		for range 20000 {
+			sample := jitter.sample(delay, rng)
+			if sample < 0 {
+				t.Fatalf("%s: negative delay %s", name, sample)
+			}
+			total += sample
+			distinct[sample] = true
+		}
+		mean := total / 20000
+		if math.Abs(float64(mean-delay)) > 0.03*float64(delay) {
+			t.Errorf("%s: mean delay %s, expected about %s", name, mean, delay)
+		}
+		if len(distinct) < 1000 {
+			t.Errorf("%s: only %d distinct delays", name, len(distinct))
+		}
+	}
+	// Uniform stays within its bounds
+	rng := newJitterRand(1)
+	for range 1000 {
+		if sample := UniformJitter(0.2).sample(delay, rng); sample < 80*time.Millisecond || sample > 120*time.Millisecond {
+			t.Fatalf("Uniform delay %s out of bounds", sample)
+		}
+	}
+	// No jitter and no delay are unchanged
+	if Jitter(nil).sample(delay, rng) != delay || ExponentialJitter().sample(0, rng) != 0 {
+		t.Error("Expected the delay to be unchanged")
+	}
+}
+
+func TestJitterSeed(t *testing.T) {
+	sequence := func(seed int64) []time.Duration {
+		client := NewClient(nil, "", 10*time.Millisecond)
+		client.SetJitter(LogNormalJitter(1), seed)
+		var delays []time.Duration
+		for range 10 {
+			client.SetActive()
+			client.SetInactive()
+			delays = append(delays, client.spacing)
+		}
+		return delays
+	}
+	first, second, other := sequence(42), sequence(42), sequence(7)
+	same := true
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("Equal seeds gave different delays %v and %v", first, second)
+		}
+		same = same && first[i] == other[i]
+	}
+	if same {
+		t.Fatal("Different seeds gave equal delays")
+	}
+}
+
+func TestClientJitter(t *testing.T) {
+	// A custom jitter alternating between 0 and 3x the delay
+	var calls int
+	alternate := func(delay time.Duration, rng *rand.Rand) time.Duration {
+		calls++
+		if calls%2 == 1 {
+			return 0
+		}
+		return 3 * delay
+	}
+	clock := NewFakeClock(time.Unix(0, 0))
+	pool := NewClientPool(20*time.Millisecond, 0, nil, nil)
+	pool.SetClock(clock)
+	pool.SetClientJitter(alternate, 1)
+	client := pool.Clients[0]
+	if activated, _ := client.tryActivate(); !activated {
+		t.Fatal("Expected the first request to be allowed")
+	}
+	client.SetInactive()
+	// Sampled 0 so the next request is allowed immediately
+	if activated, _ := client.tryActivate(); !activated {
+		t.Fatal("Expected a jittered delay of 0")
+	}
+	client.SetInactive()
+	// Sampled 60ms so the configured 20ms is not enough
+	clock.Advance(59 * time.Millisecond)
+	if client.IsAvailable() {
+		t.Fatal("Expected a jittered delay of 60ms")
+	}
+	clock.Advance(time.Millisecond)
+	if !client.IsAvailable() {
+		t.Fatal("Expected the client to be available after 60ms")
+	}
+	// The pool delay is jittered too
+	pool = NewClientPool(0, 20*time.Millisecond, nil, nil)
+	pool.SetClock(clock)
+	pool.SetPoolJitter(func(delay time.Duration, rng *rand.Rand) time.Duration { return 3 * delay }, 1)
+	if client, _ := pool.tryHandout(GetClientOptions{}); client == nil {
+		t.Fatal("Expected the first request to be allowed")
+	} else {
+		client.SetInactive()
+	}
+	if _, wait := pool.tryHandout(GetClientOptions{}); wait != 60*time.Millisecond {
+		t.Fatalf("Expected a jittered pool wait of 60ms, got %s", wait)
+	}
+}
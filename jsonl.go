@@ -0,0 +1,258 @@
+package HttpClientPool
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// JSONLRecord is a line of the output written by RunJSONL.
+type JSONLRecord struct {
+	// ID is the id of the input record, or its line number if it has no id.
+	ID string `json:"id"`
+	// Response is the response received, omitted if the request failed.
+	Response *ResponseData `json:"response,omitempty"`
+	// Error is the request error, if any.
+	Error string `json:"error,omitempty"`
+	// Started is when the request was read from the input.
+	Started time.Time `json:"started"`
+	// DurationMs is the time taken by the request in milliseconds.
+	DurationMs float64 `json:"duration_ms"`
+	// ClientID is the id of the Client which made the request, 0 if none.
+	ClientID uint64 `json:"client_id,omitempty"`
+}
+
+// JSONLStats summarises a run of RunJSONL.
+type JSONLStats struct {
+	// Succeeded is the number of requests completed without error.
+	Succeeded int
+	// Failed is the number of records which could not be parsed or whose request failed.
+	Failed int
+	// Skipped is the number of records skipped as already completed.
+	Skipped int
+}
+
+// jsonlInput is a line of the input read by RunJSONL.
+type jsonlInput struct {
+	ID json.RawMessage `json:"id"`
+	RequestData
+}
+
+// RunJSONL runs the requests read from JSONL input and writes a JSONLRecord for
+// each as JSONL output.
+//
+// Each input line is a RequestData encoded as JSON with an optional "id" field,
+// e.g. {"id": "a1", "Type": "GET", "Url": "https://example.com"}. Records
+// without an id use their line number. Blank lines are ignored. Lines which
+// cannot be parsed are written as failed records. When opts.StopOnError stops
+// the batch the remaining records are neither run nor written.
+//
+// Parameters:
+//   - ctx (context.Context): The context for the requests.
+//   - input (io.Reader): The JSONL requests.
+//   - output (io.Writer): Where the JSONL results are written.
+//   - skip (map[string]bool): Ids of records to skip. Use nil to run every record.
+//   - opts (BatchOptions): The options of the underlying Batch.
+//
+// Returns:
+//   - JSONLStats: The number of records run and skipped.
+//   - error: An error reading the input or writing the output.
+func (pool *ClientPool) RunJSONL(ctx context.Context, input io.Reader, output io.Writer, skip map[string]bool, opts BatchOptions) (JSONLStats, error) {
+	var stats JSONLStats
+	var outputMu sync.Mutex
+	var outputErr error
+	encoder := json.NewEncoder(output)
+	write := func(record JSONLRecord) {
+		outputMu.Lock()
+		defer outputMu.Unlock()
+		if record.Error != "" {
+			stats.Failed++
+		} else {
+			stats.Succeeded++
+		}
+		if outputErr == nil {
+			outputErr = encoder.Encode(record)
+		}
+	}
+	// ids and started times of the requests in the batch by index
+	type pending struct {
+		id      string
+		started time.Time
+	}
+	var pendingMu sync.Mutex
+	inFlight := make(map[int]pending)
+	requests := make(chan RequestData)
+	var inputErr error
+	inputDone := make(chan struct{})
+	// stop ends reading the input once Batch stops, e.g. with StopOnError
+	stop, stopInput := context.WithCancel(ctx)
+	defer stopInput()
+	go func() {
+		defer close(inputDone)
+		defer close(requests)
+		reader := bufio.NewReader(input)
+		index := 0
+		for lineNumber := 1; stop.Err() == nil; lineNumber++ {
+			line, err := reader.ReadBytes('\n')
+			if err != nil && err != io.EOF {
+				inputErr = err
+				return
+			}
+			if line = bytes.TrimSpace(line); len(line) > 0 {
+				var record jsonlInput
+				id := strconv.Itoa(lineNumber)
+				parseErr := json.Unmarshal(line, &record)
+				if parseErr == nil {
+					id, parseErr = jsonlID(record.ID, id)
+				}
+				switch {
+				case skip[id]:
+					outputMu.Lock()
+					stats.Skipped++
+					outputMu.Unlock()
+				case parseErr != nil:
+					write(JSONLRecord{ID: id, Error: fmt.Sprintf("line %d: %v", lineNumber, parseErr), Started: time.Now()})
+				default:
+					pendingMu.Lock()
+					inFlight[index] = pending{id: id, started: time.Now()}
+					pendingMu.Unlock()
+					select {
+					case requests <- record.RequestData:
+						index++
+					case <-stop.Done():
+						pendingMu.Lock()
+						delete(inFlight, index)
+						pendingMu.Unlock()
+						return
+					}
+				}
+			}
+			if err == io.EOF {
+				return
+			}
+		}
+	}()
+	for result := range pool.Batch(ctx, requests, opts) {
+		pendingMu.Lock()
+		request := inFlight[result.Index]
+		delete(inFlight, result.Index)
+		pendingMu.Unlock()
+		record := JSONLRecord{
+			ID:         request.id,
+			Started:    request.started,
+			DurationMs: float64(result.Duration) / float64(time.Millisecond),
+		}
+		if result.Response.Client != nil {
+			record.ClientID = result.Response.Client.ID()
+		}
+		if result.Err != nil {
+			record.Error = result.Err.Error()
+		} else {
+			response := result.Response
+			record.Response = &response
+		}
+		write(record)
+	}
+	stopInput()
+	<-inputDone
+	if inputErr != nil {
+		return stats, inputErr
+	}
+	if outputErr != nil {
+		return stats, outputErr
+	}
+	return stats, ctx.Err()
+}
+
+// RunJSONLFile runs the requests of a JSONL file with RunJSONL, appending the
+// results to a JSONL output file.
+//
+// The run is resumable. Records whose id was completed without an error in the
+// output file are skipped, and a partially written last line is removed.
+// Failed records are retried, their earlier failures are left in the output so
+// the last record written for an id is its outcome.
+//
+// Parameters:
+//   - ctx (context.Context): The context for the requests.
+//   - inputPath (string): The path of the JSONL requests.
+//   - outputPath (string): The path of the JSONL results. Created if it does not exist.
+//   - opts (BatchOptions): The options of the underlying Batch.
+//
+// Returns:
+//   - JSONLStats: The number of records run and skipped.
+//   - error: An error, if any, reading the input or writing the output.
+func (pool *ClientPool) RunJSONLFile(ctx context.Context, inputPath, outputPath string, opts BatchOptions) (JSONLStats, error) {
+	input, err := os.Open(inputPath)
+	if err != nil {
+		return JSONLStats{}, err
+	}
+	defer input.Close()
+	output, err := os.OpenFile(outputPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return JSONLStats{}, err
+	}
+	defer output.Close()
+	skip, err := completedJSONLIDs(output)
+	if err != nil {
+		return JSONLStats{}, fmt.Errorf("reading %s: %w", outputPath, err)
+	}
+	return pool.RunJSONL(ctx, input, output, skip, opts)
+}
+
+// completedJSONLIDs returns the ids recorded without an error in a JSONL output
+// file and leaves the file positioned for appending after the last complete line.
+func completedJSONLIDs(output *os.File) (map[string]bool, error) {
+	ids := make(map[string]bool)
+	reader := bufio.NewReader(output)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// Drop a partially written last line
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			var record JSONLRecord
+			if err := json.Unmarshal(line, &record); err != nil {
+				return nil, fmt.Errorf("offset %d: %w", offset, err)
+			}
+			// Failed records are run again
+			if record.Error == "" {
+				ids[record.ID] = true
+			}
+		}
+		offset += int64(len(line))
+	}
+	if err := output.Truncate(offset); err != nil {
+		return nil, err
+	}
+	_, err := output.Seek(offset, io.SeekStart)
+	return ids, err
+}
+
+// jsonlID returns the id of an input record as a string.
+func jsonlID(raw json.RawMessage, lineID string) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return lineID, nil
+	}
+	var id string
+	if err := json.Unmarshal(raw, &id); err == nil {
+		return id, nil
+	}
+	var number json.Number
+	if err := json.Unmarshal(raw, &number); err == nil {
+		return number.String(), nil
+	}
+	return lineID, errors.New("id must be a string or number")
+}
@@ -0,0 +1,131 @@
+package HttpClientPool
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunJSONLFile(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "requests.jsonl")
+	outputPath := filepath.Join(dir, "responses.jsonl")
+	input := strings.Join([]string{
+		`{"id": "first", "Type": "GET", "Url": "` + server.URL + `/first"}`,
+		``,
+		`{"Type": "GET", "Url": "` + server.URL + `/line"}`,
+		`{"id": 7, "Type": "POST", "Url": "` + server.URL + `/seven", "JsonData": {"a": 1}}`,
+		`{not json`,
+	}, "\n")
+	if err := os.WriteFile(inputPath, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pool := NewClientPool(0, 0, nil, nil)
+	stats, err := pool.RunJSONLFile(context.Background(), inputPath, outputPath, BatchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (JSONLStats{Succeeded: 3, Failed: 1}) {
+		t.Fatalf("Unexpected stats %+v", stats)
+	}
+	records := readJSONLRecords(t, outputPath)
+	for id, body := range map[string]string{"first": "/first", "3": "/line", "7": "/seven"} {
+		record, exists := records[id]
+		if !exists || record.Response == nil || string(record.Response.Body) != body {
+			t.Fatalf("Unexpected record %q: %+v", id, record)
+		}
+		if record.ClientID != pool.Clients[0].ID() {
+			t.Fatalf("Expected client id %d, got %d", pool.Clients[0].ID(), record.ClientID)
+		}
+	}
+	if record := records["5"]; record.Error == "" || record.Response != nil {
+		t.Fatalf("Expected a parse error for line 5, got %+v", record)
+	}
+	// Simulate a crash while writing, fix the failed record and add a new record
+	output, _ := os.OpenFile(outputPath, os.O_APPEND|os.O_WRONLY, 0644)
+	output.WriteString(`{"id": "par`)
+	output.Close()
+	input = strings.Replace(input, `{not json`, `{"Type": "GET", "Url": "`+server.URL+`/fixed"}`, 1)
+	input += "\n" + `{"id": "new", "Type": "GET", "Url": "` + server.URL + `/new"}`
+	os.WriteFile(inputPath, []byte(input), 0644)
+	hits.Store(0)
+	stats, err = pool.RunJSONLFile(context.Background(), inputPath, outputPath, BatchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (JSONLStats{Succeeded: 2, Skipped: 3}) || hits.Load() != 2 {
+		t.Fatalf("Expected the failed and new records to run, got %+v with %d requests", stats, hits.Load())
+	}
+	// The retried record is written after its failure
+	records = readJSONLRecords(t, outputPath)
+	if len(records) != 5 || records["5"].Response == nil || string(records["5"].Response.Body) != "/fixed" {
+		t.Fatalf("Expected 5 records with line 5 retried, got %+v", records)
+	}
+}
+
+// Stopping on an error ends the run without reading the rest of the input
+func TestRunJSONLStopOnError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	// Requests to the closed server fail
+	server.Close()
+	var lines []string
+	for i := 0; i < 20; i++ {
+		lines = append(lines, `{"Type": "GET", "Url": "`+server.URL+`"}`)
+	}
+	pool := NewClientPool(0, 0, nil, nil)
+	var output strings.Builder
+	done := make(chan JSONLStats)
+	go func() {
+		stats, err := pool.RunJSONL(context.Background(), strings.NewReader(strings.Join(lines, "\n")), &output, nil,
+			BatchOptions{Workers: 1, StopOnError: true})
+		if err != nil {
+			t.Error(err)
+		}
+		done <- stats
+	}()
+	select {
+	case stats := <-done:
+		if stats.Failed == 0 || stats.Failed >= len(lines) || stats.Succeeded != 0 {
+			t.Fatalf("Expected the run to stop after the first failures, got %+v", stats)
+		}
+		if written := strings.Count(output.String(), "\n"); written != stats.Failed {
+			t.Fatalf("Expected %d records, got %d", stats.Failed, written)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunJSONL did not return after stopping on an error")
+	}
+}
+
+// readJSONLRecords reads the records of a JSONL output file by id.
+func readJSONLRecords(t *testing.T, path string) map[string]JSONLRecord {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records := make(map[string]JSONLRecord)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record JSONLRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("Malformed output line %q: %v", scanner.Text(), err)
+		}
+		records[record.ID] = record
+	}
+	return records
+}
@@ -4,7 +4,10 @@ package HttpClientPool
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"reflect"
 	"testing"
 	"time"
 
@@ -14,7 +17,9 @@ import (
 // Tests a client with no pool
 func TestBareClientRatelimiting(t *testing.T) {
 	// Create Client
+	clock := NewFakeClock(time.Unix(0, 0))
 	client := NewClient(nil, "HttpClient", 0)
+	client.SetClock(clock)
 	// Test active/inactive blocking
 	client.SetActive()
 	if client.IsAvailable() {
@@ -28,10 +33,11 @@ func TestBareClientRatelimiting(t *testing.T) {
 	client.SetDelay(10 * time.Millisecond)
 	client.SetActive()
 	client.SetInactive()
+	clock.Advance(9 * time.Millisecond)
 	if client.IsAvailable() {
 		t.Error("Client should not be available yet.")
 	}
-	time.Sleep(10 * time.Millisecond)
+	clock.Advance(time.Millisecond)
 	if !client.IsAvailable() {
 		t.Error("Client should be available but is not.")
 	}
@@ -40,39 +46,40 @@ func TestBareClientRatelimiting(t *testing.T) {
 // Test a single client pool
 func TestSingleClientPoolRatelimiting(t *testing.T) {
 	// Create pool with no client or pool delay
+	clock := NewFakeClock(time.Unix(0, 0))
+	clock.SetAutoAdvance(true)
 	pool := NewClientPool(0, 0, nil, nil)
+	pool.SetClock(clock)
 	// Get client from pool
-	tic := time.Now()
+	tic := clock.Now()
 	client := pool.GetClient()
 	client.SetInactive()
 	client = pool.GetClient()
-	// Should have taken 0 (rounded down) milliseconds
-	timeSpent := time.Now().Sub(tic) / time.Millisecond
-	if timeSpent != 0 {
-		t.Errorf("GetClient took an unexpected amount of time (%d)", timeSpent)
+	// Should have taken no time
+	if timeSpent := clock.Now().Sub(tic); timeSpent != 0 {
+		t.Errorf("GetClient took an unexpected amount of time (%s)", timeSpent)
 	}
 	client.SetInactive()
 	// Test client delay
 	clientDelay := Utils.MillisecondToDuration(10)
 	pool.SetClientDelay(clientDelay)
-	tic = time.Now()
+	tic = clock.Now()
 	client.SetActive() // Reset lastReqTime
 	client.SetInactive()
 	client = pool.GetClient()
-	timeSpent = time.Now().Sub(tic) / time.Millisecond
-	if timeSpent < 10 || timeSpent > 11 {
-		t.Errorf("GetClient took an unexpected amount of time (%d)", timeSpent)
+	if timeSpent := clock.Now().Sub(tic); timeSpent != clientDelay {
+		t.Errorf("GetClient took an unexpected amount of time (%s)", timeSpent)
 	}
+	client.SetInactive()
 	// Repeat previous test with pool delay
 	poolDelay := Utils.MillisecondToDuration(20)
 	pool.SetPoolDelay(poolDelay)
-	tic = time.Now()
+	tic = clock.Now()
 	client.SetActive() // Reset lastReqTime
 	client.SetInactive()
 	client = pool.GetClient()
-	timeSpent = time.Now().Sub(tic) / time.Millisecond
-	if timeSpent < 20 || timeSpent > 21 {
-		t.Errorf("GetClient took an unexpected amount of time (%d)", timeSpent)
+	if timeSpent := clock.Now().Sub(tic); timeSpent != poolDelay {
+		t.Errorf("GetClient took an unexpected amount of time (%s)", timeSpent)
 	}
 }
 
@@ -88,62 +95,85 @@ func TestMultiClientPoolRatelimiting(t *testing.T) {
 	for i := 0; i < proxyCount; i++ {
 		proxies[i] = dummyProxy
 	}
+	clock := NewFakeClock(time.Unix(0, 0))
+	clock.SetAutoAdvance(true)
 	pool := NewClientPool(0, 0, proxies, nil)
+	pool.SetClock(clock)
 	for idx, client := range pool.Clients {
 		client.SetUserAgent(fmt.Sprintf("Client #%d", idx+1))
 	}
-	// Make 100 requests with no ratelimit
-	tic := time.Now()
-	for i := 0; i < 100; i++ {
-		client := pool.GetClient()
-		go func() {
+	// makeRequests makes 100 requests which each take 5ms and returns the time
+	// until the last request started. GetClient waiting for a busy client moves
+	// the clock to the end of the next request, so the times follow from the
+	// delays alone
+	makeRequests := func() time.Duration {
+		tic := clock.Now()
+		for i := 0; i < 100; i++ {
+			client := pool.GetClient()
 			// Simulating a request which takes 5ms
-			time.Sleep(time.Millisecond * 5)
-			client.SetInactive()
-		}()
+			clock.AfterFunc(5*time.Millisecond, client.SetInactive)
+		}
+		return clock.Now().Sub(tic)
 	}
-	// With 10 clients with 5ms request time each it would be possible to make a
-	// request every 0.5ms or 100 requests in 50ms
-	timeSpent := time.Now().Sub(tic) / time.Millisecond
-	if timeSpent < 50 || timeSpent > 60 { // Should take 5 ms per request
-		t.Errorf("Requests took an unexpected amount of time (%d)", timeSpent)
+	// With no ratelimit 10 clients with 5ms request time each make 10 requests
+	// every 5ms, so the last of the 10 rounds starts after 45ms
+	if timeSpent := makeRequests(); timeSpent != 45*time.Millisecond {
+		t.Errorf("Requests took an unexpected amount of time (%s)", timeSpent)
 	}
-
-	// Make 100 requests with a 10ms client ratelimit
+	// With a 10ms client ratelimit the 10 clients make 10 requests every 10ms.
+	// The first round waits 10ms for the previous round, so the last starts after 100ms
 	pool.SetClientDelay(10 * time.Millisecond)
-	tic = time.Now()
-	for i := 0; i < 100; i++ {
-		client := pool.GetClient()
-		go func() {
-			// Simulating a request which takes 5ms
-			time.Sleep(time.Millisecond * 5)
-			client.SetInactive()
-		}()
-	}
-	// With 10 clients with 10ms request time each it would be possible to make a
-	// request every 1ms or 100 requests in 100 ms.
-	timeSpent = time.Now().Sub(tic) / time.Millisecond
-	if timeSpent < 100 || timeSpent > 110 { // Should take 10 ms per request
-		t.Errorf("Requests took an unexpected amount of time (%d)", timeSpent)
+	if timeSpent := makeRequests(); timeSpent != 100*time.Millisecond {
+		t.Errorf("Requests took an unexpected amount of time (%s)", timeSpent)
 	}
-	// Make 100 requests with an additional 5ms pool ratelimit
+	// An additional 2ms pool ratelimit caps this to one request every 2ms. The
+	// first request waits 10ms for the previous round, the other 99 are 2ms apart
 	pool.SetPoolDelay(2 * time.Millisecond)
-	tic = time.Now()
-	for i := 0; i < 100; i++ {
-		client := pool.GetClient()
-		go func() {
-			// Simulating a request which takes 5ms
-			time.Sleep(time.Millisecond * 5)
+	if timeSpent := makeRequests(); timeSpent != 208*time.Millisecond {
+		t.Errorf("Requests took an unexpected amount of time (%s)", timeSpent)
+	}
+	// Clock still drives the real pool
+	if clock.Now().Sub(time.Unix(0, 0)) != 353*time.Millisecond {
+		t.Errorf("Unexpected fake time %s", clock.Now())
+	}
+}
+
+// Tests per client and pool wide in-flight limits
+func TestMaxInFlight(t *testing.T) {
+	pool := NewClientPool(0, 0, nil, nil)
+	pool.AddClient(NewClient(nil, "HttpClient", 0))
+	// Allow three concurrent requests per client
+	pool.SetClientMaxInFlight(3)
+	for i := 0; i < 6; i++ {
+		pool.GetClient()
+	}
+	for _, client := range pool.Clients {
+		if client.GetInFlight() != 3 || client.IsAvailable() {
+			t.Errorf("Unexpected client in-flight count %d", client.GetInFlight())
+		}
+	}
+	for _, client := range pool.Clients {
+		for i := 0; i < 3; i++ {
 			client.SetInactive()
-		}()
+		}
+	}
+	// Cap the whole pool at two requests
+	pool.SetMaxInFlight(2)
+	first, second := pool.GetClient(), pool.GetClient()
+	if pool.GetInFlight() != 2 {
+		t.Errorf("Unexpected pool in-flight count %d", pool.GetInFlight())
 	}
-	// With 10 clients with 10ms request time each it would be possible to make a
-	// request every 1ms or 100 requests in 100 ms. The pool delay of 2ms instead
-  // caps this to one request every 2ms
-	timeSpent = time.Now().Sub(tic) / time.Millisecond
-	if timeSpent < 200 || timeSpent > 250 { // Should take 10 ms per request
-		t.Errorf("Requests took an unexpected amount of time (%d)", timeSpent)
+	if client, _ := pool.tryHandout(GetClientOptions{}); client != nil {
+		t.Error("Pool should be at its in-flight limit")
 	}
+	first.SetInactive()
+	if client, _ := pool.tryHandout(GetClientOptions{}); client == nil {
+		t.Error("Pool should be below its in-flight limit")
+	} else {
+		client.SetInactive()
+	}
+	second.SetInactive()
+	pool.Done()
 }
 
 // */
@@ -182,3 +212,64 @@ func TestAddRemoveClients(t *testing.T) {
 		t.Errorf("Incorrect pool size. Expected 0 got %d", len(pool.Clients))
 	}
 }
+
+// Tests bursting with a rate from Utils.ParseRate
+func TestRateBurst(t *testing.T) {
+	rate, err := Utils.ParseRate("10/s burst 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(nil, "", 0)
+	client.SetMaxInFlight(0)
+	client.SetRate(rate)
+	if client.GetDelay() != 100*time.Millisecond || client.GetBurst() != 3 {
+		t.Fatalf("Unexpected delay %s burst %d", client.GetDelay(), client.GetBurst())
+	}
+	// Three requests may be sent back to back
+	for i := 0; i < 3; i++ {
+		if activated, _ := client.tryActivate(); !activated {
+			t.Fatalf("Expected request %d of the burst to be allowed", i+1)
+		}
+	}
+	if client.IsAvailable() {
+		t.Fatal("Expected the burst to be exhausted")
+	}
+	// The pool bursts the same way
+	pool := NewClientPool(0, 0, nil, nil)
+	pool.SetClientMaxInFlight(0)
+	pool.SetPoolRate(rate)
+	for i := 0; i < 3; i++ {
+		if handout, _ := pool.tryHandout(GetClientOptions{}); handout == nil {
+			t.Fatalf("Expected handout %d of the burst", i+1)
+		}
+	}
+	if handout, wait := pool.tryHandout(GetClientOptions{}); handout != nil || wait <= 0 || wait > 100*time.Millisecond {
+		t.Fatalf("Expected to wait for the pool rate, got wait %s", wait)
+	}
+}
+
+// Tests the pool sets its client inactive once a QuickRequest is complete
+func TestPoolQuickRequestSetsInactive(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	pool := NewClientPool(0, 0, nil, map[string]float32{"HttpPoolClient": 1})
+	if _, err := pool.QuickRequest(RequestData{Type: "GET", Url: server.URL}); err != nil {
+		t.Fatal(err)
+	}
+	if pool.Clients[0].IsRunning() {
+		t.Error("Expected the client to be inactive after the request")
+	}
+}
+
+// Tests clients without a proxy use the proxy from the environment
+func TestClientEnvironmentProxy(t *testing.T) {
+	client := NewClient(nil, "HttpClient", 0)
+	if reflect.ValueOf(client.transport.Proxy).Pointer() != reflect.ValueOf(http.ProxyFromEnvironment).Pointer() {
+		t.Error("Expected the client to use http.ProxyFromEnvironment")
+	}
+	proxy, _ := url.Parse("http://127.0.0.1:3128")
+	client = NewClient(proxy, "HttpClient", 0)
+	if used, _ := client.transport.Proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: "example.com"}}); used != proxy {
+		t.Errorf("Expected proxy %s, got %s", proxy, used)
+	}
+}
@@ -0,0 +1,66 @@
+package HttpClientPool
+
+import (
+	"context"
+)
+
+// Handler runs a RequestData and returns the response.
+type Handler func(ctx context.Context, reqData RequestData) (ResponseData, error)
+
+// Middleware wraps a Handler to run code before and after a request.
+//
+// A Middleware may modify the RequestData before calling next, inspect or modify
+// the ResponseData returned by next, or return a response without calling next.
+// ResponseData.Client reports which Client handled the request.
+//
+// Example:
+//
+//	pool.Use(func(next HttpClientPool.Handler) HttpClientPool.Handler {
+//		return func(ctx context.Context, reqData HttpClientPool.RequestData) (HttpClientPool.ResponseData, error) {
+//			response, err := next(ctx, reqData)
+//			log.Println(reqData.Url, response.Status, err)
+//			return response, err
+//		}
+//	})
+type Middleware func(next Handler) Handler
+
+// chainMiddleware wraps a Handler in middleware so the first middleware runs first.
+//
+// Parameters:
+//   - handler (Handler): The innermost handler.
+//   - middleware ([]Middleware): The middleware in the order it should run.
+//
+// Returns:
+//   - Handler: The wrapped handler.
+func chainMiddleware(handler Handler, middleware []Middleware) Handler {
+	for idx := len(middleware) - 1; idx >= 0; idx-- {
+		handler = middleware[idx](handler)
+	}
+	return handler
+}
+
+// Use appends middleware to the client's QuickRequest pipeline.
+//
+// Client middleware runs for every QuickRequest made with the client,
+// including requests made through a ClientPool.
+//
+// Parameters:
+//   - middleware (...Middleware): The middleware in the order it should run.
+func (client *Client) Use(middleware ...Middleware) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.middleware = append(client.middleware, middleware...)
+}
+
+// Use appends middleware to the pool's QuickRequest pipeline.
+//
+// Pool middleware runs before a Client is fetched from the pool, so it can
+// return cached responses without using a Client.
+//
+// Parameters:
+//   - middleware (...Middleware): The middleware in the order it should run.
+func (pool *ClientPool) Use(middleware ...Middleware) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	pool.middleware = append(pool.middleware, middleware...)
+}
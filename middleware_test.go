@@ -0,0 +1,67 @@
+package HttpClientPool
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte(r.Header.Get("X-Injected")))
+	}))
+	defer server.Close()
+	pool := NewClientPool(0, 0, nil, nil)
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, reqData RequestData) (ResponseData, error) {
+				order = append(order, name)
+				return next(ctx, reqData)
+			}
+		}
+	}
+	// Cache responses by url
+	cache := make(map[string]ResponseData)
+	pool.Use(trace("pool"), func(next Handler) Handler {
+		return func(ctx context.Context, reqData RequestData) (ResponseData, error) {
+			if response, exists := cache[reqData.Url]; exists {
+				return response, nil
+			}
+			response, err := next(ctx, reqData)
+			if err == nil {
+				cache[reqData.Url] = response
+			}
+			return response, err
+		}
+	})
+	// Inject a header
+	pool.Clients[0].Use(trace("client"), func(next Handler) Handler {
+		return func(ctx context.Context, reqData RequestData) (ResponseData, error) {
+			reqData.Headers = map[string][]string{"X-Injected": {"1"}}
+			return next(ctx, reqData)
+		}
+	})
+	for i := 0; i < 3; i++ {
+		response, err := pool.QuickRequest(RequestData{Type: "GET", Url: server.URL})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(response.Body) != "1" {
+			t.Errorf("Unexpected body %s", response.Body)
+		}
+		if response.Client != pool.Clients[0] {
+			t.Errorf("Unexpected client %p", response.Client)
+		}
+	}
+	if hits.Load() != 1 {
+		t.Errorf("Expected 1 request to reach the server got %d", hits.Load())
+	}
+	if len(order) != 4 || order[0] != "pool" || order[1] != "client" || order[2] != "pool" {
+		t.Errorf("Unexpected middleware order %v", order)
+	}
+}
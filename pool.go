@@ -23,17 +23,35 @@
 // Features:
 //   - Dynamic client pool creation with customizable delays.
 //   - Rate-limiting for individual clients and the entire pool.
+//   - Jittered request spacing to avoid perfectly periodic requests.
+//   - Injectable clocks for deterministic ratelimiting tests.
+//   - Record and replay of requests with cassette files.
+//   - HAR export of requests and replay of browser-captured HAR flows.
 //   - Automatic proxy rotation by ratelimit.
+//   - Per client TLS ClientHello profiles matching the user-agent.
+//   - Priority queueing of requests waiting for a client.
+//   - Weighted fair scheduling and quotas for tenants sharing a pool.
+//   - Bounded worker pool batches with optionally ordered results.
+//   - Resumable batches of requests from JSONL files.
 //
 // GitHub repository: https://github.com/RootInit/HttpClientPool
 package HttpClientPool
 
 import (
+	"context"
+	"errors"
 	"github.com/RootInit/HttpClientPool/Utils"
+	"math/rand"
 	"net/url"
+	"slices"
+	"sync"
 	"time"
 )
 
+// ErrNoClients is returned when a ClientPool has no clients to hand out, for
+// example after BanPolicy.RetireAfter has removed every client.
+var ErrNoClients = errors.New("no clients in pool")
+
 // ClientPool represents a pool of HTTP clients with easy per client and
 // whole pool ratelimiting.
 //
@@ -43,6 +61,29 @@ type ClientPool struct {
 	// Clients is a slice containing pointers to the clients in the pool.
 	Clients []*Client
 	delay   time.Duration
+	// burst is the number of requests the pool may send back to back.
+	burst int
+	// jitter randomises the pool delay, nil for a fixed delay.
+	jitter Jitter
+	rng    *rand.Rand
+	// spacing is the jittered pool delay following the last request.
+	spacing time.Duration
+	// tat is the theoretical arrival time of the next request when bursting.
+	tat        time.Time
+	middleware []Middleware
+	banPolicy  BanPolicy
+	banCounts  map[string]int
+	adaptive   *adaptiveLimiter
+	// queue holds the goroutines waiting in GetClientContext.
+	queue waitQueue
+	// quotas paces requests using rate limit headers, nil if disabled.
+	quotas *quotaTracker
+	// maxInFlight limits concurrent requests across the pool, 0 for no limit.
+	maxInFlight int
+	// clock tells the time for ratelimiting.
+	clock     Clock
+	mu        sync.Mutex
+	handoutMu sync.Mutex
 }
 
 // NewClientPool creates a pool of HTTP clients for concurrent requests.
@@ -73,6 +114,7 @@ func NewClientPool(clientDelay, poolDelay time.Duration, proxies []*url.URL, use
 	return ClientPool{
 		Clients: clients,
 		delay:   poolDelay,
+		clock:   RealClock,
 	}
 }
 
@@ -83,7 +125,10 @@ func NewClientPool(clientDelay, poolDelay time.Duration, proxies []*url.URL, use
 // Parameters:
 //   - client (*Client): The HTTP client to be added to the pool.
 func (pool *ClientPool) AddClient(client *Client) {
-	pool.Clients = append(pool.Clients, client)
+	pool.mu.Lock()
+	pool.Clients = append(pool.Clients[:len(pool.Clients):len(pool.Clients)], client)
+	pool.mu.Unlock()
+	pool.wake()
 }
 
 // RemoveClient removes a specific HTTP client from the client pool.
@@ -95,20 +140,76 @@ func (pool *ClientPool) AddClient(client *Client) {
 // Parameters:
 //   - client (*Client): The HTTP client to be removed from the pool.
 func (pool *ClientPool) RemmoveClient(client *Client) {
+	pool.mu.Lock()
+	removed, remaining := false, false
 	for idx, c := range pool.Clients {
 		// Compare pointer addresses
-		if c == client {
-			// Check if last element
-			if idx < len(pool.Clients)-1 {
-				// Remove from slice
-				pool.Clients = append(pool.Clients[:idx], pool.Clients[idx+1:]...)
-			} else {
-				// Remove the last element
-				pool.Clients = pool.Clients[:idx]
-			}
-			break
+		if c == client && !removed {
+			// Copy so slices returned by getClients are unchanged
+			clients := make([]*Client, 0, len(pool.Clients)-1)
+			clients = append(clients, pool.Clients[:idx]...)
+			pool.Clients = append(clients, pool.Clients[idx+1:]...)
+			removed = true
+		} else if c == client {
+			remaining = true
+		}
+	}
+	pool.mu.Unlock()
+	if removed && !remaining {
+		client.unwatch(pool)
+	}
+	pool.wake()
+}
+
+// hasClients returns true if the pool has a client which is not excluded.
+func (pool *ClientPool) hasClients(exclude []*Client) bool {
+	for _, client := range pool.getClients() {
+		if !slices.Contains(exclude, client) {
+			return true
+		}
+	}
+	return false
+}
+
+// quotaWait returns the time until the host quota of any client allows a request.
+//
+// Parameters:
+//   - opts (GetClientOptions): The options of the waiter.
+//
+// Returns:
+//   - time.Duration: The time until a client may request the host, 0 if one may
+//     now or quotas are not tracked.
+func (pool *ClientPool) quotaWait(opts GetClientOptions) time.Duration {
+	pool.mu.Lock()
+	quotas, now := pool.quotas, pool.clock.Now()
+	pool.mu.Unlock()
+	if quotas == nil || opts.Host == "" {
+		return 0
+	}
+	var wait time.Duration
+	for _, client := range pool.getClients() {
+		if slices.Contains(opts.Exclude, client) {
+			continue
+		}
+		clientWait := quotas.allow(client.quotaKey(opts.Host), now)
+		if clientWait == 0 {
+			return 0
+		}
+		if wait == 0 || clientWait < wait {
+			wait = clientWait
 		}
 	}
+	return wait
+}
+
+// getClients returns the current clients in the pool.
+//
+// The returned slice is never modified by AddClient or RemmoveClient so it can
+// be iterated while clients are added or removed.
+func (pool *ClientPool) getClients() []*Client {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	return pool.Clients
 }
 
 // SetPoolDelay sets the minimum delay between requests from all clients in the pool.
@@ -116,7 +217,98 @@ func (pool *ClientPool) RemmoveClient(client *Client) {
 // Parameters:
 //   - poolDelay (time.Duration): The new shared delay. Use 0 for no delay.
 func (pool *ClientPool) SetPoolDelay(poolDelay time.Duration) {
+	pool.mu.Lock()
 	pool.delay = poolDelay
+	pool.mu.Unlock()
+	pool.wake()
+}
+
+// GetPoolDelay returns the minimum delay between requests from all clients in the pool.
+//
+// Returns:
+//   - time.Duration: The duration of the pool delay
+func (pool *ClientPool) GetPoolDelay() time.Duration {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	return pool.delay
+}
+
+// SetPoolRate sets the pool delay and burst from a rate.
+//
+// Parameters:
+//   - rate (Utils.Rate): The rate, e.g. from Utils.ParseRate. Use the zero Rate for no limit.
+func (pool *ClientPool) SetPoolRate(rate Utils.Rate) {
+	pool.mu.Lock()
+	pool.delay = rate.Interval()
+	pool.burst = rate.Burst
+	pool.mu.Unlock()
+	pool.wake()
+}
+
+// SetClientRate sets the delay and burst of each client in the pool from a rate.
+//
+// Parameters:
+//   - rate (Utils.Rate): The rate, e.g. from Utils.ParseRate. Use the zero Rate for no limit.
+func (pool *ClientPool) SetClientRate(rate Utils.Rate) {
+	for _, client := range pool.getClients() {
+		client.SetRate(rate)
+	}
+}
+
+// SetPoolJitter randomises the pool delay between requests.
+//
+// The pool delay before each request is sampled from the jitter, so the
+// average rate still matches the configured pool delay.
+//
+// Parameters:
+//   - jitter (Jitter): The jitter, e.g. ExponentialJitter(). Use nil for a fixed delay.
+//   - seed (int64): The random seed. Equal seeds give equal delays, use
+//     time.Now().UnixNano() for unpredictable delays.
+func (pool *ClientPool) SetPoolJitter(jitter Jitter, seed int64) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	pool.jitter = jitter
+	pool.rng = newJitterRand(seed)
+}
+
+// SetClientJitter randomises the delay between requests for each client in the pool.
+//
+// Each client samples from its own random source seeded from seed and its
+// position in the pool.
+//
+// Parameters:
+//   - jitter (Jitter): The jitter, e.g. LogNormalJitter(0.5). Use nil for a fixed delay.
+//   - seed (int64): The random seed. Equal seeds give equal delays, use
+//     time.Now().UnixNano() for unpredictable delays.
+func (pool *ClientPool) SetClientJitter(jitter Jitter, seed int64) {
+	for i, client := range pool.getClients() {
+		client.SetJitter(jitter, seed+int64(i))
+	}
+}
+
+// SetClock sets the clock used for ratelimiting by the pool and each client in the pool.
+//
+// Clients added to the pool afterwards keep their own clock.
+//
+// Parameters:
+//   - clock (Clock): The clock, e.g. a FakeClock in tests. Use RealClock for the time package.
+func (pool *ClientPool) SetClock(clock Clock) {
+	pool.mu.Lock()
+	pool.clock = clock
+	pool.mu.Unlock()
+	for _, client := range pool.getClients() {
+		client.SetClock(clock)
+	}
+}
+
+// GetClock returns the clock used for ratelimiting by the pool.
+//
+// Returns:
+//   - Clock: The pool clock.
+func (pool *ClientPool) GetClock() Clock {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	return pool.clock
 }
 
 // SetClientDelay sets the individual delay between requests for each client in the pool.
@@ -124,45 +316,169 @@ func (pool *ClientPool) SetPoolDelay(poolDelay time.Duration) {
 // Parameters:
 //   - clientDelay (time.Duration): The new shared delay. Use 0 for no delay.
 func (pool *ClientPool) SetClientDelay(clientDelay time.Duration) {
-	for _, client := range pool.Clients {
+	for _, client := range pool.getClients() {
 		client.SetDelay(clientDelay)
 	}
 }
 
+// SetTLSProfile sets the TLS ClientHello and HTTP/2 profile of each client in the pool.
+//
+// Parameters:
+//   - profile (*TLSProfile): The profile to use. Use nil for the Go defaults.
+func (pool *ClientPool) SetTLSProfile(profile *TLSProfile) {
+	for _, client := range pool.getClients() {
+		client.SetTLSProfile(profile)
+	}
+}
+
+// MatchTLSProfiles sets the TLS ClientHello and HTTP/2 profile of each client in the pool
+// to the built-in profile matching the browser in its user agent.
+//
+// Clients with an unrecognised user agent use the Go defaults.
+func (pool *ClientPool) MatchTLSProfiles() {
+	for _, client := range pool.getClients() {
+		client.SetTLSProfile(TLSProfileForUserAgent(client.GetUserAgent()))
+	}
+}
+
 // GetClient returns an available HTTP client from the pool.
 // The client is set as active and the lastReqTime is set to time.Now.
 //
-// This method blocks until a client becomes available in the pool.
+// This method blocks until a client becomes available in the pool and the
+// pool is below its in-flight limit.
 //
 // Returns:
-//   - *Client: A pointer to the available HTTP client.
+//   - *Client: A pointer to the available HTTP client, nil if the pool has no clients.
 func (pool *ClientPool) GetClient() *Client {
+	client, _ := pool.GetClientContext(context.Background(), GetClientOptions{})
+	return client
+}
+
+// tryHandout activates and returns an available client without blocking.
+//
+// Parameters:
+//   - opts (GetClientOptions): The options of the waiter.
+//
+// Returns:
+//   - *Client: The activated client, nil if no client is available, the pool
+//     delay has not elapsed or the pool is at its in-flight limit.
+//   - time.Duration: The time until the pool delay elapses or the first
+//     ratelimited client or host quota becomes available, 0 if only a request
+//     finishing can free a client.
+func (pool *ClientPool) tryHandout(opts GetClientOptions) (*Client, time.Duration) {
+	pool.handoutMu.Lock()
+	defer pool.handoutMu.Unlock()
+	clients := pool.getClients()
 	// Calculate time since last request
 	var lastReqTime time.Time
-	for _, client := range pool.Clients {
+	inFlight := 0
+	for _, client := range clients {
+		// Watch before checking the client so no release is missed
+		client.watch(pool)
 		clientReqTime := client.GetRequestTime()
 		if clientReqTime.After(lastReqTime) {
 			lastReqTime = clientReqTime
 		}
+		inFlight += client.GetInFlight()
 	}
-	lastReqDelta := time.Now().Sub(lastReqTime)
-	if lastReqDelta < pool.delay {
-		// Wait until pool delay elapsed
-		time.Sleep(pool.delay - lastReqDelta)
+	pool.mu.Lock()
+	now := pool.clock.Now()
+	poolDelay, burst, tat, quotas := pool.delay, pool.burst, pool.tat, pool.quotas
+	if pool.jitter != nil {
+		poolDelay = pool.spacing
 	}
-	for {
-		for _, client := range pool.Clients {
-			if client.IsAvailable() {
-				client.SetActive()
-				return client
+	pool.mu.Unlock()
+	if burst > 1 {
+		if wait := burstWait(tat, now, poolDelay, burst); wait > 0 {
+			return nil, wait
+		}
+	} else if lastReqDelta := now.Sub(lastReqTime); lastReqDelta < poolDelay {
+		return nil, poolDelay - lastReqDelta
+	}
+	// Check pool in-flight limit
+	if maxInFlight := pool.GetMaxInFlight(); maxInFlight > 0 && inFlight >= maxInFlight {
+		return nil, 0
+	}
+	var wait time.Duration
+	for _, client := range clients {
+		if slices.Contains(opts.Exclude, client) {
+			continue
+		}
+		// Skip clients which must wait for the host quota
+		var key quotaKey
+		if quotas != nil && opts.Host != "" {
+			key = client.quotaKey(opts.Host)
+			if quotaWait := quotas.allow(key, now); quotaWait > 0 {
+				if wait == 0 || quotaWait < wait {
+					wait = quotaWait
+				}
+				continue
+			}
+		}
+		activated, clientWait := client.tryActivate()
+		if activated {
+			if quotas != nil && opts.Host != "" {
+				quotas.take(key, now)
 			}
+			pool.mu.Lock()
+			pool.spacing = pool.jitter.sample(pool.delay, pool.rng)
+			pool.tat = nextArrival(pool.tat, now, pool.spacing)
+			pool.mu.Unlock()
+			return client, 0
+		}
+		if clientWait > 0 && (wait == 0 || clientWait < wait) {
+			wait = clientWait
 		}
-		time.Sleep(time.Millisecond * 1)
 	}
+	return nil, wait
+}
+
+// SetMaxInFlight sets the maximum number of concurrent requests across all clients in the pool.
+//
+// Parameters:
+//   - maxInFlight (int): The maximum concurrent requests. Use 0 for no limit.
+func (pool *ClientPool) SetMaxInFlight(maxInFlight int) {
+	pool.mu.Lock()
+	pool.maxInFlight = maxInFlight
+	pool.mu.Unlock()
+	pool.wake()
+}
+
+// GetMaxInFlight returns the maximum number of concurrent requests across all clients in the pool.
+//
+// Returns:
+//   - int: The pool in-flight limit, 0 for no limit
+func (pool *ClientPool) GetMaxInFlight() int {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	return pool.maxInFlight
+}
+
+// SetClientMaxInFlight sets the maximum number of concurrent requests for each client in the pool.
+//
+// Parameters:
+//   - maxInFlight (int): The maximum concurrent requests per client. Use 0 for no limit.
+func (pool *ClientPool) SetClientMaxInFlight(maxInFlight int) {
+	for _, client := range pool.getClients() {
+		client.SetMaxInFlight(maxInFlight)
+	}
+}
+
+// GetInFlight returns the number of requests currently running across all clients in the pool.
+//
+// Returns:
+//   - int: The number of requests in flight
+func (pool *ClientPool) GetInFlight() int {
+	inFlight := 0
+	for _, client := range pool.getClients() {
+		inFlight += client.GetInFlight()
+	}
+	return inFlight
 }
 
 // QuickRequest is a convenience function which fetches a Client
-// with pool.GetClient and passes the RequestData to client.QuickRequest
+// with pool.GetClient and passes the RequestData to client.QuickRequest.
+// The Client is set inactive when the request is complete.
 //
 // Parameters:
 //   - reqData (RequestData): The RequestData struct containing HTTP request data.
@@ -171,8 +487,83 @@ func (pool *ClientPool) GetClient() *Client {
 //   - ResponseData: A ResponseData struct containing HTTP response data.
 //   - error: An error, if any, encountered during the HTTP request.
 func (pool *ClientPool) QuickRequest(reqData RequestData) (ResponseData, error) {
-	client := pool.GetClient()
-	return client.QuickRequest(reqData)
+	return pool.QuickRequestContext(context.Background(), reqData)
+}
+
+// QuickRequestContext performs QuickRequest with a context controlling the request.
+//
+// Parameters:
+//   - ctx (context.Context): The context for the request.
+//   - reqData (RequestData): The RequestData struct containing HTTP request data.
+//
+// Returns:
+//   - ResponseData: A ResponseData struct containing HTTP response data.
+//   - error: An error, if any, encountered during the HTTP request.
+func (pool *ClientPool) QuickRequestContext(ctx context.Context, reqData RequestData) (ResponseData, error) {
+	pool.mu.Lock()
+	handler := chainMiddleware(pool.quickRequest, pool.middleware)
+	clock := pool.clock
+	pool.mu.Unlock()
+	return handler(ContextWithClock(ctx, clock), reqData)
+}
+
+// quickRequest fetches a Client and runs the request for QuickRequestContext
+// once the pool middleware has run.
+//
+// Parameters:
+//   - ctx (context.Context): The context for the request.
+//   - reqData (RequestData): The RequestData struct containing HTTP request data.
+//
+// Returns:
+//   - ResponseData: A ResponseData struct containing HTTP response data.
+//   - error: An error, if any, encountered during the HTTP request.
+func (pool *ClientPool) quickRequest(ctx context.Context, reqData RequestData) (ResponseData, error) {
+	pool.mu.Lock()
+	banPolicy := pool.banPolicy
+	pool.mu.Unlock()
+	if len(banPolicy.Detectors) > 0 {
+		return pool.banCheck(ctx, reqData, banPolicy)
+	}
+	client, response, err := pool.attempt(ctx, reqData, nil)
+	pool.adapt(client, response, err, false)
+	return response, err
+}
+
+// attempt fetches a Client and runs the request once.
+//
+// Parameters:
+//   - ctx (context.Context): The context for the request.
+//   - reqData (RequestData): The RequestData struct containing HTTP request data.
+//   - exclude ([]*Client): Clients which must not be used.
+//
+// Returns:
+//   - *Client: The Client used for the request, nil if no Client was obtained.
+//   - ResponseData: A ResponseData struct containing HTTP response data.
+//   - error: An error, if any, encountered during the HTTP request.
+func (pool *ClientPool) attempt(ctx context.Context, reqData RequestData, exclude []*Client) (*Client, ResponseData, error) {
+	if !reqData.Deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, reqData.Deadline)
+		defer cancel()
+	}
+	opts := GetClientOptions{Priority: reqData.Priority, Tenant: reqData.Tenant, Exclude: exclude}
+	pool.mu.Lock()
+	quotas := pool.quotas
+	pool.mu.Unlock()
+	if quotas != nil {
+		if parsedUrl, err := url.Parse(reqData.Url); err == nil {
+			opts.Host = parsedUrl.Host
+		}
+		// The client observes the quotas of its response into the pool's
+		ctx = context.WithValue(ctx, quotasKey{}, quotas)
+	}
+	client, err := pool.GetClientContext(ctx, opts)
+	if err != nil {
+		return nil, ResponseData{}, err
+	}
+	defer client.SetInactive()
+	response, err := client.QuickRequestContext(ctx, reqData)
+	return client, response, err
 }
 
 // Done blocks until all clients in the pool are inactive.
@@ -182,7 +573,7 @@ func (pool *ClientPool) QuickRequest(reqData RequestData) (ResponseData, error)
 func (pool *ClientPool) Done() {
 	for {
 		done := true
-		for _, client := range pool.Clients {
+		for _, client := range pool.getClients() {
 			if client.IsRunning() {
 				done = false
 				break
@@ -0,0 +1,63 @@
+package pooltest
+
+import (
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+// AssertUsed fails the test unless a request to target went through the proxy.
+//
+// Parameters:
+//   - tb (testing.TB): The test.
+//   - target (string): The host and port, e.g. server.Host(), or the hostname alone.
+func (proxy *Proxy) AssertUsed(tb testing.TB, target string) {
+	tb.Helper()
+	requests := proxy.Requests()
+	for _, request := range requests {
+		if hostname, _, _ := net.SplitHostPort(request.Target); request.Target == target || hostname == target {
+			return
+		}
+	}
+	targets := make([]string, len(requests))
+	for idx, request := range requests {
+		targets[idx] = request.Target
+	}
+	tb.Errorf("pooltest: expected a request to %s through proxy %s, got %v", target, proxy.url, targets)
+}
+
+// AssertNotUsed fails the test if any request went through the proxy.
+//
+// Parameters:
+//   - tb (testing.TB): The test.
+func (proxy *Proxy) AssertNotUsed(tb testing.TB) {
+	tb.Helper()
+	if requests := proxy.Requests(); len(requests) > 0 {
+		tb.Errorf("pooltest: expected no requests through proxy %s, got %d, the first to %s",
+			proxy.url, len(requests), requests[0].Target)
+	}
+}
+
+// AssertMaxRate fails the test if more than requests requests happened within
+// any window of length per, e.g. AssertMaxRate(t, server.Times(), 10, time.Second)
+// checks the rate never exceeded 10/s.
+//
+// Parameters:
+//   - tb (testing.TB): The test.
+//   - times ([]time.Time): The request times, e.g. from Server.Times or Proxy.Times.
+//   - requests (int): The maximum number of requests in a window.
+//   - per (time.Duration): The length of the window.
+func AssertMaxRate(tb testing.TB, times []time.Time, requests int, per time.Duration) {
+	tb.Helper()
+	sorted := slices.Clone(times)
+	slices.SortFunc(sorted, func(a, b time.Time) int { return a.Compare(b) })
+	// Any requests+1 consecutive requests must span at least per
+	for idx := 0; idx+requests < len(sorted); idx++ {
+		if span := sorted[idx+requests].Sub(sorted[idx]); span < per {
+			tb.Errorf("pooltest: %d requests within %s starting at request %d, expected at most %d per %s",
+				requests+1, span, idx, requests, per)
+			return
+		}
+	}
+}
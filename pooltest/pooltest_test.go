@@ -0,0 +1,121 @@
+package pooltest_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RootInit/HttpClientPool"
+	"github.com/RootInit/HttpClientPool/pooltest"
+)
+
+func TestServers(t *testing.T) {
+	echoServer := pooltest.NewEchoServer(t)
+	response, err := http.Post(echoServer.URL+"/path?one=1", "text/plain", strings.NewReader("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var echo pooltest.Echo
+	json.NewDecoder(response.Body).Decode(&echo)
+	response.Body.Close()
+	if echo.Method != "POST" || echo.URL != "/path" || echo.Params["one"][0] != "1" || echo.Body != "body" {
+		t.Fatalf("Unexpected echo %+v", echo)
+	}
+	if requests := echoServer.Requests(); len(requests) != 1 || string(requests[0].Body) != "body" {
+		t.Fatalf("Unexpected recorded requests %+v", requests)
+	}
+	// Scripted responses repeat the last
+	scripted := pooltest.NewScriptedServer(t,
+		pooltest.Response{Status: http.StatusServiceUnavailable},
+		pooltest.Response{Body: "ok", Header: http.Header{"X-Test": {"1"}}},
+	)
+	for _, expected := range []int{503, 200, 200} {
+		response, err := http.Get(scripted.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(response.Body)
+		response.Body.Close()
+		if response.StatusCode != expected || (expected == 200 && (string(body) != "ok" || response.Header.Get("X-Test") != "1")) {
+			t.Fatalf("Unexpected response %d %q", response.StatusCode, body)
+		}
+	}
+}
+
+func TestProxies(t *testing.T) {
+	server := pooltest.NewEchoServer(t)
+	for _, proxy := range []*pooltest.Proxy{pooltest.NewHTTPProxy(t), pooltest.NewSOCKS5Proxy(t)} {
+		unused := pooltest.NewHTTPProxy(t)
+		pool := HttpClientPool.NewClientPool(0, 0, []*url.URL{proxy.URL()}, nil)
+		get := func() (HttpClientPool.ResponseData, error) {
+			// A request failing on a reused connection is retried by the
+			// transport, so each request is sent on a new connection
+			pool.Clients[0].CloseIdleConnections()
+			return pool.QuickRequest(HttpClientPool.RequestData{Type: "GET", Url: server.URL, Timeout: time.Second})
+		}
+		if response, err := get(); err != nil || response.StatusCode != http.StatusOK {
+			t.Fatalf("%s: unexpected response %d %v", proxy.URL(), response.StatusCode, err)
+		}
+		proxy.AssertUsed(t, server.Host())
+		proxy.AssertUsed(t, "127.0.0.1")
+		unused.AssertNotUsed(t)
+		// Faults
+		proxy.RateLimit()
+		if response, _ := get(); response.StatusCode != http.StatusTooManyRequests || response.Headers["Retry-After"] == nil {
+			t.Fatalf("%s: expected 429, got %d", proxy.URL(), response.StatusCode)
+		}
+		proxy.Ban()
+		if response, _ := get(); response.StatusCode != http.StatusForbidden || string(response.Body) != pooltest.DefaultBanPage {
+			t.Fatalf("%s: expected a ban page, got %d", proxy.URL(), response.StatusCode)
+		}
+		proxy.Fail()
+		if _, err := get(); err == nil {
+			t.Fatalf("%s: expected a failed request", proxy.URL())
+		}
+		proxy.Slow(50 * time.Millisecond)
+		start := time.Now()
+		if _, err := get(); err != nil || time.Since(start) < 50*time.Millisecond {
+			t.Fatalf("%s: expected a slow request, took %s: %v", proxy.URL(), time.Since(start), err)
+		}
+		proxy.Heal()
+		expected := []pooltest.FaultKind{pooltest.NoFault, pooltest.FaultRateLimit, pooltest.FaultBan, pooltest.FaultFail, pooltest.FaultSlow}
+		requests := proxy.Requests()
+		faults := make([]pooltest.FaultKind, len(requests))
+		for idx, request := range requests {
+			faults[idx] = request.Fault
+		}
+		if !slices.Equal(faults, expected) {
+			t.Fatalf("%s: expected faults %v, got %v", proxy.URL(), expected, faults)
+		}
+	}
+}
+
+func TestAssertMaxRate(t *testing.T) {
+	start := time.Unix(0, 0)
+	times := []time.Time{start, start.Add(100 * time.Millisecond), start.Add(200 * time.Millisecond)}
+	pooltest.AssertMaxRate(t, times, 10, time.Second)
+	pooltest.AssertMaxRate(t, times, 1, 100*time.Millisecond)
+	recorder := &recordingTB{TB: t}
+	pooltest.AssertMaxRate(recorder, times, 2, time.Second)
+	if len(recorder.errors) != 1 || !strings.Contains(recorder.errors[0], "3 requests within 200ms") {
+		t.Fatalf("Expected 3 requests within a second to exceed 2/s, got %q", recorder.errors)
+	}
+}
+
+// recordingTB is a testing.TB recording the errors reported to it instead of failing.
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (tb *recordingTB) Helper() {}
+
+func (tb *recordingTB) Errorf(format string, args ...any) {
+	tb.errors = append(tb.errors, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,415 @@
+package pooltest
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// DefaultBanPage is the body returned by a banned proxy.
+const DefaultBanPage = "<html><head><title>Access Denied</title></head>" +
+	"<body><h1>Access Denied</h1><p>Your IP address has been blocked.</p></body></html>"
+
+// FaultKind is a kind of fault injected by a Proxy.
+type FaultKind int
+
+const (
+	// NoFault forwards requests normally.
+	NoFault FaultKind = iota
+	// FaultFail closes connections without a response, like a dead proxy.
+	FaultFail
+	// FaultSlow waits before forwarding requests.
+	FaultSlow
+	// FaultRateLimit responds 429 Too Many Requests with a Retry-After header.
+	FaultRateLimit
+	// FaultBan responds 403 Forbidden with DefaultBanPage.
+	FaultBan
+)
+
+// ProxyRequest is a request recorded by a Proxy.
+type ProxyRequest struct {
+	// Target is the host and port the proxy was asked to reach.
+	Target string
+	// Method is the HTTP method, "CONNECT" for tunnels and SOCKS connections.
+	Method string
+	// URL is the absolute URL of plain HTTP requests, empty for tunnels.
+	URL string
+	// Fault is the fault injected into the request.
+	Fault FaultKind
+	// Time is when the request was received.
+	Time time.Time
+}
+
+// Proxy is a fake HTTP or SOCKS5 proxy which records traffic and injects faults.
+type Proxy struct {
+	url      *url.URL
+	listener net.Listener
+	fault    FaultKind
+	delay    time.Duration
+	requests []ProxyRequest
+	// conns are the open client connections, closed with the proxy.
+	conns map[net.Conn]bool
+	mu    sync.Mutex
+}
+
+// NewHTTPProxy starts a fake HTTP forward proxy supporting plain requests and CONNECT tunnels.
+//
+// Parameters:
+//   - tb (testing.TB): The test, the proxy is closed when it finishes.
+//
+// Returns:
+//   - *Proxy: A pointer to the started proxy.
+func NewHTTPProxy(tb testing.TB) *Proxy {
+	proxy := newProxy(tb, "http")
+	server := &http.Server{Handler: http.HandlerFunc(proxy.serveHTTP)}
+	go server.Serve(proxy.listener)
+	tb.Cleanup(func() {
+		server.Close()
+		proxy.Close()
+	})
+	return proxy
+}
+
+// NewSOCKS5Proxy starts a fake SOCKS5 proxy supporting the CONNECT command without authentication.
+//
+// Parameters:
+//   - tb (testing.TB): The test, the proxy is closed when it finishes.
+//
+// Returns:
+//   - *Proxy: A pointer to the started proxy.
+func NewSOCKS5Proxy(tb testing.TB) *Proxy {
+	proxy := newProxy(tb, "socks5")
+	go func() {
+		for {
+			conn, err := proxy.listener.Accept()
+			if err != nil {
+				return
+			}
+			go proxy.serveSOCKS5(conn)
+		}
+	}()
+	tb.Cleanup(proxy.Close)
+	return proxy
+}
+
+// newProxy listens on an ephemeral localhost port.
+func newProxy(tb testing.TB, scheme string) *Proxy {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatalf("pooltest: failed to listen: %v", err)
+	}
+	return &Proxy{
+		url:      &url.URL{Scheme: scheme, Host: listener.Addr().String()},
+		listener: listener,
+		conns:    make(map[net.Conn]bool),
+	}
+}
+
+// URL returns the URL of the proxy for use as a client proxy.
+//
+// Returns:
+//   - *url.URL: The proxy URL, e.g. "socks5://127.0.0.1:41234".
+func (proxy *Proxy) URL() *url.URL {
+	proxyUrl := *proxy.url
+	return &proxyUrl
+}
+
+// Close stops the proxy and closes open connections.
+func (proxy *Proxy) Close() {
+	proxy.listener.Close()
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+	for conn := range proxy.conns {
+		conn.Close()
+	}
+}
+
+// Fail makes the proxy close connections without a response.
+func (proxy *Proxy) Fail() {
+	proxy.setFault(FaultFail, 0)
+}
+
+// Slow makes the proxy wait before forwarding each request.
+//
+// Parameters:
+//   - delay (time.Duration): The time to wait.
+func (proxy *Proxy) Slow(delay time.Duration) {
+	proxy.setFault(FaultSlow, delay)
+}
+
+// RateLimit makes the proxy respond 429 Too Many Requests with a Retry-After header.
+func (proxy *Proxy) RateLimit() {
+	proxy.setFault(FaultRateLimit, 0)
+}
+
+// Ban makes the proxy respond 403 Forbidden with DefaultBanPage.
+func (proxy *Proxy) Ban() {
+	proxy.setFault(FaultBan, 0)
+}
+
+// Heal makes the proxy forward requests normally.
+func (proxy *Proxy) Heal() {
+	proxy.setFault(NoFault, 0)
+}
+
+// setFault sets the fault injected into later requests.
+//
+// Open tunnels and SOCKS connections are closed so clients reconnect and the
+// fault applies to their next request.
+func (proxy *Proxy) setFault(fault FaultKind, delay time.Duration) {
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+	proxy.fault, proxy.delay = fault, delay
+	for conn := range proxy.conns {
+		conn.Close()
+	}
+}
+
+// Requests returns the requests received by the proxy in order.
+//
+// Returns:
+//   - []ProxyRequest: The recorded requests.
+func (proxy *Proxy) Requests() []ProxyRequest {
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+	return append([]ProxyRequest(nil), proxy.requests...)
+}
+
+// Times returns when each request was received, for use with AssertMaxRate.
+//
+// Returns:
+//   - []time.Time: The request times in order.
+func (proxy *Proxy) Times() []time.Time {
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+	times := make([]time.Time, len(proxy.requests))
+	for idx, request := range proxy.requests {
+		times[idx] = request.Time
+	}
+	return times
+}
+
+// record records a request and returns the fault to inject into it.
+func (proxy *Proxy) record(request ProxyRequest) (FaultKind, time.Duration) {
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+	request.Fault = proxy.fault
+	request.Time = time.Now()
+	proxy.requests = append(proxy.requests, request)
+	return proxy.fault, proxy.delay
+}
+
+// track adds or removes an open connection.
+func (proxy *Proxy) track(conn net.Conn, open bool) {
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+	if open {
+		proxy.conns[conn] = true
+	} else {
+		delete(proxy.conns, conn)
+	}
+}
+
+// faultResponse returns the response injected by a fault, nil if the request
+// should be forwarded.
+func faultResponse(fault FaultKind) *http.Response {
+	var response *http.Response
+	switch fault {
+	case FaultRateLimit:
+		response = &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"1"}}}
+		response.Body = io.NopCloser(strings.NewReader("Too Many Requests"))
+	case FaultBan:
+		response = &http.Response{StatusCode: http.StatusForbidden, Header: http.Header{"Content-Type": {"text/html"}}}
+		response.Body = io.NopCloser(strings.NewReader(DefaultBanPage))
+	default:
+		return nil
+	}
+	response.Status = strconv.Itoa(response.StatusCode) + " " + http.StatusText(response.StatusCode)
+	response.ProtoMajor, response.ProtoMinor = 1, 1
+	return response
+}
+
+// serveHTTP handles a request to the HTTP proxy.
+func (proxy *Proxy) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	request := ProxyRequest{Target: r.Host, Method: r.Method}
+	if r.Method != http.MethodConnect {
+		request.URL = r.URL.String()
+		if r.URL.Port() == "" {
+			request.Target = net.JoinHostPort(r.URL.Hostname(), "80")
+		}
+	}
+	fault, delay := proxy.record(request)
+	switch fault {
+	case FaultFail:
+		if conn, _, err := http.NewResponseController(w).Hijack(); err == nil {
+			conn.Close()
+		}
+		return
+	case FaultSlow:
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if response := faultResponse(fault); response != nil {
+		for key, values := range response.Header {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(response.StatusCode)
+		io.Copy(w, response.Body)
+		return
+	}
+	if r.Method == http.MethodConnect {
+		proxy.tunnel(w, r)
+		return
+	}
+	if !r.URL.IsAbs() {
+		http.Error(w, "pooltest: proxy requests need an absolute URL", http.StatusBadRequest)
+		return
+	}
+	outReq := r.Clone(r.Context())
+	outReq.RequestURI = ""
+	outReq.Header.Del("Proxy-Connection")
+	outReq.Header.Del("Proxy-Authorization")
+	response, err := http.DefaultTransport.RoundTrip(outReq)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer response.Body.Close()
+	for key, values := range response.Header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(response.StatusCode)
+	io.Copy(w, response.Body)
+}
+
+// tunnel relays a CONNECT request to its target.
+func (proxy *Proxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	upstream, err := net.DialTimeout("tcp", r.Host, 10*time.Second)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	conn, buffered, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	// Forward anything the client sent after the CONNECT request
+	if buffered.Reader.Buffered() > 0 {
+		data, _ := buffered.Reader.Peek(buffered.Reader.Buffered())
+		upstream.Write(data)
+	}
+	proxy.relay(conn, upstream)
+}
+
+// relay copies data between two connections until either closes.
+func (proxy *Proxy) relay(conn, upstream net.Conn) {
+	proxy.track(conn, true)
+	defer proxy.track(conn, false)
+	done := make(chan struct{}, 2)
+	copyConn := func(dst, src net.Conn) {
+		io.Copy(dst, src)
+		done <- struct{}{}
+	}
+	go copyConn(upstream, conn)
+	go copyConn(conn, upstream)
+	<-done
+	conn.Close()
+	upstream.Close()
+}
+
+// serveSOCKS5 handles a connection to the SOCKS5 proxy.
+func (proxy *Proxy) serveSOCKS5(conn net.Conn) {
+	proxy.track(conn, true)
+	defer proxy.track(conn, false)
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	// Greeting: version, method count and methods
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(reader, header); err != nil || header[0] != 5 {
+		return
+	}
+	if _, err := io.ReadFull(reader, make([]byte, header[1])); err != nil {
+		return
+	}
+	// Accept without authentication
+	conn.Write([]byte{5, 0})
+	// Request: version, command, reserved, address type
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(reader, request); err != nil {
+		return
+	}
+	var host string
+	switch request[3] {
+	case 1, 4:
+		ip := make([]byte, map[byte]int{1: net.IPv4len, 4: net.IPv6len}[request[3]])
+		if _, err := io.ReadFull(reader, ip); err != nil {
+			return
+		}
+		host = net.IP(ip).String()
+	case 3:
+		length, err := reader.ReadByte()
+		if err != nil {
+			return
+		}
+		name := make([]byte, length)
+		if _, err := io.ReadFull(reader, name); err != nil {
+			return
+		}
+		host = string(name)
+	default:
+		// Address type not supported
+		conn.Write([]byte{5, 8, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(reader, port); err != nil {
+		return
+	}
+	target := net.JoinHostPort(host, strconv.Itoa(int(port[0])<<8|int(port[1])))
+	if request[1] != 1 {
+		// Only CONNECT is supported
+		conn.Write([]byte{5, 7, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	fault, delay := proxy.record(ProxyRequest{Target: target, Method: http.MethodConnect})
+	switch fault {
+	case FaultFail:
+		return
+	case FaultSlow:
+		time.Sleep(delay)
+	}
+	if response := faultResponse(fault); response != nil {
+		// Answer the HTTP request sent through the tunnel
+		conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+		if req, err := http.ReadRequest(reader); err == nil {
+			req.Body.Close()
+			response.Close = true
+			response.Write(conn)
+		}
+		return
+	}
+	upstream, err := net.DialTimeout("tcp", target, 10*time.Second)
+	if err != nil {
+		// Connection refused
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	if reader.Buffered() > 0 {
+		data, _ := reader.Peek(reader.Buffered())
+		upstream.Write(data)
+	}
+	proxy.relay(conn, upstream)
+}
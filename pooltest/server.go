@@ -0,0 +1,198 @@
+// Package pooltest provides fake upstream servers and proxies for testing code using a ClientPool.
+//
+// Overview:
+//
+//	Servers and proxies listen on ephemeral localhost ports and are closed when the
+//	test finishes. They record every request so tests can assert on the traffic,
+//	and proxies can be told to fail, slow down, return 429 or return ban pages.
+//
+// Example:
+//
+//	server := pooltest.NewEchoServer(t)
+//	proxy := pooltest.NewHTTPProxy(t)
+//	pool := HttpClientPool.NewClientPool(0, 100*time.Millisecond, []*url.URL{proxy.URL()}, nil)
+//	pool.QuickRequest(HttpClientPool.RequestData{Type: "GET", Url: server.URL})
+//	proxy.AssertUsed(t, server.Host())
+//	pooltest.AssertMaxRate(t, server.Times(), 10, time.Second)
+//
+// This package does not import HttpClientPool so it can be used by its tests.
+package pooltest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Echo is the JSON body returned by an echo server describing the request it received.
+type Echo struct {
+	Method  string              `json:"method"`
+	URL     string              `json:"url"`
+	Headers map[string][]string `json:"headers"`
+	Params  map[string][]string `json:"params"`
+	Body    string              `json:"body"`
+	Cookies map[string]string   `json:"cookies"`
+}
+
+// Response is a scripted response returned by a scripted server.
+type Response struct {
+	// Status is the status code, 200 if 0.
+	Status int
+	// Header is added to the response headers.
+	Header http.Header
+	// Body is the response body.
+	Body string
+	// Delay is waited before responding.
+	Delay time.Duration
+}
+
+// Request is a request recorded by a Server.
+type Request struct {
+	Method string
+	// URL is the request URL with the path and query.
+	URL    string
+	Header http.Header
+	Body   []byte
+	// Time is when the request was received.
+	Time time.Time
+}
+
+// Server is an ephemeral upstream server recording the requests it receives.
+type Server struct {
+	*httptest.Server
+	requests []Request
+	mu       sync.Mutex
+}
+
+// NewEchoServer starts a server responding to every request with an Echo of the request.
+//
+// Parameters:
+//   - tb (testing.TB): The test, the server is closed when it finishes.
+//
+// Returns:
+//   - *Server: A pointer to the started server.
+func NewEchoServer(tb testing.TB) *Server {
+	return NewServer(tb, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		cookies := make(map[string]string)
+		for _, cookie := range r.Cookies() {
+			cookies[cookie.Name] = cookie.Value
+		}
+		echo := Echo{
+			Method:  r.Method,
+			URL:     r.URL.Path,
+			Headers: r.Header,
+			Params:  r.URL.Query(),
+			Body:    string(body),
+			Cookies: cookies,
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(echo)
+	}))
+}
+
+// NewScriptedServer starts a server returning responses in order. Once the
+// script runs out the last response is repeated.
+//
+// Parameters:
+//   - tb (testing.TB): The test, the server is closed when it finishes.
+//   - responses (...Response): The responses to return.
+//
+// Returns:
+//   - *Server: A pointer to the started server.
+func NewScriptedServer(tb testing.TB, responses ...Response) *Server {
+	var served int
+	var mu sync.Mutex
+	return NewServer(tb, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		response := Response{}
+		if len(responses) > 0 {
+			response = responses[min(served, len(responses)-1)]
+		}
+		served++
+		mu.Unlock()
+		if response.Delay > 0 {
+			select {
+			case <-time.After(response.Delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		for key, values := range response.Header {
+			w.Header()[key] = values
+		}
+		if response.Status == 0 {
+			response.Status = http.StatusOK
+		}
+		w.WriteHeader(response.Status)
+		io.WriteString(w, response.Body)
+	}))
+}
+
+// NewServer starts a server recording requests before passing them to handler.
+//
+// Parameters:
+//   - tb (testing.TB): The test, the server is closed when it finishes.
+//   - handler (http.Handler): The handler responding to requests.
+//
+// Returns:
+//   - *Server: A pointer to the started server.
+func NewServer(tb testing.TB, handler http.Handler) *Server {
+	server := &Server{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body.Close()
+		server.mu.Lock()
+		server.requests = append(server.requests, Request{
+			Method: r.Method,
+			URL:    r.URL.RequestURI(),
+			Header: r.Header.Clone(),
+			Body:   body,
+			Time:   time.Now(),
+		})
+		server.mu.Unlock()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		handler.ServeHTTP(w, r)
+	}))
+	tb.Cleanup(server.Close)
+	return server
+}
+
+// Host returns the host and port of the server.
+//
+// Returns:
+//   - string: The server host, e.g. "127.0.0.1:41234".
+func (server *Server) Host() string {
+	parsedUrl, _ := url.Parse(server.URL)
+	return parsedUrl.Host
+}
+
+// Requests returns the requests received by the server in order.
+//
+// Returns:
+//   - []Request: The recorded requests.
+func (server *Server) Requests() []Request {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return append([]Request(nil), server.requests...)
+}
+
+// Times returns when each request was received, for use with AssertMaxRate.
+//
+// Returns:
+//   - []time.Time: The request times in order.
+func (server *Server) Times() []time.Time {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	times := make([]time.Time, len(server.requests))
+	for idx, request := range server.requests {
+		times[idx] = request.Time
+	}
+	return times
+}
@@ -0,0 +1,453 @@
+package HttpClientPool
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	utls "github.com/refraction-networking/utls"
+	"golang.org/x/net/proxy"
+)
+
+// errUseHTTP1 is returned when the server did not negotiate HTTP/2.
+var errUseHTTP1 = errors.New("server did not negotiate HTTP/2")
+
+// profileTransport sends HTTPS requests with the ClientHello and HTTP/2
+// fingerprint of a TLSProfile, and plain HTTP requests with the Client's transport.
+//
+// The proxy, TLS config, dialer and timeouts are read from the Client's transport.
+// HTTP/2 connections are closed once idle for its IdleConnTimeout and requests
+// fail if the response headers take longer than its ResponseHeaderTimeout.
+type profileTransport struct {
+	base    *http.Transport
+	profile *TLSProfile
+	// http1 sends HTTPS requests to servers which did not negotiate HTTP/2.
+	http1 *http.Transport
+	mu    sync.Mutex
+	// conns are the HTTP/2 connections by address.
+	conns map[string][]*http2Conn
+	// http1Addrs are the addresses of servers which did not negotiate HTTP/2.
+	http1Addrs map[string]bool
+	// spare are handshaken HTTP/1.1 connections waiting for the http1 transport.
+	spare map[string][]net.Conn
+}
+
+// newProfileTransport creates a profileTransport.
+//
+// Parameters:
+//   - base (*http.Transport): The Client's transport.
+//   - profile (*TLSProfile): The profile to present.
+//
+// Returns:
+//   - *profileTransport: The transport.
+func newProfileTransport(base *http.Transport, profile *TLSProfile) *profileTransport {
+	transport := &profileTransport{
+		base:       base,
+		profile:    profile,
+		conns:      make(map[string][]*http2Conn),
+		http1Addrs: make(map[string]bool),
+		spare:      make(map[string][]net.Conn),
+	}
+	// The proxy is dialed by dialTLS
+	transport.http1 = base.Clone()
+	transport.http1.Proxy = nil
+	transport.http1.DialTLSContext = transport.dialHTTP1
+	transport.http1.ForceAttemptHTTP2 = false
+	transport.http1.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	return transport
+}
+
+// RoundTrip implements http.RoundTripper.
+func (transport *profileTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" {
+		return transport.base.RoundTrip(req)
+	}
+	addr := canonicalAddr(req.URL)
+	transport.mu.Lock()
+	useHTTP1 := transport.profile.HTTP2 == nil || transport.http1Addrs[addr]
+	transport.mu.Unlock()
+	if useHTTP1 {
+		return transport.http1.RoundTrip(req)
+	}
+	for attempt := 0; ; attempt++ {
+		cc, err := transport.http2Conn(req.Context(), addr)
+		if errors.Is(err, errUseHTTP1) {
+			return transport.http1.RoundTrip(req)
+		}
+		if err != nil {
+			closeRequestBody(req)
+			return nil, err
+		}
+		res, err := cc.roundTrip(req)
+		if !errors.Is(err, errHTTP2Retry) || attempt == 2 {
+			return res, err
+		}
+		// Retry requests the server did not process on a new connection
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return nil, err
+			}
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// CloseIdleConnections closes the connections which are not in use.
+func (transport *profileTransport) CloseIdleConnections() {
+	transport.base.CloseIdleConnections()
+	transport.http1.CloseIdleConnections()
+	transport.mu.Lock()
+	var idle []*http2Conn
+	for _, conns := range transport.conns {
+		for _, cc := range conns {
+			if cc.isIdle() {
+				idle = append(idle, cc)
+			}
+		}
+	}
+	for addr, conns := range transport.spare {
+		for _, conn := range conns {
+			conn.Close()
+		}
+		delete(transport.spare, addr)
+	}
+	transport.mu.Unlock()
+	// Closed connections remove themselves with removeConn
+	for _, cc := range idle {
+		cc.close(errHTTP2Retry)
+	}
+}
+
+// removeConn forgets a closed HTTP/2 connection.
+func (transport *profileTransport) removeConn(addr string, cc *http2Conn) {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	conns := slices.DeleteFunc(transport.conns[addr], func(open *http2Conn) bool { return open == cc })
+	if len(conns) == 0 {
+		delete(transport.conns, addr)
+	} else {
+		transport.conns[addr] = conns
+	}
+}
+
+// http2Conn returns an HTTP/2 connection to an address with a reserved stream,
+// dialing a new connection if none can take the request.
+//
+// Returns:
+//   - *http2Conn: The connection.
+//   - error: errUseHTTP1 if the server did not negotiate HTTP/2.
+func (transport *profileTransport) http2Conn(ctx context.Context, addr string) (*http2Conn, error) {
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.GetConn != nil {
+		trace.GetConn(addr)
+	}
+	transport.mu.Lock()
+	var open []*http2Conn
+	for _, cc := range transport.conns[addr] {
+		if !cc.isClosed() {
+			open = append(open, cc)
+		}
+	}
+	transport.conns[addr] = open
+	for _, cc := range open {
+		if cc.reserve() {
+			transport.mu.Unlock()
+			if trace != nil && trace.GotConn != nil {
+				trace.GotConn(httptrace.GotConnInfo{Conn: cc.conn, Reused: true})
+			}
+			return cc, nil
+		}
+	}
+	transport.mu.Unlock()
+	conn, state, err := transport.dialTLS(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	if state.NegotiatedProtocol != "h2" {
+		transport.mu.Lock()
+		transport.http1Addrs[addr] = true
+		transport.spare[addr] = append(transport.spare[addr], conn)
+		transport.mu.Unlock()
+		return nil, errUseHTTP1
+	}
+	cc, err := newHTTP2Conn(conn, state, transport.profile.HTTP2, transport.base, func(cc *http2Conn) {
+		transport.removeConn(addr, cc)
+	})
+	if err != nil {
+		return nil, err
+	}
+	cc.reserve()
+	transport.mu.Lock()
+	// A connection closed already has been removed by removeConn
+	if !cc.isClosed() {
+		transport.conns[addr] = append(transport.conns[addr], cc)
+	}
+	transport.mu.Unlock()
+	if trace != nil && trace.GotConn != nil {
+		trace.GotConn(httptrace.GotConnInfo{Conn: conn})
+	}
+	return cc, nil
+}
+
+// dialHTTP1 is the DialTLSContext of the http1 transport.
+func (transport *profileTransport) dialHTTP1(ctx context.Context, network, addr string) (net.Conn, error) {
+	transport.mu.Lock()
+	if spare := transport.spare[addr]; len(spare) > 0 {
+		conn := spare[len(spare)-1]
+		transport.spare[addr] = spare[:len(spare)-1]
+		transport.mu.Unlock()
+		return conn, nil
+	}
+	transport.mu.Unlock()
+	conn, state, err := transport.dialTLS(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	if state.NegotiatedProtocol == "h2" {
+		// The server now supports HTTP/2, use it from the next request
+		conn.Close()
+		transport.mu.Lock()
+		delete(transport.http1Addrs, addr)
+		transport.mu.Unlock()
+		return nil, fmt.Errorf("server %s negotiated HTTP/2 on an HTTP/1.1 connection", addr)
+	}
+	return conn, nil
+}
+
+// dialTLS dials an address through the proxy of the Client and performs the
+// TLS handshake with the profile's ClientHello.
+//
+// Returns:
+//   - net.Conn: The TLS connection.
+//   - *tls.ConnectionState: The state of the TLS connection.
+//   - error: An error dialing, or with the handshake.
+func (transport *profileTransport) dialTLS(ctx context.Context, addr string) (net.Conn, *tls.ConnectionState, error) {
+	conn, err := transport.dialProxy(ctx, addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	config := transport.utlsConfig(addr)
+	var uconn *utls.UConn
+	if transport.profile.HTTP2 != nil {
+		uconn = utls.UClient(conn, config, transport.profile.ClientHelloID)
+	} else {
+		// Only offer HTTP/1.1 over ALPN
+		spec, err := utls.UTLSIdToSpec(transport.profile.ClientHelloID)
+		if err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		for _, extension := range spec.Extensions {
+			if alpn, ok := extension.(*utls.ALPNExtension); ok {
+				alpn.AlpnProtocols = []string{"http/1.1"}
+			}
+		}
+		uconn = utls.UClient(conn, config, utls.HelloCustom)
+		if err := uconn.ApplyPreset(&spec); err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+	}
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.TLSHandshakeStart != nil {
+		trace.TLSHandshakeStart()
+	}
+	handshakeCtx := ctx
+	if transport.base.TLSHandshakeTimeout > 0 {
+		var cancel context.CancelFunc
+		handshakeCtx, cancel = context.WithTimeout(ctx, transport.base.TLSHandshakeTimeout)
+		defer cancel()
+	}
+	err = uconn.HandshakeContext(handshakeCtx)
+	state := convertConnectionState(uconn.ConnectionState())
+	if err == nil && config.MinVersion != 0 && state.Version < config.MinVersion {
+		err = fmt.Errorf("tls: server selected version %x below the minimum %x", state.Version, config.MinVersion)
+	}
+	if trace != nil && trace.TLSHandshakeDone != nil {
+		trace.TLSHandshakeDone(*state, err)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return uconn, state, nil
+}
+
+// utlsConfig converts the TLS config of the Client's transport for uTLS.
+func (transport *profileTransport) utlsConfig(addr string) *utls.Config {
+	host, _, _ := net.SplitHostPort(addr)
+	config := &utls.Config{ServerName: host}
+	if base := transport.base.TLSClientConfig; base != nil {
+		if base.ServerName != "" {
+			config.ServerName = base.ServerName
+		}
+		config.RootCAs = base.RootCAs
+		config.InsecureSkipVerify = base.InsecureSkipVerify
+		config.MinVersion = base.MinVersion
+		config.KeyLogWriter = base.KeyLogWriter
+		for _, certificate := range base.Certificates {
+			config.Certificates = append(config.Certificates, utls.Certificate{
+				Certificate:                 certificate.Certificate,
+				PrivateKey:                  certificate.PrivateKey,
+				OCSPStaple:                  certificate.OCSPStaple,
+				SignedCertificateTimestamps: certificate.SignedCertificateTimestamps,
+				Leaf:                        certificate.Leaf,
+			})
+		}
+	}
+	return config
+}
+
+// convertConnectionState converts the state of a uTLS connection for http.Response.TLS.
+func convertConnectionState(state utls.ConnectionState) *tls.ConnectionState {
+	return &tls.ConnectionState{
+		Version:                     state.Version,
+		HandshakeComplete:           state.HandshakeComplete,
+		DidResume:                   state.DidResume,
+		CipherSuite:                 state.CipherSuite,
+		NegotiatedProtocol:          state.NegotiatedProtocol,
+		ServerName:                  state.ServerName,
+		PeerCertificates:            state.PeerCertificates,
+		VerifiedChains:              state.VerifiedChains,
+		SignedCertificateTimestamps: state.SignedCertificateTimestamps,
+		OCSPResponse:                state.OCSPResponse,
+	}
+}
+
+// dialProxy opens a connection to an address through the proxy of the Client.
+//
+// HTTP and HTTPS proxies are tunnelled through with CONNECT and SOCKS5
+// proxies with the SOCKS5 CONNECT command.
+func (transport *profileTransport) dialProxy(ctx context.Context, addr string) (net.Conn, error) {
+	dial := transport.base.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	}
+	var proxyUrl *url.URL
+	if transport.base.Proxy != nil {
+		var err error
+		proxyUrl, err = transport.base.Proxy(&http.Request{
+			Method: http.MethodGet,
+			URL:    &url.URL{Scheme: "https", Host: addr},
+			Header: make(http.Header),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if proxyUrl == nil {
+		return dial(ctx, "tcp", addr)
+	}
+	switch proxyUrl.Scheme {
+	case "socks5", "socks5h":
+		dialer, err := proxy.FromURL(proxyUrl, dialFunc(dial))
+		if err != nil {
+			return nil, err
+		}
+		return dialer.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+	case "http", "https":
+		return transport.dialConnect(ctx, proxyUrl, addr, dial)
+	}
+	return nil, fmt.Errorf("unsupported proxy scheme %q", proxyUrl.Scheme)
+}
+
+// dialConnect opens a tunnel to an address through an HTTP or HTTPS proxy.
+func (transport *profileTransport) dialConnect(ctx context.Context, proxyUrl *url.URL, addr string, dial func(ctx context.Context, network, addr string) (net.Conn, error)) (net.Conn, error) {
+	conn, err := dial(ctx, "tcp", canonicalAddr(proxyUrl))
+	if err != nil {
+		return nil, err
+	}
+	if proxyUrl.Scheme == "https" {
+		config := &tls.Config{}
+		if transport.base.TLSClientConfig != nil {
+			config = transport.base.TLSClientConfig.Clone()
+		}
+		config.ServerName = proxyUrl.Hostname()
+		tlsConn := tls.Client(conn, config)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	header := transport.base.ProxyConnectHeader.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	if user := proxyUrl.User; user != nil && header.Get("Proxy-Authorization") == "" {
+		password, _ := user.Password()
+		header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(user.Username()+":"+password)))
+	}
+	connectReq := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: header,
+	}
+	// Abort the CONNECT if the context is done
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+	if err := connectReq.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, connectReq)
+	if err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	// A successful CONNECT response has no body, the tunnel follows it
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT to %s failed: %s", addr, res.Status)
+	}
+	if reader.Buffered() > 0 {
+		conn.Close()
+		return nil, errors.New("proxy sent data before the tunnel was established")
+	}
+	if !stop() {
+		conn.Close()
+		return nil, ctx.Err()
+	}
+	return conn, nil
+}
+
+// dialFunc adapts a dial function to proxy.Dialer and proxy.ContextDialer.
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+func (dial dialFunc) Dial(network, addr string) (net.Conn, error) {
+	return dial(context.Background(), network, addr)
+}
+
+func (dial dialFunc) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return dial(ctx, network, addr)
+}
+
+// canonicalAddr returns the host and port of a URL, adding the default port of its scheme.
+func canonicalAddr(requestUrl *url.URL) string {
+	port := requestUrl.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443", "socks5": "1080", "socks5h": "1080"}[requestUrl.Scheme]
+	}
+	return net.JoinHostPort(requestUrl.Hostname(), port)
+}
@@ -0,0 +1,306 @@
+package HttpClientPool
+
+import (
+	"container/heap"
+	"context"
+	"time"
+)
+
+// GetClientOptions configures how GetClientContext waits for a Client.
+type GetClientOptions struct {
+	// Priority orders the waiters. Higher priorities are served first and equal
+	// priorities in arrival order.
+	Priority int
+
+	// Tenant is the tenant the client is handed out to. Tenants waiting at the
+	// same priority are served in proportion to their weights. See SetTenant.
+	Tenant string
+
+	// Exclude lists clients which must not be handed out, for example clients
+	// which have already failed the request.
+	Exclude []*Client
+
+	// Host is the host the client will request. Clients whose quota for the
+	// host is exhausted are not handed out. See ClientPool.SetQuotaTracking.
+	Host string
+}
+
+// waiter is a goroutine waiting in GetClientContext.
+type waiter struct {
+	priority int
+	tenant   string
+	seq      uint64
+	enqueued time.Time
+	// index is the position of the waiter in the queue heap.
+	index int
+	// ready is signalled when the waiter should try to take a client.
+	ready chan struct{}
+}
+
+// signal wakes the waiter without blocking.
+func (w *waiter) signal() {
+	select {
+	case w.ready <- struct{}{}:
+	default:
+	}
+}
+
+// waitQueue orders the goroutines waiting for a Client. pool.mu must be held.
+//
+// The waiters form a heap ordered by effective priority, tenant start tag and
+// arrival. Aging and handouts to other tenants change the order of waiters
+// already in the heap, so the heap is rebuilt when either may have done so.
+type waitQueue struct {
+	waiters []*waiter
+	seq     uint64
+	// aging raises the priority of a waiter by one for each interval waited, 0 for no aging.
+	aging time.Duration
+	// tenants holds the weights, quotas and usage of each tenant.
+	tenants map[string]*tenantState
+	// waiting counts the waiters of each tenant.
+	waiting map[string]int
+	// virtualTime is the start tag of the last tenant served.
+	virtualTime float64
+	// now is the time the heap was ordered at.
+	now time.Time
+	// stale is set when the heap must be rebuilt before use.
+	stale bool
+}
+
+// effectivePriority returns the priority of a waiter including aging.
+func (queue *waitQueue) effectivePriority(w *waiter, now time.Time) int {
+	if queue.aging <= 0 {
+		return w.priority
+	}
+	return w.priority + int(now.Sub(w.enqueued)/queue.aging)
+}
+
+func (queue *waitQueue) Len() int { return len(queue.waiters) }
+
+// Less orders the highest effective priority first. Inside a priority the
+// tenant with the lowest start tag is first, then the earliest waiter of that tenant.
+func (queue *waitQueue) Less(i, j int) bool {
+	a, b := queue.waiters[i], queue.waiters[j]
+	if priorityA, priorityB := queue.effectivePriority(a, queue.now), queue.effectivePriority(b, queue.now); priorityA != priorityB {
+		return priorityA > priorityB
+	}
+	if tagA, tagB := queue.startTag(a.tenant), queue.startTag(b.tenant); tagA != tagB {
+		return tagA < tagB
+	}
+	return a.seq < b.seq
+}
+
+func (queue *waitQueue) Swap(i, j int) {
+	queue.waiters[i], queue.waiters[j] = queue.waiters[j], queue.waiters[i]
+	queue.waiters[i].index = i
+	queue.waiters[j].index = j
+}
+
+func (queue *waitQueue) Push(x any) {
+	w := x.(*waiter)
+	w.index = len(queue.waiters)
+	queue.waiters = append(queue.waiters, w)
+}
+
+func (queue *waitQueue) Pop() any {
+	last := len(queue.waiters) - 1
+	w := queue.waiters[last]
+	queue.waiters[last] = nil
+	queue.waiters = queue.waiters[:last]
+	return w
+}
+
+// push adds a waiter to the queue.
+func (queue *waitQueue) push(w *waiter, now time.Time) {
+	queue.order(now)
+	heap.Push(queue, w)
+	if queue.waiting == nil {
+		queue.waiting = make(map[string]int)
+	}
+	queue.waiting[w.tenant]++
+}
+
+// remove removes a waiter from the queue.
+func (queue *waitQueue) remove(w *waiter) {
+	heap.Remove(queue, w.index)
+	if queue.waiting[w.tenant]--; queue.waiting[w.tenant] == 0 {
+		delete(queue.waiting, w.tenant)
+	}
+}
+
+// head returns the waiter to be served next, nil if the queue is empty.
+func (queue *waitQueue) head(now time.Time) *waiter {
+	if len(queue.waiters) == 0 {
+		return nil
+	}
+	queue.order(now)
+	return queue.waiters[0]
+}
+
+// order rebuilds the heap if aging or tenant tags may have changed the order.
+func (queue *waitQueue) order(now time.Time) {
+	if queue.stale || (queue.aging > 0 && !now.Equal(queue.now)) {
+		queue.now = now
+		heap.Init(queue)
+		queue.stale = false
+	}
+}
+
+// GetClientContext returns an available HTTP client from the pool.
+// The client is set as active and the lastReqTime is set to time.Now.
+//
+// When no client is available the caller is queued. Waiters are served strictly
+// by priority with FIFO order inside each priority. Tenants sharing a priority
+// are served in proportion to their weights.
+//
+// Only the waiter at the head of the queue tries to take a client. It sleeps
+// until the pool delay, client ratelimits or host quotas end, or until a client
+// finishes a request or is added to the pool. A waiter whose host quota is
+// exhausted for every client leaves the queue until the quota resets and then
+// returns to its original place.
+//
+// Parameters:
+//   - ctx (context.Context): The context controlling the wait.
+//   - opts (GetClientOptions): The options of the waiter.
+//
+// Returns:
+//   - *Client: A pointer to the available HTTP client.
+//   - error: The context error if the context ends before a client is available,
+//     ErrTenantQuota if the tenant has exhausted a quota, or ErrNoClients if the
+//     pool has no clients other than those excluded.
+func (pool *ClientPool) GetClientContext(ctx context.Context, opts GetClientOptions) (*Client, error) {
+	pool.mu.Lock()
+	pool.queue.seq++
+	clock := pool.clock
+	self := &waiter{priority: opts.Priority, tenant: opts.Tenant, seq: pool.queue.seq, enqueued: clock.Now(), ready: make(chan struct{}, 1)}
+	if err := pool.queue.checkQuota(self.tenant, self.enqueued); err != nil {
+		pool.mu.Unlock()
+		return nil, err
+	}
+	pool.queue.push(self, self.enqueued)
+	pool.mu.Unlock()
+	defer pool.dequeue(self)
+	for woken := false; ; woken = true {
+		pool.mu.Lock()
+		now := clock.Now()
+		head := pool.queue.head(now)
+		var err error
+		if head == self {
+			err = pool.queue.checkQuota(self.tenant, now)
+		}
+		pool.mu.Unlock()
+		if err == nil && head == self && !pool.hasClients(opts.Exclude) {
+			err = ErrNoClients
+		}
+		if err != nil {
+			return nil, err
+		}
+		var wait time.Duration
+		if head == self {
+			if quotaWait := pool.quotaWait(opts); quotaWait > 0 {
+				// Step out of the queue so requests to other hosts are not held up
+				pool.dequeue(self)
+				err := sleepContext(ctx, clock, quotaWait)
+				pool.mu.Lock()
+				pool.queue.push(self, clock.Now())
+				pool.mu.Unlock()
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+			var client *Client
+			client, wait = pool.tryHandout(opts)
+			if client != nil {
+				pool.mu.Lock()
+				pool.queue.served(self.tenant, clock.Now())
+				pool.mu.Unlock()
+				return client, nil
+			}
+		} else if woken {
+			// The order changed since the wake up was sent, pass it on
+			head.signal()
+		}
+		if err := pool.await(ctx, clock, self, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// await blocks a waiter until it is signalled, the wait elapses or the context ends.
+//
+// Parameters:
+//   - ctx (context.Context): The context controlling the wait.
+//   - clock (Clock): The clock of the pool.
+//   - self (*waiter): The waiter.
+//   - wait (time.Duration): The time until the waiter should retry. Use 0 to
+//     wait only for a signal.
+//
+// Returns:
+//   - error: The context error if the context ended.
+func (pool *ClientPool) await(ctx context.Context, clock Clock, self *waiter, wait time.Duration) error {
+	var timeout <-chan time.Time
+	if wait > 0 {
+		timer := clock.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C()
+	} else if idle, ok := clock.(idleAdvancer); ok {
+		// Let an auto advancing clock run the timers which release clients
+		idle.advanceIdle()
+	}
+	select {
+	case <-self.ready:
+		return nil
+	case <-timeout:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// dequeue removes a waiter from the queue and wakes the next head.
+func (pool *ClientPool) dequeue(self *waiter) {
+	pool.mu.Lock()
+	pool.queue.remove(self)
+	pool.mu.Unlock()
+	pool.wake()
+}
+
+// wake signals the waiter at the head of the queue to try to take a client.
+func (pool *ClientPool) wake() {
+	pool.mu.Lock()
+	head := pool.queue.head(pool.clock.Now())
+	pool.mu.Unlock()
+	if head != nil {
+		head.signal()
+	}
+}
+
+// SetPriorityAging raises the priority of waiting requests over time so low
+// priority requests are not starved.
+//
+// Parameters:
+//   - interval (time.Duration): The wait which raises a priority by one. Use 0 to disable aging.
+func (pool *ClientPool) SetPriorityAging(interval time.Duration) {
+	pool.mu.Lock()
+	pool.queue.aging = interval
+	pool.queue.stale = true
+	pool.mu.Unlock()
+	pool.wake()
+}
+
+// GetQueueDepth returns the number of goroutines waiting for a client at each priority.
+//
+// Waiters are counted under the priority they requested, not including aging.
+//
+// Returns:
+//   - map[int]int: Map of priority to number of waiters.
+func (pool *ClientPool) GetQueueDepth() map[int]int {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	depth := make(map[int]int)
+	for _, w := range pool.queue.waiters {
+		depth[w.priority]++
+	}
+	return depth
+}
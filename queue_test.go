@@ -0,0 +1,156 @@
+package HttpClientPool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitForDepth waits until the pool has the given number of waiters.
+func waitForDepth(t *testing.T, pool *ClientPool, waiters int) {
+	t.Helper()
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(time.Millisecond) {
+		total := 0
+		for _, depth := range pool.GetQueueDepth() {
+			total += depth
+		}
+		if total == waiters {
+			return
+		}
+	}
+	t.Fatalf("Expected %d waiters, got %v", waiters, pool.GetQueueDepth())
+}
+
+func TestPriorityQueue(t *testing.T) {
+	pool := NewClientPool(0, 0, nil, nil)
+	held := pool.GetClient()
+	// Queue waiters in arrival order
+	priorities := []int{0, 1, 0, 5, 1}
+	var mu sync.Mutex
+	var served []int
+	var wg sync.WaitGroup
+	for idx, priority := range priorities {
+		wg.Add(1)
+		go func(idx, priority int) {
+			defer wg.Done()
+			client, err := pool.GetClientContext(context.Background(), GetClientOptions{Priority: priority})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			served = append(served, idx)
+			mu.Unlock()
+			client.SetInactive()
+		}(idx, priority)
+		waitForDepth(t, &pool, idx+1)
+	}
+	depth := pool.GetQueueDepth()
+	if depth[0] != 2 || depth[1] != 2 || depth[5] != 1 {
+		t.Fatalf("Unexpected queue depth %v", depth)
+	}
+	held.SetInactive()
+	wg.Wait()
+	// Highest priority first, FIFO inside each priority
+	expected := []int{3, 1, 4, 0, 2}
+	for idx := range expected {
+		if served[idx] != expected[idx] {
+			t.Fatalf("Expected serve order %v, got %v", expected, served)
+		}
+	}
+	if len(pool.GetQueueDepth()) != 0 {
+		t.Fatalf("Expected empty queue, got %v", pool.GetQueueDepth())
+	}
+}
+
+func TestPriorityAging(t *testing.T) {
+	pool := NewClientPool(0, 0, nil, nil)
+	pool.SetPriorityAging(20 * time.Millisecond)
+	held := pool.GetClient()
+	order := make(chan int, 2)
+	go func() {
+		client, _ := pool.GetClientContext(context.Background(), GetClientOptions{Priority: 0})
+		order <- 0
+		client.SetInactive()
+	}()
+	waitForDepth(t, &pool, 1)
+	// Let the low priority waiter age past the high priority one
+	time.Sleep(50 * time.Millisecond)
+	go func() {
+		client, _ := pool.GetClientContext(context.Background(), GetClientOptions{Priority: 1})
+		order <- 1
+		client.SetInactive()
+	}()
+	waitForDepth(t, &pool, 2)
+	held.SetInactive()
+	if first := <-order; first != 0 {
+		t.Fatal("Expected the aged low priority waiter to be served first")
+	}
+	<-order
+}
+
+func TestQueueDeadline(t *testing.T) {
+	pool := NewClientPool(0, 0, nil, nil)
+	held := pool.GetClient()
+	defer held.SetInactive()
+	_, err := pool.QuickRequest(RequestData{
+		Type:     "GET",
+		Url:      "http://127.0.0.1/",
+		Deadline: time.Now().Add(10 * time.Millisecond),
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected deadline exceeded, got %v", err)
+	}
+	if len(pool.GetQueueDepth()) != 0 {
+		t.Fatalf("Expected the expired waiter to leave the queue, got %v", pool.GetQueueDepth())
+	}
+}
+
+// Waiters sleep until a client is released instead of polling the clock
+func TestQueueSignalling(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	pool := NewClientPool(0, 0, nil, nil)
+	pool.SetClock(clock)
+	held := pool.GetClient()
+	done := make(chan *Client, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			client, _ := pool.GetClientContext(context.Background(), GetClientOptions{})
+			done <- client
+		}()
+	}
+	waitForDepth(t, &pool, 3)
+	if clock.Waiters() != 0 {
+		t.Fatalf("Expected no timers while the client is busy, got %d", clock.Waiters())
+	}
+	// Each release wakes the next waiter
+	for i := 0; i < 3; i++ {
+		held.SetInactive()
+		select {
+		case held = <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("Waiter %d was not woken", i+1)
+		}
+	}
+	held.SetInactive()
+	// Adding a client wakes a waiter
+	pool.SetClientMaxInFlight(1)
+	pool.GetClient()
+	go func() {
+		client, _ := pool.GetClientContext(context.Background(), GetClientOptions{})
+		done <- client
+	}()
+	waitForDepth(t, &pool, 1)
+	added := NewClient(nil, "HttpClient", 0)
+	pool.AddClient(added)
+	select {
+	case client := <-done:
+		if client != added {
+			t.Fatal("Expected the added client")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Waiter was not woken by AddClient")
+	}
+}
@@ -0,0 +1,121 @@
+package HttpClientPool
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// httpErrorSnippetSize is the maximum number of body bytes kept in an HTTPError.
+const httpErrorSnippetSize = 512
+
+// QuickRequester is implemented by types able to run a RequestData, such as
+// *Client and *ClientPool.
+type QuickRequester interface {
+	QuickRequest(reqData RequestData) (ResponseData, error)
+}
+
+// HTTPError is returned by the JSON helpers for responses with a non-2xx status code.
+type HTTPError struct {
+	// Status is the human-readable status message of the HTTP response.
+	Status string
+
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+
+	// Headers contains the HTTP headers of the response.
+	Headers map[string][]string
+
+	// Body contains up to the first 512 bytes of the response body.
+	Body []byte
+
+	// Detail contains the error body decoded by QuickJSONWithError, nil otherwise.
+	Detail interface{}
+}
+
+// Error implements the error interface.
+func (err *HTTPError) Error() string {
+	if len(err.Body) == 0 {
+		return fmt.Sprintf("http status %s", err.Status)
+	}
+	return fmt.Sprintf("http status %s: %s", err.Status, err.Body)
+}
+
+// newHTTPError creates an HTTPError from a ResponseData.
+func newHTTPError(response ResponseData) *HTTPError {
+	snippet := response.Body
+	if len(snippet) > httpErrorSnippetSize {
+		snippet = snippet[:httpErrorSnippetSize]
+	}
+	return &HTTPError{
+		Status:     response.Status,
+		StatusCode: response.StatusCode,
+		Headers:    response.Headers,
+		Body:       append([]byte(nil), snippet...),
+	}
+}
+
+// QuickJSON runs a request and decodes a successful JSON response into T.
+//
+// Responses with a non-2xx status code are returned as an *HTTPError. An empty
+// response body leaves T as its zero value. An Accept: application/json header is
+// added unless the request already sets one.
+//
+// Parameters:
+//   - requester (QuickRequester): The *Client or *ClientPool used to make the request.
+//   - reqData (RequestData): The RequestData struct containing HTTP request data.
+//
+// Returns:
+//   - T: The decoded response body.
+//   - ResponseData: A ResponseData struct containing HTTP response data.
+//   - error: An error, if any, encountered during the request or decoding.
+func QuickJSON[T any](requester QuickRequester, reqData RequestData) (T, ResponseData, error) {
+	return QuickJSONWithError[T, json.RawMessage](requester, reqData)
+}
+
+// QuickJSONWithError runs a request and decodes a successful JSON response into T.
+//
+// This behaves like QuickJSON but additionally decodes the body of non-2xx
+// responses into E, which is stored as the Detail of the returned *HTTPError.
+// If the error body cannot be decoded Detail is left nil.
+//
+// Parameters:
+//   - requester (QuickRequester): The *Client or *ClientPool used to make the request.
+//   - reqData (RequestData): The RequestData struct containing HTTP request data.
+//
+// Returns:
+//   - T: The decoded response body.
+//   - ResponseData: A ResponseData struct containing HTTP response data.
+//   - error: An error, if any, encountered during the request or decoding.
+func QuickJSONWithError[T, E any](requester QuickRequester, reqData RequestData) (T, ResponseData, error) {
+	var result T
+	// Request JSON without modifying the callers headers
+	if http.Header(reqData.Headers).Get("Accept") == "" {
+		headers := make(map[string][]string, len(reqData.Headers)+1)
+		for key, values := range reqData.Headers {
+			headers[key] = values
+		}
+		headers["Accept"] = []string{"application/json"}
+		reqData.Headers = headers
+	}
+	response, err := requester.QuickRequest(reqData)
+	if err != nil {
+		return result, response, err
+	}
+	// Check status
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		httpErr := newHTTPError(response)
+		var detail E
+		if len(response.Body) > 0 && json.Unmarshal(response.Body, &detail) == nil {
+			httpErr.Detail = detail
+		}
+		return result, response, httpErr
+	}
+	// Decode body
+	if len(response.Body) > 0 {
+		if err := json.Unmarshal(response.Body, &result); err != nil {
+			return result, response, err
+		}
+	}
+	return result, response, nil
+}
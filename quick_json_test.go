@@ -0,0 +1,65 @@
+package HttpClientPool
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type apiItem struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func TestQuickJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "application/json" {
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/item":
+			w.Write([]byte(`{"id":1,"name":"widget"}`))
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":"not_found","message":"no such item"}`))
+		}
+	}))
+	defer server.Close()
+	pool := NewClientPool(0, 0, nil, nil)
+
+	// Success
+	item, response, err := QuickJSON[apiItem](&pool, RequestData{Type: "GET", Url: server.URL + "/item"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Id != 1 || item.Name != "widget" || response.StatusCode != http.StatusOK {
+		t.Errorf("Unexpected item %+v", item)
+	}
+	// Empty body
+	_, response, err = QuickJSON[apiItem](&pool, RequestData{Type: "GET", Url: server.URL + "/empty"})
+	if err != nil || response.StatusCode != http.StatusNoContent {
+		t.Errorf("Unexpected response %v %v", response.Status, err)
+	}
+	// Typed error
+	_, _, err = QuickJSONWithError[apiItem, apiError](&pool, RequestData{Type: "GET", Url: server.URL + "/missing"})
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("Expected *HTTPError got %v", err)
+	}
+	if httpErr.StatusCode != http.StatusNotFound || httpErr.Headers["Content-Type"][0] != "application/json" {
+		t.Errorf("Unexpected HTTPError %v", httpErr)
+	}
+	if detail, ok := httpErr.Detail.(apiError); !ok || detail.Code != "not_found" {
+		t.Errorf("Unexpected HTTPError detail %#v", httpErr.Detail)
+	}
+}
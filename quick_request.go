@@ -2,14 +2,26 @@ package HttpClientPool
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
 )
 
 // RequestData represents request data to be passed to QuickRequest
+//
+// RequestData can be encoded as JSON. FormFiles, FormUploads and RawData are
+// not encoded.
 type RequestData struct {
 	// Type specifies the HTTP request method (e.g., GET, POST).
 	Type string
@@ -22,28 +34,74 @@ type RequestData struct {
 
 	// JsonData accepts any type with data to be sent in the request body as JSON.
 	//
-	// Cannot be used with FormData, FormFiles or RawData
+	// Cannot be used with Data, FormData, FormFiles or RawData
 	JsonData interface{}
 
+	// Data accepts any value to be encoded with the BodyEncoder registered for ContentType.
+	//
+	// Cannot be used with JsonData, FormData, FormFiles or RawData
+	Data interface{}
+	// ContentType is the content type used to encode Data and is sent as the
+	// Content-Type header (e.g., application/xml).
+	ContentType string
+
 	// FormData contains the data to be sent in the request body as form data.
 	//
-	// Cannot be used with JsonData or RawData
+	// Cannot be used with JsonData, Data or RawData
 	FormData map[string]string
 	// Files contains the files to be included as part of FormData.
 	//
-	// Cannot be used with JsonData or RawData
-	FormFiles map[string]*os.File
+	// Cannot be used with JsonData, Data or RawData
+	FormFiles map[string]*os.File `json:"-"`
+	// FormUploads contains files with an explicit filename and content type
+	// to be included as part of FormData.
+	//
+	// Cannot be used with JsonData, Data or RawData
+	FormUploads map[string]FormUpload `json:"-"`
 
 	// RawData contains the raw request body as an io.Reader.
 	//
-	// Overrides both JsonData and FormData/FormFiles
-	RawData *io.Reader
+	// Cannot be used with JsonData, Data or FormData. If validation is skipped
+	// it overrides JsonData, Data and FormData/FormFiles/FormUploads
+	RawData *io.Reader `json:"-"`
 
 	// Headers contains the HTTP headers for the request. Key:Array of values
 	Headers map[string][]string
 
 	// Cookies contains the cookies to be included in the request.
 	Cookies map[string]string
+
+	// Timeout limits the time taken by the request including reading the body.
+	// Use 0 for no timeout.
+	Timeout time.Duration
+
+	// Priority orders requests waiting for a Client from a ClientPool. Higher
+	// priorities are served first and equal priorities in arrival order.
+	Priority int
+
+	// Tenant identifies the user of a shared ClientPool for fair scheduling and
+	// quotas. See ClientPool.SetTenant.
+	Tenant string
+
+	// Deadline ends the request at the given time, including any time spent
+	// waiting for a Client from a ClientPool. Use the zero time for no deadline.
+	Deadline time.Time
+
+	// SkipValidation disables the RequestData.Validate call made by QuickRequest.
+	SkipValidation bool
+}
+
+// FormUpload represents a file to be sent as part of a multipart form.
+type FormUpload struct {
+	// Reader provides the file content. It is read from its current position
+	// and is not closed.
+	Reader io.Reader
+
+	// Filename is the filename sent for the file.
+	Filename string
+
+	// ContentType is the content type of the file. Defaults to application/octet-stream.
+	ContentType string
 }
 
 // ResponseData represents data from an http.Response returned by QuickRequest
@@ -54,11 +112,20 @@ type ResponseData struct {
 	// StatusCode is the HTTP status code of the response.
 	StatusCode int
 
+	// Headers contains the HTTP headers of the response. Key:Array of values
+	Headers map[string][]string
+
 	// Body contains the raw body of the HTTP response.
 	Body []byte
 
 	// Cookies contains the cookies received in the HTTP response.
 	Cookies map[string]string
+
+	// Url is the final URL of the request after any redirects.
+	Url string
+
+	// Client is the Client which handled the request.
+	Client *Client `json:"-"`
 }
 
 // QuickRequest is a convenience wrapper arround http.Request allowing easy basic requests.
@@ -66,7 +133,8 @@ type ResponseData struct {
 //
 // It allows making HTTP requests with different methods (GET, POST, etc.) and supports request
 // options such as URL parameters, headers, user agent, cookies, and various payload types
-// including JSON, form, and other data using the RawData field.
+// including JSON, form, data encoded by a registered BodyEncoder, and other data using
+// the RawData field.
 //
 // Parameters:
 //   - reqData (RequestData): The RequestData struct containing HTTP request data.
@@ -75,35 +143,88 @@ type ResponseData struct {
 //   - ResponseData: A ResponseData struct containing HTTP response data.
 //   - error: An error, if any, encountered during the HTTP request.
 func (client *Client) QuickRequest(reqData RequestData) (ResponseData, error) {
+	return client.QuickRequestContext(context.Background(), reqData)
+}
+
+// QuickRequestContext performs QuickRequest with a context controlling the request.
+//
+// Parameters:
+//   - ctx (context.Context): The context for the request.
+//   - reqData (RequestData): The RequestData struct containing HTTP request data.
+//
+// Returns:
+//   - ResponseData: A ResponseData struct containing HTTP response data.
+//   - error: An error, if any, encountered during the HTTP request.
+func (client *Client) QuickRequestContext(ctx context.Context, reqData RequestData) (ResponseData, error) {
+	client.mu.Lock()
+	handler := chainMiddleware(client.quickRequest, client.middleware)
+	clock := client.clock
+	client.mu.Unlock()
+	return handler(ContextWithClock(ctx, clock), reqData)
+}
+
+// quickRequest performs the HTTP request for QuickRequestContext once the
+// client middleware has run.
+//
+// Parameters:
+//   - ctx (context.Context): The context for the request.
+//   - reqData (RequestData): The RequestData struct containing HTTP request data.
+//
+// Returns:
+//   - ResponseData: A ResponseData struct containing HTTP response data.
+//   - error: An error, if any, encountered during the HTTP request.
+func (client *Client) quickRequest(ctx context.Context, reqData RequestData) (ResponseData, error) {
 	// Initialize return variable
-	var response = ResponseData{}
-	// Set the request body
-	var bodyReader io.Reader
-	if reqData.RawData != nil {
-		// Use RawData
-		bodyReader = *reqData.RawData
-	} else if reqData.JsonData != nil {
-		// Use JsonData
-		reader, err := jsonDataReader(reqData.JsonData)
-		if err != nil {
-			return response, err
-		}
-		bodyReader = reader
-	} else if reqData.FormData != nil || reqData.FormFiles != nil {
-		// Use FormData
-		reader, err := formDataReader(reqData.FormData, reqData.FormFiles)
-		if err != nil {
+	var response = ResponseData{Client: client}
+	// Validate the request
+	if !reqData.SkipValidation {
+		if err := reqData.Validate(); err != nil {
 			return response, err
 		}
-		bodyReader = reader
-	} else {
-		bodyReader = http.NoBody
+	}
+	// Set the request body
+	bodyReader, contentType, err := reqData.bodyReader()
+	if err != nil {
+		return response, err
+	}
+	// Apply the request timeout and deadline
+	if reqData.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, reqData.Timeout)
+		defer cancel()
+	}
+	if !reqData.Deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, reqData.Deadline)
+		defer cancel()
 	}
 	// Create the request
-	req, err := http.NewRequest(reqData.Type, reqData.Url, bodyReader)
+	req, err := http.NewRequestWithContext(ctx, reqData.Type, reqData.Url, bodyReader)
 	if err != nil {
+		// Release a streaming body
+		if closer, ok := bodyReader.(io.Closer); ok {
+			closer.Close()
+		}
 		return response, err
 	}
+	// Release the body on early returns until Do takes ownership of it
+	bodyOwned := false
+	defer func() {
+		if !bodyOwned {
+			closeRequestBody(req)
+		}
+	}()
+	// Allow seekable bodies to be rewound for redirects and retries
+	if seeker, ok := bodyReader.(io.ReadSeeker); ok && req.GetBody == nil {
+		if offset, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			req.GetBody = func() (io.ReadCloser, error) {
+				if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+					return nil, err
+				}
+				return io.NopCloser(seeker), nil
+			}
+		}
+	}
 	// Set url paramaters
 	if reqData.Params != nil {
 		q := req.URL.Query()
@@ -114,6 +235,10 @@ func (client *Client) QuickRequest(reqData RequestData) (ResponseData, error) {
 		}
 		req.URL.RawQuery = q.Encode()
 	}
+	// Set content type
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
 	// Set headers
 	for key, values := range reqData.Headers {
 		for _, value := range values {
@@ -130,11 +255,51 @@ func (client *Client) QuickRequest(reqData RequestData) (ResponseData, error) {
 		}
 		req.AddCookie(&cookie)
 	}
+	// Authenticate and sign
+	authenticator, signer := client.GetAuthenticator(), client.GetSigner()
+	if err := authorizeRequest(req, authenticator, signer); err != nil {
+		return response, err
+	}
+	// Wait for the host quota unless the pool paced the request
+	key := client.quotaKey(req.URL.Host)
+	quotas, pooled := ctx.Value(quotasKey{}).(*quotaTracker)
+	if !pooled {
+		client.mu.Lock()
+		quotas = client.quotas
+		client.mu.Unlock()
+		if quotas != nil {
+			if err := quotas.wait(ctx, key); err != nil {
+				return response, err
+			}
+		}
+	}
 	// Run request
+	bodyOwned = true
 	res, err := client.Do(req)
 	if err != nil {
 		return response, err
 	}
+	if quotas != nil {
+		quotas.observe(key, client, res.Header, client.GetClock().Now())
+	}
+	// Re-authenticate once if the credentials were rejected
+	if refresher, ok := authenticator.(RefreshingAuthenticator); ok && res.StatusCode == http.StatusUnauthorized {
+		if retry, ok := rewindRequest(req); ok {
+			res.Body.Close()
+			refresher.Invalidate()
+			if err := authorizeRequest(retry, refresher, signer); err != nil {
+				closeRequestBody(retry)
+				return response, err
+			}
+			res, err = client.Do(retry)
+			if err != nil {
+				return response, err
+			}
+			if quotas != nil {
+				quotas.observe(key, client, res.Header, client.GetClock().Now())
+			}
+		}
+	}
 	defer res.Body.Close()
 	// Read the body data
 	responseBody, err := io.ReadAll(res.Body)
@@ -149,13 +314,37 @@ func (client *Client) QuickRequest(reqData RequestData) (ResponseData, error) {
 	response = ResponseData{
 		Status:     res.Status,
 		StatusCode: res.StatusCode,
+		Headers:    res.Header,
 		Body:       responseBody,
 		Cookies:    cookies,
+		Url:        res.Request.URL.String(),
+		Client:     client,
 	}
 	return response, nil
 
 }
 
+// authorizeRequest applies an Authenticator and then a Signer to a request.
+//
+// Parameters:
+//   - req (*http.Request): The request to authorize.
+//   - authenticator (Authenticator): The authenticator to apply, may be nil.
+//   - signer (Signer): The signer to apply, may be nil.
+//
+// Returns:
+//   - error: An error, if any, returned by the authenticator or signer.
+func authorizeRequest(req *http.Request, authenticator Authenticator, signer Signer) error {
+	if authenticator != nil {
+		if err := authenticator.Authenticate(req); err != nil {
+			return err
+		}
+	}
+	if signer != nil {
+		return signer.Sign(req)
+	}
+	return nil
+}
+
 // jsonDataReader converts a Go data structure into a JSON-formatted io.Reader.
 //
 // Parameters:
@@ -173,35 +362,211 @@ func jsonDataReader(data interface{}) (io.Reader, error) {
 	return bodyData, nil
 }
 
-// formDataReader creates a multipart/form-data io.Reader from a map of key-value pairs
-// and a map of files.
+// bodyReader returns an io.Reader for the request body along with its content type.
+//
+// Returns:
+//   - io.Reader: An io.Reader containing the request body.
+//   - string: The content type of the body, empty if unknown.
+//   - error: An error, if any, encountered while encoding the body.
+func (reqData RequestData) bodyReader() (io.Reader, string, error) {
+	if reqData.RawData != nil {
+		// Use RawData
+		return *reqData.RawData, "", nil
+	} else if reqData.JsonData != nil {
+		// Use JsonData
+		reader, err := jsonDataReader(reqData.JsonData)
+		if err != nil {
+			return nil, "", err
+		}
+		return reader, "application/json", nil
+	} else if reqData.Data != nil {
+		// Use Data with the registered BodyEncoder
+		encoder, err := lookupBodyEncoder(reqData.ContentType)
+		if err != nil {
+			return nil, "", err
+		}
+		reader, err := encoder.Encode(reqData.Data)
+		if err != nil {
+			return nil, "", err
+		}
+		return reader, reqData.ContentType, nil
+	} else if reqData.FormData != nil || reqData.FormFiles != nil || reqData.FormUploads != nil {
+		// Use FormData
+		uploads := make(map[string]FormUpload, len(reqData.FormFiles)+len(reqData.FormUploads))
+		for field, file := range reqData.FormFiles {
+			uploads[field] = FormUpload{Reader: file, Filename: filepath.Base(file.Name())}
+		}
+		for field, upload := range reqData.FormUploads {
+			uploads[field] = upload
+		}
+		reader, contentType := formDataReader(reqData.FormData, uploads)
+		return reader, contentType, nil
+	}
+	return http.NoBody, "", nil
+}
+
+// bodyRewinder records the position of the readers in the request body so the
+// body can be sent again, for example when retrying on another Client.
+//
+// Returns:
+//   - func() error: Seeks the readers back to their recorded positions, nil if
+//     the body contains a reader or channel which cannot be rewound.
+func (reqData RequestData) bodyRewinder() func() error {
+	var readers []io.Reader
+	if reqData.RawData != nil {
+		readers = append(readers, *reqData.RawData)
+	}
+	switch data := reqData.Data.(type) {
+	case io.Reader:
+		readers = append(readers, data)
+	case nil, []byte, string:
+	default:
+		if reflect.ValueOf(data).Kind() == reflect.Chan {
+			return nil
+		}
+	}
+	for _, file := range reqData.FormFiles {
+		readers = append(readers, file)
+	}
+	for _, upload := range reqData.FormUploads {
+		readers = append(readers, upload.Reader)
+	}
+	// Record offsets
+	seekers := make([]io.Seeker, len(readers))
+	offsets := make([]int64, len(readers))
+	for idx, reader := range readers {
+		seeker, ok := reader.(io.Seeker)
+		if !ok {
+			return nil
+		}
+		offset, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil
+		}
+		seekers[idx], offsets[idx] = seeker, offset
+	}
+	return func() error {
+		for idx, seeker := range seekers {
+			if _, err := seeker.Seek(offsets[idx], io.SeekStart); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// bodyBytes returns the request body without consuming it.
+//
+// Returns:
+//   - []byte: The encoded body.
+//   - string: The content type of the body, empty if unknown.
+//   - bool: False if the body could not be encoded or rewound.
+func (reqData RequestData) bodyBytes() ([]byte, string, bool) {
+	rewind := reqData.bodyRewinder()
+	if rewind == nil {
+		return nil, "", false
+	}
+	reader, contentType, err := reqData.bodyReader()
+	if err != nil {
+		return nil, "", false
+	}
+	body, err := io.ReadAll(reader)
+	if rewind() != nil || err != nil {
+		return nil, "", false
+	}
+	return body, contentType, true
+}
+
+// fullUrl returns the request URL with the Params added to the query.
+//
+// Returns:
+//   - string: The URL, unchanged if it cannot be parsed.
+func (reqData RequestData) fullUrl() string {
+	if len(reqData.Params) == 0 {
+		return reqData.Url
+	}
+	parsedUrl, err := url.Parse(reqData.Url)
+	if err != nil {
+		return reqData.Url
+	}
+	query := parsedUrl.Query()
+	for key, values := range reqData.Params {
+		query[key] = append(query[key], values...)
+	}
+	parsedUrl.RawQuery = query.Encode()
+	return parsedUrl.String()
+}
+
+// formDataReader creates a streaming multipart/form-data io.Reader from a map of
+// key-value pairs and a map of files.
+//
+// The body is written through an io.Pipe as it is read so files are never held
+// in memory. Errors encountered while writing are returned by the reader.
 //
 // Parameters:
 //   - data (map[string]string): A map of string key-value pairs representing form fields.
-//   - files (map[string]*os.File): A map of files to be included in the request body.
+//   - files (map[string]FormUpload): A map of files to be included in the request body.
 //
 // Returns:
 //   - io.Reader: An io.Reader containing the multipart/form-data request body.
+//   - string: The multipart/form-data content type including the boundary.
+func formDataReader(data map[string]string, files map[string]FormUpload) (io.Reader, string) {
+	pipeReader, pipeWriter := io.Pipe()
+	writer := multipart.NewWriter(pipeWriter)
+	go func() {
+		pipeWriter.CloseWithError(writeFormData(writer, data, files))
+	}()
+	return pipeReader, writer.FormDataContentType()
+}
+
+// writeFormData writes form fields and files to a multipart.Writer and closes it.
+//
+// Parameters:
+//   - writer (*multipart.Writer): The writer for the multipart body.
+//   - data (map[string]string): A map of string key-value pairs representing form fields.
+//   - files (map[string]FormUpload): A map of files to be included in the request body.
+//
+// Returns:
 //   - error: An error, if any, encountered during the construction of the request body.
-func formDataReader(data map[string]string, files map[string]*os.File) (io.Reader, error) {
-	var formData bytes.Buffer
-	writer := multipart.NewWriter(&formData)
+func writeFormData(writer *multipart.Writer, data map[string]string, files map[string]FormUpload) error {
 	// Set Data
-	for field, value := range data {
-		if err := writer.WriteField(field, value); err != nil {
-			return nil, err
+	for _, field := range sortedKeys(data) {
+		if err := writer.WriteField(field, data[field]); err != nil {
+			return err
 		}
 	}
 	// Set Files
-	for field, file := range files {
-		fileField, err := writer.CreateFormFile(field, file.Name())
-		if err != nil {
-			return nil, err
+	for _, field := range sortedKeys(files) {
+		file := files[field]
+		contentType := file.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
 		}
-		_, err = io.Copy(fileField, file)
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+			quoteEscaper.Replace(field), quoteEscaper.Replace(file.Filename)))
+		header.Set("Content-Type", contentType)
+		fileField, err := writer.CreatePart(header)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if _, err = io.Copy(fileField, file.Reader); err != nil {
+			return err
 		}
 	}
-	return &formData, nil
+	// Write the closing boundary
+	return writer.Close()
+}
+
+// quoteEscaper escapes quotes in Content-Disposition parameters as mime/multipart does.
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// sortedKeys returns the keys of a map in sorted order for a deterministic body.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
@@ -3,13 +3,12 @@ package HttpClientPool
 import (
 	"encoding/json"
 	"io"
-	"net/http"
 	"os"
 	"regexp"
-	"sync"
+	"strings"
 	"testing"
-	"time"
-  "context"
+
+	"github.com/RootInit/HttpClientPool/pooltest"
 )
 
 // Runs a battery of various web requests
@@ -17,86 +16,17 @@ func TestQuickRequest(t *testing.T) {
 	// Create Client
 	client := NewClient(nil, "HttpClient", 0)
 	// Start echo webserver
-	serverDone := &sync.WaitGroup{}
-	server := startEchoWebserver(serverDone)
-  // Run Requests
-	getRequestTest(client, t)
-	postJsonRequestTest(client, t)
-	postFormRequestTest(client, t)
-	// Stop echo webserver
-	shutdownCtx, _ := context.WithTimeout(context.Background(), 1*time.Second)
-	err := server.Shutdown(shutdownCtx)
-	if err != nil {
-		t.Error(err)
-	}
-
-	serverDone.Wait()
-}
-
-// RequestData represents the data to be returned by the echo webserver
-type EchoData struct {
-	Method  string              `json:"method"`
-	URL     string              `json:"url"`
-	Headers map[string][]string `json:"headers"`
-	Params  map[string][]string `json:"params"`
-	Body    interface{}         `json:"body"`
-	Cookies map[string]string   `json:"cookies"`
+	server := pooltest.NewEchoServer(t)
+	// Run Requests
+	getRequestTest(client, server.URL, t)
+	postJsonRequestTest(client, server.URL, t)
+	postFormRequestTest(client, server.URL, t)
 }
 
-func startEchoWebserver(wg *sync.WaitGroup) *http.Server {
-
-	mux := http.NewServeMux()
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		// Read request body
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			http.Error(w, "Error reading request body", http.StatusInternalServerError)
-			print(err)
-			return
-		}
-		// Parse request cookies
-		cookies := make(map[string]string)
-		for _, cookie := range r.Cookies() {
-			cookies[cookie.Name] = cookie.Value
-		}
-		requestData := EchoData{
-			Method:  r.Method,
-			URL:     r.URL.Path,
-			Headers: r.Header,
-			Params:  r.URL.Query(),
-			Body:    string(body),
-			Cookies: cookies,
-		}
-		// Return the data
-		responseJSON, err := json.MarshalIndent(requestData, "", "  ")
-		if err != nil {
-			var errMsg = "Error creating JSON response" + err.Error()
-			http.Error(w, errMsg, http.StatusInternalServerError)
-			print(errMsg)
-			return
-		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write(responseJSON)
-	})
-	server := &http.Server{Addr: ":8080", Handler: mux}
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		// Start the web server on port 8080
-		if err := server.ListenAndServe(); err != http.ErrServerClosed {
-			println("Error running webserver:", err)
-		}
-	}()
-	// Give time for webserver to start
-	time.Sleep(time.Millisecond * 100)
-	return server
-}
-
-func getRequestTest(client *Client, t *testing.T) {
+func getRequestTest(client *Client, serverUrl string, t *testing.T) {
 	request := RequestData{
 		Type: "GET",
-		Url:  "http://127.0.0.1:8080",
+		Url:  serverUrl,
 		Params: map[string][]string{
 			"one": {"1"},
 			"two": {"2"},
@@ -112,7 +42,7 @@ func getRequestTest(client *Client, t *testing.T) {
 	if err != nil {
 		t.Fatal(err, string(responseData.Body))
 	}
-	var echo EchoData
+	var echo pooltest.Echo
 	err = json.Unmarshal(responseData.Body, &echo)
 
 	// Check param "one"
@@ -162,24 +92,27 @@ func getRequestTest(client *Client, t *testing.T) {
 	}
 }
 
-func postJsonRequestTest(client *Client, t *testing.T) {
+func postJsonRequestTest(client *Client, serverUrl string, t *testing.T) {
 	request := RequestData{
 		Type:     "POST",
-		Url:      "http://127.0.0.1:8080",
+		Url:      serverUrl,
 		JsonData: struct{ BodyData bool }{true},
 	}
 	responseData, err := client.QuickRequest(request)
 	if err != nil {
 		t.Fatal(err, jsonFmt(responseData.Body))
 	}
-	var echo EchoData
+	var echo pooltest.Echo
 	err = json.Unmarshal(responseData.Body, &echo)
-	if echo.Body.(string) != "{\"BodyData\":true}" {
+	if echo.Body != "{\"BodyData\":true}" {
 		t.Errorf("Unexpected BodyData %v", jsonFmt(echo.Body))
 	}
+	if contentType := echo.Headers["Content-Type"]; len(contentType) != 1 || contentType[0] != "application/json" {
+		t.Errorf("Unexpected Content-Type %v", contentType)
+	}
 }
 
-func postFormRequestTest(client *Client, t *testing.T) {
+func postFormRequestTest(client *Client, serverUrl string, t *testing.T) {
 	// Create test file
 	uploadFile, err := os.CreateTemp("", "uploadTestFile.txt")
 	if err != nil {
@@ -192,12 +125,16 @@ func postFormRequestTest(client *Client, t *testing.T) {
 	}
 	request := RequestData{
 		Type:      "POST",
-		Url:       "http://127.0.0.1:8080",
+		Url:       serverUrl,
 		FormData:  map[string]string{"Field1": "true"},
 		FormFiles: map[string]*os.File{"attachment": uploadFile},
+		FormUploads: map[string]FormUpload{
+			"upload": {Reader: strings.NewReader("{}"), Filename: "data.json", ContentType: "application/json"},
+		},
 	}
 	// Generate expected echo string
-	reader, err := formDataReader(request.FormData, request.FormFiles)
+	uploadFile.Seek(0, io.SeekStart)
+	reader, _, err := request.bodyReader()
 	if err != nil {
 		t.Error(err)
 	}
@@ -205,19 +142,29 @@ func postFormRequestTest(client *Client, t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
+	if !strings.Contains(string(expectedResult), "testdata") || !strings.HasSuffix(string(expectedResult), "--\r\n") {
+		t.Errorf("Unexpected form body\n%s", expectedResult)
+	}
+	uploadFile.Seek(0, io.SeekStart)
+	request.FormUploads["upload"] = FormUpload{Reader: strings.NewReader("{}"), Filename: "data.json", ContentType: "application/json"}
 	responseData, err := client.QuickRequest(request)
 	if err != nil {
 		t.Fatal(err, jsonFmt(responseData.Body))
 	}
-	var echo EchoData
+	var echo pooltest.Echo
 	err = json.Unmarshal(responseData.Body, &echo)
 	// Check body
 	re := regexp.MustCompile(`--([a-fA-F0-9]+)`)
-	echoBody := re.ReplaceAllString(echo.Body.(string), "")
+	echoBody := re.ReplaceAllString(echo.Body, "")
 	expectedBody := re.ReplaceAllString(string(expectedResult), "")
 	if echoBody != expectedBody {
 		t.Errorf("Unexpected BodyData\n%v\nExpected:\n%v", jsonFmt(echoBody), jsonFmt(expectedBody))
 	}
+	// Check content type
+	contentType := echo.Headers["Content-Type"]
+	if len(contentType) != 1 || !strings.HasPrefix(contentType[0], "multipart/form-data; boundary=") {
+		t.Errorf("Unexpected Content-Type %v", contentType)
+	}
 }
 
 func jsonFmt(o interface{}) string {
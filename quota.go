@@ -0,0 +1,359 @@
+package HttpClientPool
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QuotaState is the rate limit quota advertised by a host.
+type QuotaState struct {
+	// Host is the host the quota applies to.
+	Host string
+	// Proxy is the proxy the quota applies to, empty for clients without a proxy.
+	// Hosts see clients sharing a proxy as one, so they share its quotas.
+	Proxy string
+	// Client is the client which last received the quota.
+	Client *Client `json:"-"`
+	// Limit is the number of requests allowed per window, 0 if unknown.
+	Limit int
+	// Remaining is the number of requests remaining before Reset.
+	Remaining int
+	// Reset is when the quota resets.
+	Reset time.Time
+	// Window is the length of the quota window, 0 if unknown.
+	Window time.Duration
+	// Updated is when the quota was last received from the host.
+	Updated time.Time
+
+	// lastSent is when the last request using the quota was released.
+	lastSent time.Time
+}
+
+// quotaKey identifies the quota of a host seen from a proxy.
+type quotaKey struct {
+	host  string
+	proxy string
+}
+
+// quotaTracker paces requests using the quotas advertised by hosts.
+type quotaTracker struct {
+	states map[quotaKey]*QuotaState
+	mu     sync.Mutex
+}
+
+// quotasKey is the context key of the quotaTracker of the pool running a request.
+type quotasKey struct{}
+
+// newQuotaTracker creates an empty quotaTracker.
+func newQuotaTracker() *quotaTracker {
+	return &quotaTracker{states: make(map[quotaKey]*QuotaState)}
+}
+
+// SetQuotaTracking enables pacing requests using the X-RateLimit-*, RateLimit-*,
+// RateLimit-Policy and Retry-After response headers.
+//
+// Quotas are tracked per host. Requests are spread evenly over the remaining
+// window so Remaining does not reach zero before Reset. Requests made through a
+// ClientPool are paced by the pool's quota tracking instead, see
+// ClientPool.SetQuotaTracking.
+//
+// Parameters:
+//   - enabled (bool): True to enable quota tracking.
+func (client *Client) SetQuotaTracking(enabled bool) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if !enabled {
+		client.quotas = nil
+	} else if client.quotas == nil {
+		client.quotas = newQuotaTracker()
+	}
+}
+
+// GetQuotas returns the quotas currently tracked by the client.
+//
+// Returns:
+//   - []QuotaState: The quota of each host, nil if quota tracking is disabled.
+func (client *Client) GetQuotas() []QuotaState {
+	client.mu.Lock()
+	tracker := client.quotas
+	client.mu.Unlock()
+	return tracker.list()
+}
+
+// SetQuotaTracking enables pacing the requests of the pool using the rate
+// limit headers of the responses.
+//
+// Quotas are tracked per host and proxy, so clients sharing a proxy, or using
+// none, share the quotas of each host. Clients whose quota for the requested
+// host is exhausted are not handed out until it resets, so waiting for a quota
+// does not hold a client or count towards the in-flight limits.
+//
+// Parameters:
+//   - enabled (bool): True to enable quota tracking.
+func (pool *ClientPool) SetQuotaTracking(enabled bool) {
+	pool.mu.Lock()
+	if !enabled {
+		pool.quotas = nil
+	} else if pool.quotas == nil {
+		pool.quotas = newQuotaTracker()
+	}
+	pool.mu.Unlock()
+	pool.wake()
+}
+
+// GetQuotas returns the quotas tracked by the pool.
+//
+// Returns:
+//   - []QuotaState: The quota of each host and proxy, nil if quota tracking is disabled.
+func (pool *ClientPool) GetQuotas() []QuotaState {
+	pool.mu.Lock()
+	tracker := pool.quotas
+	pool.mu.Unlock()
+	return tracker.list()
+}
+
+// list returns the tracked quotas ordered by host and proxy.
+func (tracker *quotaTracker) list() []QuotaState {
+	if tracker == nil {
+		return nil
+	}
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	quotas := make([]QuotaState, 0, len(tracker.states))
+	for _, quota := range tracker.states {
+		quotas = append(quotas, *quota)
+	}
+	sort.Slice(quotas, func(i, j int) bool {
+		if quotas[i].Host != quotas[j].Host {
+			return quotas[i].Host < quotas[j].Host
+		}
+		return quotas[i].Proxy < quotas[j].Proxy
+	})
+	return quotas
+}
+
+// next returns the time until the quota allows another request. tracker.mu must be held.
+func (quota *QuotaState) next(now time.Time) time.Duration {
+	if !now.Before(quota.Reset) {
+		return 0
+	}
+	if quota.Remaining <= 0 {
+		// Wait for the quota to reset
+		return quota.Reset.Sub(now)
+	}
+	// Spread the remaining requests over the window
+	interval := quota.Reset.Sub(quota.lastSent) / time.Duration(quota.Remaining)
+	return max(quota.lastSent.Add(interval).Sub(now), 0)
+}
+
+// allow returns the time until a request to a host from a proxy is allowed.
+//
+// Parameters:
+//   - key (quotaKey): The host and proxy of the request.
+//   - now (time.Time): The current time.
+//
+// Returns:
+//   - time.Duration: The time until the request is allowed, 0 if allowed now.
+func (tracker *quotaTracker) allow(key quotaKey, now time.Time) time.Duration {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	quota, exists := tracker.states[key]
+	if !exists {
+		return 0
+	}
+	return quota.next(now)
+}
+
+// take records a request sent using the quota of a host and proxy.
+func (tracker *quotaTracker) take(key quotaKey, now time.Time) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	quota, exists := tracker.states[key]
+	if !exists {
+		return
+	}
+	if now.Before(quota.Reset) && quota.Remaining > 0 {
+		quota.Remaining--
+	}
+	quota.lastSent = now
+}
+
+// wait blocks until a request is allowed by its quota and takes it. It paces
+// the requests of a Client used without a pool.
+//
+// Parameters:
+//   - ctx (context.Context): The context of the request.
+//   - key (quotaKey): The host and proxy of the request.
+//
+// Returns:
+//   - error: The context error if the context ends while waiting.
+func (tracker *quotaTracker) wait(ctx context.Context, key quotaKey) error {
+	clock := clockFromContext(ctx)
+	for {
+		wait := tracker.allow(key, clock.Now())
+		if wait <= 0 {
+			tracker.take(key, clock.Now())
+			return nil
+		}
+		if err := sleepContext(ctx, clock, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// observe updates the quota of a host from response headers.
+//
+// Parameters:
+//   - key (quotaKey): The host and proxy of the request.
+//   - client (*Client): The client which received the response.
+//   - header (http.Header): The response headers.
+//   - now (time.Time): The time the response was received.
+func (tracker *quotaTracker) observe(key quotaKey, client *Client, header http.Header, now time.Time) {
+	quota, ok := parseQuotaHeaders(header, now)
+	if !ok {
+		return
+	}
+	quota.Host, quota.Proxy, quota.Client = key.host, key.proxy, client
+	quota.lastSent = now
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	tracker.states[key] = &quota
+}
+
+// quotaKey returns the key of the quotas of a host seen by the client.
+func (client *Client) quotaKey(host string) quotaKey {
+	key := quotaKey{host: host}
+	if proxy := client.GetProxy(); proxy != nil {
+		key.proxy = proxy.String()
+	}
+	return key
+}
+
+// parseQuotaHeaders parses rate limit headers into a QuotaState.
+//
+// Supported headers are X-RateLimit-Limit/Remaining/Reset, RateLimit-Limit/
+// Remaining/Reset, the structured RateLimit and RateLimit-Policy headers and
+// Retry-After. Reset values above 1e9 are treated as unix timestamps and smaller
+// values as seconds from now.
+//
+// Parameters:
+//   - header (http.Header): The response headers.
+//   - now (time.Time): The time the response was received.
+//
+// Returns:
+//   - QuotaState: The parsed quota.
+//   - bool: False if no quota headers were present.
+func parseQuotaHeaders(header http.Header, now time.Time) (QuotaState, bool) {
+	quota := QuotaState{Remaining: -1, Updated: now}
+	parseReset := func(value string) {
+		if seconds, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+			if seconds > 1e9 {
+				quota.Reset = time.Unix(0, int64(seconds*float64(time.Second)))
+			} else {
+				quota.Reset = now.Add(time.Duration(seconds * float64(time.Second)))
+			}
+		}
+	}
+	// Separate headers
+	for _, prefix := range []string{"X-Ratelimit-", "Ratelimit-"} {
+		if value, err := strconv.Atoi(strings.TrimSpace(header.Get(prefix + "Limit"))); err == nil {
+			quota.Limit = value
+		}
+		if value, err := strconv.Atoi(strings.TrimSpace(header.Get(prefix + "Remaining"))); err == nil {
+			quota.Remaining = value
+		}
+		if value := header.Get(prefix + "Reset"); value != "" {
+			parseReset(value)
+		}
+	}
+	// Structured headers
+	for name, value := range parseQuotaParams(header.Get("Ratelimit-Policy")) {
+		switch name {
+		case "q", "limit":
+			quota.Limit, _ = strconv.Atoi(value)
+		case "w", "window":
+			if seconds, err := strconv.Atoi(value); err == nil {
+				quota.Window = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	for name, value := range parseQuotaParams(header.Get("Ratelimit")) {
+		switch name {
+		case "q", "limit":
+			quota.Limit, _ = strconv.Atoi(value)
+		case "r", "remaining":
+			if remaining, err := strconv.Atoi(value); err == nil {
+				quota.Remaining = remaining
+			}
+		case "t", "reset":
+			parseReset(value)
+		}
+	}
+	// Retry-After empties the quota until the given time
+	if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+			quota.Remaining, quota.Reset = 0, now.Add(time.Duration(seconds)*time.Second)
+		} else if date, err := http.ParseTime(value); err == nil {
+			quota.Remaining, quota.Reset = 0, date
+		}
+	}
+	if quota.Remaining < 0 {
+		return quota, false
+	}
+	if quota.Reset.IsZero() {
+		if quota.Window == 0 {
+			return quota, false
+		}
+		quota.Reset = now.Add(quota.Window)
+	}
+	return quota, true
+}
+
+// parseQuotaParams parses the parameters of the first item of a structured
+// RateLimit header, e.g. `"default";r=50;t=30` or `limit=100, remaining=50, reset=30`.
+func parseQuotaParams(value string) map[string]string {
+	params := make(map[string]string)
+	if value == "" {
+		return params
+	}
+	separator := ";"
+	if !strings.Contains(value, ";") {
+		separator = ","
+	} else {
+		// Only use the first policy item
+		value, _, _ = strings.Cut(value, ",")
+	}
+	for _, param := range strings.Split(value, separator) {
+		name, paramValue, found := strings.Cut(strings.TrimSpace(param), "=")
+		if found {
+			params[strings.ToLower(name)] = strings.Trim(paramValue, `"`)
+		} else if _, err := strconv.Atoi(name); err == nil {
+			// Bare quota such as "100;w=60"
+			params["q"] = name
+		}
+	}
+	return params
+}
+
+// sleepContext sleeps on a clock for a duration or until the context ends.
+//
+// Returns:
+//   - error: The context error if the context ended first.
+func sleepContext(ctx context.Context, clock Clock, duration time.Duration) error {
+	if duration <= 0 {
+		return ctx.Err()
+	}
+	timer := clock.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,164 @@
+package HttpClientPool
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Runs requests against a server allowing 3 requests per 1 second window
+func TestQuotaTracking(t *testing.T) {
+	const limit = 3
+	clock := NewFakeClock(time.Unix(0, 0))
+	clock.SetAutoAdvance(true)
+	var mu sync.Mutex
+	windowStart := clock.Now()
+	used := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		now := clock.Now()
+		if now.Sub(windowStart) >= time.Second {
+			windowStart, used = now, 0
+		}
+		used++
+		reset := windowStart.Add(time.Second).Sub(now).Seconds()
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(max(limit-used, 0)))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatFloat(reset, 'f', 3, 64))
+		if used > limit {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+	pool := NewClientPool(0, 0, nil, nil)
+	pool.SetClock(clock)
+	pool.SetQuotaTracking(true)
+	for i := 0; i < 2*limit; i++ {
+		response, err := pool.QuickRequest(RequestData{Type: "GET", Url: server.URL})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.StatusCode != http.StatusOK {
+			t.Errorf("Request %d was rate limited", i)
+		}
+	}
+	// The remaining requests of each window are spread evenly until its reset,
+	// 500ms apart, so the sixth request starts after 2.5s
+	if elapsed := clock.Now().Sub(time.Unix(0, 0)); elapsed != 2500*time.Millisecond {
+		t.Errorf("Requests took an unexpected amount of time (%s)", elapsed)
+	}
+	quotas := pool.GetQuotas()
+	if len(quotas) != 1 || quotas[0].Limit != limit || quotas[0].Client != pool.Clients[0] {
+		t.Errorf("Unexpected quotas %+v", quotas)
+	}
+}
+
+// The request body is released when the quota wait ends before sending
+func TestQuotaWaitClosesBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", "3600")
+	}))
+	defer server.Close()
+	client := NewClient(nil, "HttpClient", 0)
+	client.SetQuotaTracking(true)
+	if _, err := client.QuickRequest(RequestData{Type: "GET", Url: server.URL}); err != nil {
+		t.Fatal(err)
+	}
+	body := &readCloser{Reader: strings.NewReader("data")}
+	var reader io.Reader = body
+	_, err := client.QuickRequest(RequestData{
+		Type:     "POST",
+		Url:      server.URL,
+		RawData:  &reader,
+		Deadline: time.Now().Add(20 * time.Millisecond),
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the quota wait to time out, got %v", err)
+	}
+	if !body.closed.Load() {
+		t.Error("Request body not closed")
+	}
+}
+
+// Waiting for an exhausted host quota holds no client
+func TestQuotaHandout(t *testing.T) {
+	limited := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", "3600")
+	}))
+	defer limited.Close()
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer other.Close()
+	clock := NewFakeClock(time.Unix(0, 0))
+	pool := NewClientPool(0, 0, nil, nil)
+	pool.SetClock(clock)
+	pool.SetQuotaTracking(true)
+	if _, err := pool.QuickRequest(RequestData{Type: "GET", Url: limited.URL}); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		_, err := pool.QuickRequest(RequestData{Type: "GET", Url: limited.URL})
+		done <- err
+	}()
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if pool.GetInFlight() != 0 {
+		t.Fatal("Expected the quota wait to hold no client")
+	}
+	// Other hosts are not held up by the queue head waiting for the quota
+	if _, err := pool.QuickRequest(RequestData{Type: "GET", Url: other.URL}); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	quotas := pool.GetQuotas()
+	if len(quotas) != 1 || quotas[0].Host != limited.Listener.Addr().String() || quotas[0].Proxy != "" {
+		t.Errorf("Unexpected quotas %+v", quotas)
+	}
+}
+
+func TestParseQuotaHeaders(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := map[string]struct {
+		header    http.Header
+		limit     int
+		remaining int
+		reset     time.Duration
+	}{
+		"x-ratelimit epoch":  {http.Header{"X-Ratelimit-Limit": {"100"}, "X-Ratelimit-Remaining": {"40"}, "X-Ratelimit-Reset": {"1700000030"}}, 100, 40, 30 * time.Second},
+		"ratelimit delta":    {http.Header{"Ratelimit-Limit": {"10"}, "Ratelimit-Remaining": {"5"}, "Ratelimit-Reset": {"7"}}, 10, 5, 7 * time.Second},
+		"ratelimit combined": {http.Header{"Ratelimit": {"limit=100, remaining=50, reset=30"}}, 100, 50, 30 * time.Second},
+		"ratelimit structured": {http.Header{
+			"Ratelimit":        {`"default";r=50;t=30`},
+			"Ratelimit-Policy": {`"default";q=100;w=60`},
+		}, 100, 50, 30 * time.Second},
+		"policy window": {http.Header{"Ratelimit-Remaining": {"9"}, "Ratelimit-Policy": {"10;w=60"}}, 10, 9, time.Minute},
+		"retry-after":   {http.Header{"Retry-After": {"120"}}, 0, 0, 2 * time.Minute},
+	}
+	for name, test := range tests {
+		quota, ok := parseQuotaHeaders(test.header, now)
+		if !ok {
+			t.Errorf("%s: headers not parsed", name)
+			continue
+		}
+		if quota.Limit != test.limit || quota.Remaining != test.remaining || !quota.Reset.Equal(now.Add(test.reset)) {
+			t.Errorf("%s: Unexpected quota %+v", name, quota)
+		}
+	}
+	if _, ok := parseQuotaHeaders(http.Header{}, now); ok {
+		t.Error("Expected no quota for empty headers")
+	}
+}
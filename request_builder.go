@@ -0,0 +1,209 @@
+package HttpClientPool
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// contextRequester is implemented by *Client and *ClientPool.
+type contextRequester interface {
+	QuickRequestContext(ctx context.Context, reqData RequestData) (ResponseData, error)
+}
+
+// RequestBuilder builds a RequestData using chained method calls.
+//
+// Create a RequestBuilder with ClientPool.NewRequest or Client.NewRequest. Errors
+// from any step are kept and returned by Build or Do.
+//
+// Example:
+//
+//	response, err := pool.NewRequest("POST", "https://api.example.com/items").
+//		Param("page", "1").
+//		BearerToken(token).
+//		JSON(item).
+//		Timeout(10 * time.Second).
+//		Do(ctx)
+type RequestBuilder struct {
+	requester contextRequester
+	reqData   RequestData
+	err       error
+}
+
+// NewRequest creates a RequestBuilder which runs the request through the pool.
+//
+// Parameters:
+//   - method (string): The HTTP request method (e.g., GET, POST).
+//   - url (string): The URL of the HTTP request.
+//
+// Returns:
+//   - *RequestBuilder: The new request builder.
+func (pool *ClientPool) NewRequest(method, url string) *RequestBuilder {
+	return newRequestBuilder(pool, method, url)
+}
+
+// NewRequest creates a RequestBuilder which runs the request with the client.
+//
+// Parameters:
+//   - method (string): The HTTP request method (e.g., GET, POST).
+//   - url (string): The URL of the HTTP request.
+//
+// Returns:
+//   - *RequestBuilder: The new request builder.
+func (client *Client) NewRequest(method, url string) *RequestBuilder {
+	return newRequestBuilder(client, method, url)
+}
+
+// newRequestBuilder creates a RequestBuilder for a requester.
+func newRequestBuilder(requester contextRequester, method, url string) *RequestBuilder {
+	return &RequestBuilder{
+		requester: requester,
+		reqData: RequestData{
+			Type: method,
+			Url:  url,
+		},
+	}
+}
+
+// Param adds a url parameter value.
+func (builder *RequestBuilder) Param(key, value string) *RequestBuilder {
+	if builder.reqData.Params == nil {
+		builder.reqData.Params = make(map[string][]string)
+	}
+	builder.reqData.Params[key] = append(builder.reqData.Params[key], value)
+	return builder
+}
+
+// Header sets a header, replacing any existing value.
+func (builder *RequestBuilder) Header(key, value string) *RequestBuilder {
+	if builder.reqData.Headers == nil {
+		builder.reqData.Headers = make(map[string][]string)
+	}
+	builder.reqData.Headers[key] = []string{value}
+	return builder
+}
+
+// Cookie sets a cookie.
+func (builder *RequestBuilder) Cookie(name, value string) *RequestBuilder {
+	if builder.reqData.Cookies == nil {
+		builder.reqData.Cookies = make(map[string]string)
+	}
+	builder.reqData.Cookies[name] = value
+	return builder
+}
+
+// BearerToken sets the Authorization header to a bearer token.
+func (builder *RequestBuilder) BearerToken(token string) *RequestBuilder {
+	return builder.Header("Authorization", "Bearer "+token)
+}
+
+// BasicAuth sets the Authorization header to basic authentication credentials.
+func (builder *RequestBuilder) BasicAuth(username, password string) *RequestBuilder {
+	credentials := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	return builder.Header("Authorization", "Basic "+credentials)
+}
+
+// JSON sets the request body to the JSON encoding of data.
+func (builder *RequestBuilder) JSON(data interface{}) *RequestBuilder {
+	builder.reqData.JsonData = data
+	return builder
+}
+
+// Encode sets the request body to data encoded by the BodyEncoder registered for contentType.
+func (builder *RequestBuilder) Encode(contentType string, data interface{}) *RequestBuilder {
+	builder.reqData.Data = data
+	builder.reqData.ContentType = contentType
+	return builder
+}
+
+// FormField adds a multipart form field.
+func (builder *RequestBuilder) FormField(field, value string) *RequestBuilder {
+	if builder.reqData.FormData == nil {
+		builder.reqData.FormData = make(map[string]string)
+	}
+	builder.reqData.FormData[field] = value
+	return builder
+}
+
+// FormFile adds a multipart form file.
+func (builder *RequestBuilder) FormFile(field string, upload FormUpload) *RequestBuilder {
+	if builder.reqData.FormUploads == nil {
+		builder.reqData.FormUploads = make(map[string]FormUpload)
+	}
+	builder.reqData.FormUploads[field] = upload
+	return builder
+}
+
+// Body sets the raw request body from a []byte, string or io.Reader.
+//
+// []byte, string and io.Seeker bodies are rewound when the request is retried
+// or redirected.
+func (builder *RequestBuilder) Body(body interface{}) *RequestBuilder {
+	var reader io.Reader
+	switch data := body.(type) {
+	case []byte:
+		reader = bytes.NewReader(data)
+	case string:
+		reader = strings.NewReader(data)
+	case io.Reader:
+		reader = data
+	default:
+		builder.err = fmt.Errorf("unsupported body type %T", body)
+		return builder
+	}
+	builder.reqData.RawData = &reader
+	return builder
+}
+
+// Timeout limits the time taken by the request. Use 0 for no timeout.
+func (builder *RequestBuilder) Timeout(timeout time.Duration) *RequestBuilder {
+	builder.reqData.Timeout = timeout
+	return builder
+}
+
+// Priority sets the priority of the request when waiting for a Client from a ClientPool.
+func (builder *RequestBuilder) Priority(priority int) *RequestBuilder {
+	builder.reqData.Priority = priority
+	return builder
+}
+
+// Tenant sets the tenant the request is scheduled and counted under.
+func (builder *RequestBuilder) Tenant(tenant string) *RequestBuilder {
+	builder.reqData.Tenant = tenant
+	return builder
+}
+
+// Deadline ends the request at the given time, including time spent waiting for a Client.
+func (builder *RequestBuilder) Deadline(deadline time.Time) *RequestBuilder {
+	builder.reqData.Deadline = deadline
+	return builder
+}
+
+// Build returns the RequestData built so far.
+//
+// Returns:
+//   - RequestData: The built RequestData.
+//   - error: The first error, if any, encountered while building.
+func (builder *RequestBuilder) Build() (RequestData, error) {
+	return builder.reqData, builder.err
+}
+
+// Do runs the request with QuickRequestContext.
+//
+// Parameters:
+//   - ctx (context.Context): The context for the request.
+//
+// Returns:
+//   - ResponseData: A ResponseData struct containing HTTP response data.
+//   - error: An error, if any, encountered while building or during the HTTP request.
+func (builder *RequestBuilder) Do(ctx context.Context) (ResponseData, error) {
+	reqData, err := builder.Build()
+	if err != nil {
+		return ResponseData{}, err
+	}
+	return builder.requester.QuickRequestContext(ctx, reqData)
+}
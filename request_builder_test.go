@@ -0,0 +1,62 @@
+package HttpClientPool
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequestBuilder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, "/echo?"+r.URL.RawQuery, http.StatusTemporaryRedirect)
+		case "/slow":
+			time.Sleep(50 * time.Millisecond)
+		default:
+			body, _ := io.ReadAll(r.Body)
+			w.Write([]byte(r.Method + " " + r.URL.Query().Get("q") + " " + r.Header.Get("Authorization") + " " + string(body)))
+		}
+	}))
+	defer server.Close()
+	pool := NewClientPool(0, 0, nil, nil)
+
+	// Seekable body survives a 307 redirect
+	body := struct{ io.ReadSeeker }{bytes.NewReader([]byte("payload"))}
+	response, err := pool.NewRequest("POST", server.URL+"/redirect").
+		Param("q", "1").
+		BearerToken("token").
+		Body(body).
+		Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(response.Body) != "POST 1 Bearer token payload" {
+		t.Errorf("Unexpected body %q", response.Body)
+	}
+	// String body
+	response, err = pool.Clients[0].NewRequest("PUT", server.URL+"/echo").
+		BasicAuth("user", "pass").
+		Body("text").
+		Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(response.Body) != "PUT  Basic dXNlcjpwYXNz text" {
+		t.Errorf("Unexpected body %q", response.Body)
+	}
+	// Unsupported body
+	if _, err := pool.NewRequest("POST", server.URL).Body(1).Build(); err == nil {
+		t.Error("Expected error for unsupported body type")
+	}
+	// Timeout
+	_, err = pool.NewRequest("GET", server.URL+"/slow").Timeout(10 * time.Millisecond).Do(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected timeout got %v", err)
+	}
+}
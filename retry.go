@@ -0,0 +1,92 @@
+package HttpClientPool
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy configures the Retry middleware.
+type RetryPolicy struct {
+	// Attempts is the maximum number of retries after the first attempt.
+	Attempts int
+
+	// Backoff is the delay before the first retry. It doubles after each retry.
+	Backoff time.Duration
+
+	// MaxBackoff caps the delay between retries. Use 0 for no cap.
+	MaxBackoff time.Duration
+
+	// Statuses are the response status codes which are retried. Defaults to
+	// 429, 502, 503 and 504. Request errors are retried unless the context has
+	// ended or the error is a validation error, ErrTenantQuota, ErrBanned or
+	// ErrNoClients.
+	Statuses []int
+}
+
+// defaultRetryStatuses are the status codes retried when RetryPolicy.Statuses is empty.
+var defaultRetryStatuses = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// Retry returns middleware which retries failed requests with exponential backoff.
+//
+// Requests with a body which cannot be rewound are not retried.
+//
+// Parameters:
+//   - policy (RetryPolicy): The retry policy.
+//
+// Returns:
+//   - Middleware: The retry middleware.
+func Retry(policy RetryPolicy) Middleware {
+	statuses := policy.Statuses
+	if len(statuses) == 0 {
+		statuses = defaultRetryStatuses
+	}
+	retryable := func(response ResponseData, err error) bool {
+		if err != nil {
+			var validationErr *ValidationError
+			if errors.As(err, &validationErr) {
+				return false
+			}
+			for _, permanent := range []error{context.Canceled, context.DeadlineExceeded, ErrTenantQuota, ErrBanned, ErrNoClients} {
+				if errors.Is(err, permanent) {
+					return false
+				}
+			}
+			return true
+		}
+		for _, status := range statuses {
+			if response.StatusCode == status {
+				return true
+			}
+		}
+		return false
+	}
+	return func(next Handler) Handler {
+		return func(ctx context.Context, reqData RequestData) (ResponseData, error) {
+			rewind := reqData.bodyRewinder()
+			backoff := policy.Backoff
+			for attempt := 0; ; attempt++ {
+				response, err := next(ctx, reqData)
+				if attempt >= policy.Attempts || rewind == nil || !retryable(response, err) {
+					return response, err
+				}
+				if sleepErr := sleepContext(ctx, clockFromContext(ctx), backoff); sleepErr != nil {
+					return response, err
+				}
+				if err := rewind(); err != nil {
+					return response, err
+				}
+				backoff *= 2
+				if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+					backoff = policy.MaxBackoff
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package HttpClientPool
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnrewindableBody is returned by HMACSigner, and by SigV4Signer for services
+// other than s3, for request bodies which cannot be rewound.
+var ErrUnrewindableBody = errors.New("request body cannot be rewound for signing")
+
+// Signer signs requests made by a Client.
+//
+// Sign is called by QuickRequest immediately before the request is sent, after
+// the params, headers, user agent, cookies and authentication have been set.
+type Signer interface {
+	Sign(req *http.Request) error
+}
+
+// SignerFunc is an adapter allowing a function to be used as a Signer.
+type SignerFunc func(req *http.Request) error
+
+// Sign calls f(req).
+func (f SignerFunc) Sign(req *http.Request) error {
+	return f(req)
+}
+
+// HMACSigner signs requests with an HMAC over the method, path, timestamp and body.
+//
+// The string to sign is:
+//
+//	METHOD + "\n" + PATH?QUERY + "\n" + TIMESTAMP + "\n" + hex(hash(BODY))
+//
+// The timestamp is sent in TimestampHeader as unix seconds and the hex encoded
+// signature in SignatureHeader.
+//
+// The body is hashed by reading a copy from req.GetBody, so the body must be
+// rewindable. Bodies set from RequestData fields or seekable readers are,
+// streaming bodies are not and fail with ErrUnrewindableBody rather than being
+// buffered in memory.
+type HMACSigner struct {
+	// Key is the shared secret.
+	Key []byte
+	// Hash is the hash function. Defaults to sha256.New.
+	Hash func() hash.Hash
+	// SignatureHeader defaults to X-Signature.
+	SignatureHeader string
+	// TimestampHeader defaults to X-Timestamp.
+	TimestampHeader string
+	// Now returns the signing time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Sign sets the timestamp and signature headers.
+func (signer HMACSigner) Sign(req *http.Request) error {
+	hashFunc := signer.Hash
+	if hashFunc == nil {
+		hashFunc = sha256.New
+	}
+	signatureHeader := signer.SignatureHeader
+	if signatureHeader == "" {
+		signatureHeader = "X-Signature"
+	}
+	timestampHeader := signer.TimestampHeader
+	if timestampHeader == "" {
+		timestampHeader = "X-Timestamp"
+	}
+	now := time.Now
+	if signer.Now != nil {
+		now = signer.Now
+	}
+	body, rewindable, err := requestBody(req)
+	if err != nil {
+		return err
+	}
+	if !rewindable {
+		return ErrUnrewindableBody
+	}
+	bodyHash := hashFunc()
+	bodyHash.Write(body)
+	timestamp := strconv.FormatInt(now().Unix(), 10)
+	stringToSign := strings.Join([]string{
+		req.Method,
+		req.URL.RequestURI(),
+		timestamp,
+		hex.EncodeToString(bodyHash.Sum(nil)),
+	}, "\n")
+	mac := hmac.New(hashFunc, signer.Key)
+	mac.Write([]byte(stringToSign))
+	req.Header.Set(timestampHeader, timestamp)
+	req.Header.Set(signatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	return nil
+}
+
+// SigV4Signer signs requests with AWS Signature Version 4.
+//
+// The host, content-type, content-md5 and x-amz-* headers are signed. Requests
+// to the s3 service also send the X-Amz-Content-Sha256 header and use single
+// encoding of the path as S3 requires.
+//
+// The payload hash is computed from a copy of the body read from req.GetBody.
+// Streaming bodies which cannot be rewound are not buffered. Requests to s3 send
+// them with the UNSIGNED-PAYLOAD hash instead, other services do not accept it
+// and fail with ErrUnrewindableBody.
+type SigV4Signer struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is sent as X-Amz-Security-Token when using temporary credentials.
+	SessionToken string
+	// Region is the signing region (e.g., us-east-1).
+	Region string
+	// Service is the signing service name (e.g., s3).
+	Service string
+	// UnsignedPayload skips hashing the body of every request. Only supported by s3.
+	UnsignedPayload bool
+	// Now returns the signing time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Sign sets the X-Amz-Date and Authorization headers.
+func (signer SigV4Signer) Sign(req *http.Request) error {
+	now := time.Now
+	if signer.Now != nil {
+		now = signer.Now
+	}
+	signTime := now().UTC()
+	amzDate := signTime.Format("20060102T150405Z")
+	date := signTime.Format("20060102")
+	// Hash the payload
+	payloadHash := "UNSIGNED-PAYLOAD"
+	if !signer.UnsignedPayload {
+		body, rewindable, err := requestBody(req)
+		if err != nil {
+			return err
+		}
+		if rewindable {
+			payloadHash = hexSha256(body)
+		} else if signer.Service != "s3" {
+			return ErrUnrewindableBody
+		}
+	}
+	req.Header.Set("X-Amz-Date", amzDate)
+	if signer.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", signer.SessionToken)
+	}
+	if signer.Service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+	// Build the canonical request
+	canonicalHeaders, signedHeaders := sigV4Headers(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		sigV4Path(req.URL.EscapedPath(), signer.Service != "s3"),
+		sigV4Query(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	// Build the string to sign
+	scope := strings.Join([]string{date, signer.Region, signer.Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hexSha256([]byte(canonicalRequest)),
+	}, "\n")
+	// Derive the signing key
+	key := hmacSha256([]byte("AWS4"+signer.SecretAccessKey), date)
+	key = hmacSha256(key, signer.Region)
+	key = hmacSha256(key, signer.Service)
+	key = hmacSha256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSha256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+signer.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+	return nil
+}
+
+// sigV4Headers returns the canonical headers and signed headers of a request.
+func sigV4Headers(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for key, values := range req.Header {
+		name := strings.ToLower(key)
+		if name == "content-type" || name == "content-md5" || strings.HasPrefix(name, "x-amz-") {
+			trimmed := make([]string, len(values))
+			for idx, value := range values {
+				trimmed[idx] = strings.Join(strings.Fields(value), " ")
+			}
+			headers[name] = strings.Join(trimmed, ",")
+		}
+	}
+	names := sortedKeys(headers)
+	var canonical strings.Builder
+	for _, name := range names {
+		canonical.WriteString(name + ":" + headers[name] + "\n")
+	}
+	return canonical.String(), strings.Join(names, ";")
+}
+
+// sigV4Path returns the canonical URI of an escaped path.
+//
+// Each segment is URI encoded once for s3 and twice for other services.
+func sigV4Path(escapedPath string, doubleEncode bool) string {
+	if escapedPath == "" {
+		return "/"
+	}
+	segments := strings.Split(escapedPath, "/")
+	for idx, segment := range segments {
+		if doubleEncode {
+			segments[idx] = sigV4Encode(segment)
+		} else if unescaped, err := url.PathUnescape(segment); err == nil {
+			segments[idx] = sigV4Encode(unescaped)
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// sigV4Query returns the canonical query string.
+func sigV4Query(query map[string][]string) string {
+	var pairs [][2]string
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, [2]string{sigV4Encode(key), sigV4Encode(value)})
+		}
+	}
+	// Sort by key then value
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+	encoded := make([]string, len(pairs))
+	for idx, pair := range pairs {
+		encoded[idx] = pair[0] + "=" + pair[1]
+	}
+	return strings.Join(encoded, "&")
+}
+
+// sigV4Encode percent encodes every byte except the RFC 3986 unreserved characters.
+func sigV4Encode(s string) string {
+	var encoded strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			encoded.WriteByte(c)
+		} else {
+			encoded.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{c})))
+		}
+	}
+	return encoded.String()
+}
+
+// hexSha256 returns the hex encoded SHA-256 hash of data.
+func hexSha256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSha256 returns the HMAC-SHA256 of data using key.
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// requestBody returns the body of a request without consuming it.
+//
+// The body is read from req.GetBody, which is called again afterwards as it may
+// rewind a reader shared with req.Body. Returns false if the body cannot be
+// rewound, in which case it is left unread.
+func requestBody(req *http.Request) ([]byte, bool, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, true, nil
+	}
+	if req.GetBody == nil {
+		return nil, false, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, false, err
+	}
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return nil, false, err
+	}
+	rewound, err := req.GetBody()
+	if err != nil {
+		return nil, false, err
+	}
+	rewound.Close()
+	return data, true, nil
+}
+
+// SetSigner sets the Signer used by QuickRequest.
+//
+// Parameters:
+//   - signer (Signer): The signer to use. Use nil to send unsigned requests.
+func (client *Client) SetSigner(signer Signer) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.signer = signer
+}
+
+// GetSigner returns the clients Signer
+//
+// Returns:
+//   - Signer: The client.signer value
+func (client *Client) GetSigner() Signer {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return client.signer
+}
+
+// SetSigner sets the Signer of each client in the pool.
+//
+// Parameters:
+//   - signer (Signer): The signer to use. Use nil to send unsigned requests.
+func (pool *ClientPool) SetSigner(signer Signer) {
+	for _, client := range pool.getClients() {
+		client.SetSigner(signer)
+	}
+}
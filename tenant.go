@@ -0,0 +1,161 @@
+package HttpClientPool
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrTenantQuota is returned when a tenant has exhausted one of its quotas.
+var ErrTenantQuota = errors.New("tenant quota exceeded")
+
+// TenantConfig configures the share of a ClientPool given to a tenant.
+type TenantConfig struct {
+	// Weight is the share of client handouts given to the tenant when several
+	// tenants are waiting at the same priority. Defaults to 1.
+	Weight float64 `json:"weight,omitempty"`
+
+	// PerMinute is the maximum number of clients handed out to the tenant in
+	// each calendar minute. Use 0 for no limit.
+	PerMinute int `json:"per_minute,omitempty"`
+
+	// PerDay is the maximum number of clients handed out to the tenant in each
+	// UTC calendar day. Use 0 for no limit.
+	PerDay int `json:"per_day,omitempty"`
+}
+
+// TenantUsage reports the use of a ClientPool by a tenant.
+type TenantUsage struct {
+	// Config is the tenant configuration.
+	Config TenantConfig
+	// Total is the number of clients handed out to the tenant.
+	Total int
+	// Minute is the number of clients handed out in the current minute.
+	Minute int
+	// Day is the number of clients handed out in the current day.
+	Day int
+	// Rejected is the number of requests rejected with ErrTenantQuota.
+	Rejected int
+	// Waiting is the number of goroutines of the tenant waiting for a client.
+	Waiting int
+}
+
+// tenantState holds the configuration and usage of a tenant. pool.mu must be held.
+type tenantState struct {
+	config TenantConfig
+	usage  TenantUsage
+	// finishTag is the virtual time at which the tenant's last handout finished.
+	finishTag   float64
+	minuteStart time.Time
+	dayStart    time.Time
+}
+
+// SetTenant sets the weight and quotas of a tenant.
+//
+// Tenants which are not configured have a weight of 1 and no quotas.
+//
+// Parameters:
+//   - name (string): The tenant name used in RequestData.Tenant.
+//   - config (TenantConfig): The tenant configuration.
+func (pool *ClientPool) SetTenant(name string, config TenantConfig) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	pool.queue.tenant(name).config = config
+}
+
+// GetTenantUsage returns the usage of each tenant which has configuration or
+// has been handed a client.
+//
+// Returns:
+//   - map[string]TenantUsage: Map of tenant name to usage.
+func (pool *ClientPool) GetTenantUsage() map[string]TenantUsage {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	now := pool.clock.Now()
+	usage := make(map[string]TenantUsage, len(pool.queue.tenants))
+	for name, state := range pool.queue.tenants {
+		state.roll(now)
+		tenantUsage := state.usage
+		tenantUsage.Config = state.config
+		usage[name] = tenantUsage
+	}
+	for name, waiting := range pool.queue.waiting {
+		tenantUsage := usage[name]
+		tenantUsage.Waiting = waiting
+		usage[name] = tenantUsage
+	}
+	return usage
+}
+
+// tenant returns the state of a tenant, creating it if needed.
+func (queue *waitQueue) tenant(name string) *tenantState {
+	if queue.tenants == nil {
+		queue.tenants = make(map[string]*tenantState)
+	}
+	state, exists := queue.tenants[name]
+	if !exists {
+		state = &tenantState{}
+		queue.tenants[name] = state
+	}
+	return state
+}
+
+// startTag returns the virtual time at which the next handout to a tenant would start.
+//
+// Tenants are never given credit for time spent idle so a returning tenant
+// cannot take a burst of handouts.
+func (queue *waitQueue) startTag(name string) float64 {
+	if state, exists := queue.tenants[name]; exists && state.finishTag > queue.virtualTime {
+		return state.finishTag
+	}
+	return queue.virtualTime
+}
+
+// served records a client handed out to a tenant.
+func (queue *waitQueue) served(name string, now time.Time) {
+	start := queue.startTag(name)
+	state := queue.tenant(name)
+	state.roll(now)
+	weight := state.config.Weight
+	if weight <= 0 {
+		weight = 1
+	}
+	state.finishTag = start + 1/weight
+	queue.virtualTime = start
+	// The tags of waiting tenants moved relative to each other
+	if len(queue.waiting) > 1 {
+		queue.stale = true
+	}
+	state.usage.Total++
+	state.usage.Minute++
+	state.usage.Day++
+}
+
+// checkQuota returns ErrTenantQuota if a tenant has exhausted a quota.
+func (queue *waitQueue) checkQuota(name string, now time.Time) error {
+	state, exists := queue.tenants[name]
+	if !exists {
+		return nil
+	}
+	state.roll(now)
+	var err error
+	if state.config.PerMinute > 0 && state.usage.Minute >= state.config.PerMinute {
+		err = fmt.Errorf("%w: %q reached %d per minute", ErrTenantQuota, name, state.config.PerMinute)
+	} else if state.config.PerDay > 0 && state.usage.Day >= state.config.PerDay {
+		err = fmt.Errorf("%w: %q reached %d per day", ErrTenantQuota, name, state.config.PerDay)
+	}
+	if err != nil {
+		state.usage.Rejected++
+	}
+	return err
+}
+
+// roll resets the minute and day counters when their window has passed.
+func (state *tenantState) roll(now time.Time) {
+	if minute := now.Truncate(time.Minute); !minute.Equal(state.minuteStart) {
+		state.minuteStart, state.usage.Minute = minute, 0
+	}
+	if day := now.UTC().Truncate(24 * time.Hour); !day.Equal(state.dayStart) {
+		state.dayStart, state.usage.Day = day, 0
+	}
+}
@@ -0,0 +1,92 @@
+package HttpClientPool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTenantFairQueueing(t *testing.T) {
+	pool := NewClientPool(0, 0, nil, nil)
+	pool.SetTenant("interactive", TenantConfig{Weight: 3})
+	held := pool.GetClient()
+	// Queue a backlog for each tenant
+	const perTenant = 8
+	var mu sync.Mutex
+	var served []string
+	var wg sync.WaitGroup
+	for idx, tenant := range []string{"interactive", "backfill"} {
+		for i := 0; i < perTenant; i++ {
+			wg.Add(1)
+			go func(tenant string) {
+				defer wg.Done()
+				client, err := pool.GetClientContext(context.Background(), GetClientOptions{Tenant: tenant})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				served = append(served, tenant)
+				mu.Unlock()
+				client.SetInactive()
+			}(tenant)
+			waitForDepth(t, &pool, idx*perTenant+i+1)
+		}
+	}
+	if waiting := pool.GetTenantUsage()["backfill"].Waiting; waiting != perTenant {
+		t.Fatalf("Expected %d backfill waiters, got %d", perTenant, waiting)
+	}
+	held.SetInactive()
+	wg.Wait()
+	// The first 8 handouts are split 3:1
+	counts := make(map[string]int)
+	for _, tenant := range served[:perTenant] {
+		counts[tenant]++
+	}
+	if counts["interactive"] != 6 || counts["backfill"] != 2 {
+		t.Fatalf("Expected a 3:1 split, got %v from %v", counts, served)
+	}
+	usage := pool.GetTenantUsage()
+	if usage["interactive"].Total != perTenant || usage["backfill"].Total != perTenant {
+		t.Fatalf("Unexpected usage %v", usage)
+	}
+	if usage["interactive"].Config.Weight != 3 {
+		t.Fatalf("Expected usage to include the config, got %v", usage["interactive"].Config)
+	}
+}
+
+func TestTenantQuota(t *testing.T) {
+	var queue waitQueue
+	queue.tenant("batch").config = TenantConfig{PerMinute: 2, PerDay: 3}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		if err := queue.checkQuota("batch", now); err != nil {
+			t.Fatal(err)
+		}
+		queue.served("batch", now)
+	}
+	if err := queue.checkQuota("batch", now.Add(30*time.Second)); !errors.Is(err, ErrTenantQuota) {
+		t.Fatalf("Expected the minute quota to be exhausted, got %v", err)
+	}
+	// The minute quota resets but the day quota does not
+	now = now.Add(time.Minute)
+	if err := queue.checkQuota("batch", now); err != nil {
+		t.Fatal(err)
+	}
+	queue.served("batch", now)
+	if err := queue.checkQuota("batch", now.Add(time.Minute)); !errors.Is(err, ErrTenantQuota) {
+		t.Fatalf("Expected the day quota to be exhausted, got %v", err)
+	}
+	if err := queue.checkQuota("batch", now.Add(24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if rejected := queue.tenants["batch"].usage.Rejected; rejected != 2 {
+		t.Fatalf("Expected 2 rejections, got %d", rejected)
+	}
+	// Unconfigured tenants have no quota
+	if err := queue.checkQuota("other", now); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,149 @@
+package HttpClientPool
+
+import (
+	"strings"
+
+	utls "github.com/refraction-networking/utls"
+	"golang.org/x/net/http2"
+)
+
+// TLSProfile describes the TLS ClientHello and HTTP/2 connection a Client should present.
+//
+// Profiles are used to make a Client's fingerprint resemble the browser behind its
+// user agent. The ClientHello is sent by uTLS, reproducing the browser's cipher
+// suite and extension order, GREASE values, curves and key shares. Connections
+// negotiating HTTP/2 are opened with the browser's SETTINGS and WINDOW_UPDATE
+// frames and send the pseudo-headers and headers in the browser's order.
+//
+// Only HTTPS requests are affected. Plain HTTP requests, and HTTPS requests to
+// servers which do not support HTTP/2, are sent by net/http with its header order.
+type TLSProfile struct {
+	// Name is a human readable name for the profile.
+	Name string
+
+	// ClientHelloID selects the uTLS ClientHello to send, e.g. utls.HelloChrome_Auto.
+	ClientHelloID utls.ClientHelloID
+
+	// HTTP2 describes the browser's HTTP/2 connections. Use nil to only offer HTTP/1.1.
+	HTTP2 *HTTP2Fingerprint
+}
+
+// HTTP2Fingerprint describes how a browser opens HTTP/2 connections and orders request headers.
+type HTTP2Fingerprint struct {
+	// Settings are sent in order in the initial SETTINGS frame.
+	Settings []http2.Setting
+
+	// WindowUpdate is the connection window increment sent after the SETTINGS frame. Use 0 for none.
+	WindowUpdate uint32
+
+	// Priority is sent in the HEADERS frame of each request. Use nil for none.
+	Priority *http2.PriorityParam
+
+	// PseudoHeaderOrder is the order of the :method, :authority, :scheme and :path pseudo-headers.
+	PseudoHeaderOrder []string
+
+	// HeaderOrder lists lower case header names in the order they are sent.
+	// Headers which are not listed follow in alphabetical order.
+	HeaderOrder []string
+}
+
+// ChromeTLSProfile is the fingerprint of current Chrome and Edge releases.
+var ChromeTLSProfile = &TLSProfile{
+	Name:          "chrome",
+	ClientHelloID: utls.HelloChrome_Auto,
+	HTTP2: &HTTP2Fingerprint{
+		Settings: []http2.Setting{
+			{ID: http2.SettingHeaderTableSize, Val: 65536},
+			{ID: http2.SettingEnablePush, Val: 0},
+			{ID: http2.SettingInitialWindowSize, Val: 6291456},
+			{ID: http2.SettingMaxHeaderListSize, Val: 262144},
+		},
+		WindowUpdate:      15663105,
+		Priority:          &http2.PriorityParam{Exclusive: true, Weight: 255},
+		PseudoHeaderOrder: []string{":method", ":authority", ":scheme", ":path"},
+		HeaderOrder: []string{
+			"content-length", "cache-control", "sec-ch-ua", "sec-ch-ua-mobile", "sec-ch-ua-platform",
+			"origin", "content-type", "upgrade-insecure-requests", "user-agent", "accept",
+			"sec-fetch-site", "sec-fetch-mode", "sec-fetch-user", "sec-fetch-dest", "referer",
+			"accept-encoding", "accept-language", "cookie", "priority",
+		},
+	},
+}
+
+// FirefoxTLSProfile is the fingerprint of current Firefox releases.
+var FirefoxTLSProfile = &TLSProfile{
+	Name:          "firefox",
+	ClientHelloID: utls.HelloFirefox_Auto,
+	HTTP2: &HTTP2Fingerprint{
+		Settings: []http2.Setting{
+			{ID: http2.SettingHeaderTableSize, Val: 65536},
+			{ID: http2.SettingEnablePush, Val: 0},
+			{ID: http2.SettingInitialWindowSize, Val: 131072},
+			{ID: http2.SettingMaxFrameSize, Val: 16384},
+		},
+		WindowUpdate:      12517377,
+		Priority:          &http2.PriorityParam{Weight: 41},
+		PseudoHeaderOrder: []string{":method", ":path", ":authority", ":scheme"},
+		HeaderOrder: []string{
+			"user-agent", "accept", "accept-language", "accept-encoding", "content-type",
+			"content-length", "origin", "referer", "cookie", "upgrade-insecure-requests",
+			"sec-fetch-dest", "sec-fetch-mode", "sec-fetch-site", "sec-fetch-user", "priority", "te",
+		},
+	},
+}
+
+// SafariTLSProfile is the fingerprint of current Safari releases.
+var SafariTLSProfile = &TLSProfile{
+	Name:          "safari",
+	ClientHelloID: utls.HelloSafari_Auto,
+	HTTP2: &HTTP2Fingerprint{
+		Settings: []http2.Setting{
+			{ID: http2.SettingEnablePush, Val: 0},
+			{ID: http2.SettingMaxConcurrentStreams, Val: 100},
+			{ID: http2.SettingInitialWindowSize, Val: 2097152},
+			// SETTINGS_NO_RFC7540_PRIORITIES
+			{ID: 0x9, Val: 1},
+		},
+		WindowUpdate:      10420225,
+		Priority:          &http2.PriorityParam{Weight: 254},
+		PseudoHeaderOrder: []string{":method", ":scheme", ":authority", ":path"},
+		HeaderOrder: []string{
+			"content-type", "accept", "sec-fetch-site", "origin", "cookie", "sec-fetch-dest",
+			"content-length", "accept-language", "sec-fetch-mode", "user-agent", "referer",
+			"accept-encoding", "priority",
+		},
+	},
+}
+
+// TLSProfileForUserAgent returns the built-in TLSProfile matching the browser in a user agent.
+//
+// Parameters:
+//   - userAgent (string): The user agent string to match.
+//
+// Returns:
+//   - *TLSProfile: The matching profile, or nil if the browser is not recognised.
+func TLSProfileForUserAgent(userAgent string) *TLSProfile {
+	switch {
+	case strings.Contains(userAgent, "Firefox/"):
+		return FirefoxTLSProfile
+	case strings.Contains(userAgent, "Chrome/"), strings.Contains(userAgent, "Chromium/"):
+		return ChromeTLSProfile
+	case strings.Contains(userAgent, "Safari/"):
+		return SafariTLSProfile
+	}
+	return nil
+}
+
+// setting returns the value of a setting sent by the fingerprint.
+//
+// Parameters:
+//   - id (http2.SettingID): The setting.
+//   - fallback (uint32): The value to return if the setting is not sent.
+func (fingerprint *HTTP2Fingerprint) setting(id http2.SettingID, fallback uint32) uint32 {
+	for _, setting := range fingerprint.Settings {
+		if setting.ID == id {
+			return setting.Val
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,404 @@
+package HttpClientPool
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RootInit/HttpClientPool/pooltest"
+	utls "github.com/refraction-networking/utls"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// fingerprintCapture is what a fingerprintServer received from a client.
+type fingerprintCapture struct {
+	clientHello []byte
+	settings    []http2.Setting
+	windowSize  uint32
+	priority    http2.PriorityParam
+	headers     []hpack.HeaderField
+}
+
+// recordingConn records the bytes read from a connection.
+type recordingConn struct {
+	net.Conn
+	read bytes.Buffer
+}
+
+func (conn *recordingConn) Read(p []byte) (int, error) {
+	n, err := conn.Conn.Read(p)
+	conn.read.Write(p[:n])
+	return n, err
+}
+
+// startHTTP2Server starts a TLS server negotiating "h2" which passes the
+// ClientHello and connection of the first client to serve.
+func startHTTP2Server(t *testing.T, serve func(clientHello []byte, conn *tls.Conn)) (string, *tls.Config) {
+	certServer := httptest.NewTLSServer(nil)
+	t.Cleanup(certServer.Close)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		recorder := &recordingConn{Conn: conn}
+		tlsConn := tls.Server(recorder, &tls.Config{Certificates: certServer.TLS.Certificates, NextProtos: []string{"h2"}})
+		if tlsConn.Handshake() != nil {
+			return
+		}
+		// The ClientHello is the first TLS record
+		raw := recorder.read.Bytes()
+		clientHello := raw[:5+int(raw[3])<<8|int(raw[4])]
+		preface := make([]byte, len(http2.ClientPreface))
+		if _, err := io.ReadFull(tlsConn, preface); err != nil {
+			return
+		}
+		serve(clientHello, tlsConn)
+	}()
+	config := certServer.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	return "https://" + listener.Addr().String(), config
+}
+
+// startFingerprintServer starts a minimal HTTP/2 server which captures the
+// ClientHello and the first frames sent by a client.
+func startFingerprintServer(t *testing.T) (string, *tls.Config, chan fingerprintCapture) {
+	captures := make(chan fingerprintCapture, 1)
+	serverUrl, config := startHTTP2Server(t, func(clientHello []byte, conn *tls.Conn) {
+		capture := fingerprintCapture{clientHello: clientHello}
+		framer := http2.NewFramer(conn, conn)
+		framer.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+		for capture.headers == nil {
+			frame, err := framer.ReadFrame()
+			if err != nil {
+				return
+			}
+			switch frame := frame.(type) {
+			case *http2.SettingsFrame:
+				frame.ForeachSetting(func(setting http2.Setting) error {
+					capture.settings = append(capture.settings, setting)
+					return nil
+				})
+			case *http2.WindowUpdateFrame:
+				capture.windowSize = frame.Increment
+			case *http2.MetaHeadersFrame:
+				capture.priority = frame.Priority
+				capture.headers = frame.Fields
+			}
+		}
+		var block bytes.Buffer
+		hpack.NewEncoder(&block).WriteField(hpack.HeaderField{Name: ":status", Value: "200"})
+		framer.WriteSettings()
+		framer.WriteSettingsAck()
+		framer.WriteHeaders(http2.HeadersFrameParam{StreamID: 1, BlockFragment: block.Bytes(), EndHeaders: true})
+		framer.WriteData(1, true, []byte("ok"))
+		captures <- capture
+		io.Copy(io.Discard, conn)
+	})
+	return serverUrl, config, captures
+}
+
+// extensionTypes returns the sorted types of ClientHello extensions. The padding
+// extension is only sent when the ClientHello needs it and SNI is not sent to IP
+// addresses so both are left out.
+func extensionTypes(extensions []utls.TLSExtension) []string {
+	var types []string
+	for _, extension := range extensions {
+		switch extension.(type) {
+		case *utls.UtlsPaddingExtension, *utls.SNIExtension:
+			continue
+		}
+		types = append(types, fmt.Sprintf("%T", extension))
+	}
+	slices.Sort(types)
+	return types
+}
+
+// Captures the ClientHello and HTTP/2 frames sent by profiled clients
+func TestTLSProfileFingerprint(t *testing.T) {
+	for _, profile := range []*TLSProfile{ChromeTLSProfile, FirefoxTLSProfile, SafariTLSProfile} {
+		serverUrl, config, captures := startFingerprintServer(t)
+		client := NewClient(nil, "HttpClient", 0)
+		client.transport.TLSClientConfig = config
+		client.SetTLSProfile(profile)
+		response, err := client.QuickRequest(RequestData{
+			Type:    "GET",
+			Url:     serverUrl + "/path?query=1",
+			Headers: map[string][]string{"Accept": {"*/*"}, "Accept-Language": {"en"}},
+			Cookies: map[string]string{"session": "abc"},
+		})
+		if err != nil || string(response.Body) != "ok" {
+			t.Fatalf("%s: unexpected response %q %v", profile.Name, response.Body, err)
+		}
+		capture := <-captures
+		// The ClientHello is uTLS's, not crypto/tls's
+		spec, err := (&utls.Fingerprinter{}).FingerprintClientHello(capture.clientHello)
+		if err != nil {
+			t.Fatalf("%s: %v", profile.Name, err)
+		}
+		expected, _ := utls.UTLSIdToSpec(profile.ClientHelloID)
+		if !slices.Equal(spec.CipherSuites, expected.CipherSuites) {
+			t.Errorf("%s: expected cipher suites %v, got %v", profile.Name, expected.CipherSuites, spec.CipherSuites)
+		}
+		if got, expected := extensionTypes(spec.Extensions), extensionTypes(expected.Extensions); !slices.Equal(got, expected) {
+			t.Errorf("%s: expected extensions %v, got %v", profile.Name, expected, got)
+		}
+		grease := slices.ContainsFunc(spec.Extensions, func(extension utls.TLSExtension) bool {
+			_, ok := extension.(*utls.UtlsGREASEExtension)
+			return ok
+		})
+		if grease != (profile != FirefoxTLSProfile) {
+			t.Errorf("%s: unexpected GREASE %v", profile.Name, grease)
+		}
+		// The HTTP/2 connection preface and header order are the browser's
+		fingerprint := profile.HTTP2
+		if !slices.Equal(capture.settings, fingerprint.Settings) || capture.windowSize != fingerprint.WindowUpdate {
+			t.Errorf("%s: unexpected SETTINGS %v and WINDOW_UPDATE %d", profile.Name, capture.settings, capture.windowSize)
+		}
+		if capture.priority != *fingerprint.Priority {
+			t.Errorf("%s: unexpected priority %+v", profile.Name, capture.priority)
+		}
+		var names []string
+		for _, field := range capture.headers {
+			names = append(names, field.Name)
+		}
+		var expectedNames []string
+		expectedNames = append(expectedNames, fingerprint.PseudoHeaderOrder...)
+		for _, name := range fingerprint.HeaderOrder {
+			if name == "user-agent" || name == "accept" || name == "accept-language" || name == "cookie" {
+				expectedNames = append(expectedNames, name)
+			}
+		}
+		if !slices.Equal(names, expectedNames) {
+			t.Errorf("%s: expected header order %v, got %v", profile.Name, expectedNames, names)
+		}
+	}
+}
+
+// Checks the profiled HTTP/2 transport against the net/http server
+func TestTLSProfileHTTP2(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Sum", fmt.Sprintf("%x", sha256.Sum256(body)))
+		if r.URL.Path == "/large" {
+			w.Write(bytes.Repeat([]byte("x"), 4<<20))
+			return
+		}
+		w.Write([]byte(r.Proto))
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	config := server.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	httpProxy, socksProxy := pooltest.NewHTTPProxy(t), pooltest.NewSOCKS5Proxy(t)
+	for _, proxy := range []*pooltest.Proxy{nil, httpProxy, socksProxy} {
+		var proxyUrl *url.URL
+		if proxy != nil {
+			proxyUrl = proxy.URL()
+		}
+		client := NewClient(proxyUrl, "HttpClient", 0)
+		client.transport.TLSClientConfig = config
+		client.SetTLSProfile(ChromeTLSProfile)
+		client.SetMaxInFlight(0)
+		response, err := client.QuickRequest(RequestData{Type: "GET", Url: server.URL})
+		if err != nil || string(response.Body) != "HTTP/2.0" {
+			t.Fatalf("%v: unexpected response %q %v", proxyUrl, response.Body, err)
+		}
+		// Request and response bodies larger than the flow control windows
+		body := bytes.Repeat([]byte("0123456789"), 1<<17)
+		res, err := client.Post(server.URL+"/large", "text/plain", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		received, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil || len(received) != 4<<20 || res.Header.Get("X-Sum") != fmt.Sprintf("%x", sha256.Sum256(body)) {
+			t.Fatalf("%v: unexpected large response of %d bytes %v", proxyUrl, len(received), err)
+		}
+		// Concurrent requests share the connection
+		var wg sync.WaitGroup
+		errs := make(chan error, 20)
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				res, err := client.Post(server.URL, "text/plain", strings.NewReader("body"))
+				if err == nil {
+					io.Copy(io.Discard, res.Body)
+					res.Body.Close()
+				}
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if conns := client.profileTransport.conns[server.Listener.Addr().String()]; len(conns) != 1 {
+			t.Errorf("%v: expected 1 connection, got %d", proxyUrl, len(conns))
+		}
+		if proxy != nil {
+			proxy.AssertUsed(t, server.Listener.Addr().String())
+		}
+	}
+}
+
+// Servers without HTTP/2 are sent requests over HTTP/1.1 with the profile's ClientHello
+func TestTLSProfileHTTP1(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	defer server.Close()
+	proxy := pooltest.NewHTTPProxy(t)
+	client := NewClient(proxy.URL(), "HttpClient", 0)
+	client.transport.TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	client.SetTLSProfile(FirefoxTLSProfile)
+	for i := 0; i < 2; i++ {
+		response, err := client.QuickRequest(RequestData{Type: "GET", Url: server.URL})
+		if err != nil || string(response.Body) != "HTTP/1.1" {
+			t.Fatalf("Unexpected response %q %v", response.Body, err)
+		}
+	}
+	// The handshaken connection was reused
+	if requests := proxy.Requests(); len(requests) != 1 {
+		t.Errorf("Expected 1 tunnel, got %d", len(requests))
+	}
+}
+
+func TestTLSProfileForUserAgent(t *testing.T) {
+	userAgents := map[string]*TLSProfile{
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.1": SafariTLSProfile,
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/122.0.0.0 Safari/537.3":       ChromeTLSProfile,
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:109.0) Gecko/20100101 Firefox/117.":                                      FirefoxTLSProfile,
+		"HttpClient": nil,
+	}
+	for userAgent, expected := range userAgents {
+		if profile := TLSProfileForUserAgent(userAgent); profile != expected {
+			t.Errorf("Unexpected profile %v for %s", profile, userAgent)
+		}
+	}
+}
+
+// Idle HTTP/2 connections are closed after the IdleConnTimeout of the transport
+func TestTLSProfileIdleTimeout(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	client := NewClient(nil, "HttpClient", 0)
+	client.transport.TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	client.transport.IdleConnTimeout = 20 * time.Millisecond
+	client.SetTLSProfile(ChromeTLSProfile)
+	response, err := client.QuickRequest(RequestData{Type: "GET", Url: server.URL})
+	if err != nil || string(response.Body) != "HTTP/2.0" {
+		t.Fatalf("Unexpected response %q %v", response.Body, err)
+	}
+	transport := client.profileTransport
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		transport.mu.Lock()
+		open := len(transport.conns)
+		transport.mu.Unlock()
+		if open == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Idle connection was not closed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// A new connection is opened for the next request
+	if response, err := client.QuickRequest(RequestData{Type: "GET", Url: server.URL}); err != nil || string(response.Body) != "HTTP/2.0" {
+		t.Fatalf("Unexpected response %q %v", response.Body, err)
+	}
+}
+
+// Servers overrunning the stream window or not answering are cut off
+func TestTLSProfileHTTP2Limits(t *testing.T) {
+	// A fingerprint with the default 64KB windows
+	fingerprint := *ChromeTLSProfile.HTTP2
+	fingerprint.Settings = []http2.Setting{{ID: http2.SettingInitialWindowSize, Val: 65535}}
+	fingerprint.WindowUpdate = 0
+	profile := &TLSProfile{Name: "small-window", ClientHelloID: ChromeTLSProfile.ClientHelloID, HTTP2: &fingerprint}
+	// serve answers the first request with body data, then reports the RST_STREAM code
+	resets := make(chan http2.ErrCode, 1)
+	serve := func(dataFrames int) func([]byte, *tls.Conn) {
+		return func(_ []byte, conn *tls.Conn) {
+			framer := http2.NewFramer(conn, conn)
+			framer.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+			framer.WriteSettings()
+			for {
+				frame, err := framer.ReadFrame()
+				if err != nil {
+					return
+				}
+				switch frame := frame.(type) {
+				case *http2.MetaHeadersFrame:
+					if dataFrames == 0 {
+						// Never answer
+						continue
+					}
+					var block bytes.Buffer
+					hpack.NewEncoder(&block).WriteField(hpack.HeaderField{Name: ":status", Value: "200"})
+					framer.WriteHeaders(http2.HeadersFrameParam{StreamID: frame.StreamID, BlockFragment: block.Bytes(), EndHeaders: true})
+					// Ignore flow control
+					for i := 0; i < dataFrames; i++ {
+						framer.WriteData(frame.StreamID, false, make([]byte, 16384))
+					}
+				case *http2.RSTStreamFrame:
+					resets <- frame.ErrCode
+					return
+				}
+			}
+		}
+	}
+	newClient := func(serverUrl string, config *tls.Config) *Client {
+		client := NewClient(nil, "HttpClient", 0)
+		client.transport.TLSClientConfig = config
+		client.transport.ResponseHeaderTimeout = 50 * time.Millisecond
+		client.SetTLSProfile(profile)
+		return client
+	}
+	serverUrl, config := startHTTP2Server(t, serve(5))
+	res, err := newClient(serverUrl, config).Get(serverUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if code := <-resets; code != http2.ErrCodeFlowControl {
+		t.Errorf("Expected FLOW_CONTROL_ERROR, got %v", code)
+	}
+	if _, err := io.ReadAll(res.Body); !errors.Is(err, http2.StreamError{StreamID: 1, Code: http2.ErrCodeFlowControl}) {
+		t.Errorf("Expected a flow control stream error, got %v", err)
+	}
+	serverUrl, config = startHTTP2Server(t, serve(0))
+	if _, err := newClient(serverUrl, config).Get(serverUrl); !errors.Is(err, errHTTP2HeaderTimeout) {
+		t.Errorf("Expected a response header timeout, got %v", err)
+	}
+	if code := <-resets; code != http2.ErrCodeCancel {
+		t.Errorf("Expected CANCEL, got %v", code)
+	}
+}
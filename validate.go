@@ -0,0 +1,174 @@
+package HttpClientPool
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Errors wrapped by a ValidationError describing why RequestData is invalid.
+var (
+	ErrConflictingBody = errors.New("conflicting request body fields")
+	ErrUnknownMethod   = errors.New("unknown HTTP method")
+	ErrMalformedURL    = errors.New("malformed URL")
+	ErrUnreadableFile  = errors.New("file is not readable")
+	ErrInvalidHeader   = errors.New("header cannot be encoded")
+)
+
+// ValidationError describes an invalid RequestData field.
+type ValidationError struct {
+	// Field is the name of the RequestData field, including the map key if any.
+	Field string
+	// Err is the reason the field is invalid.
+	Err error
+}
+
+// Error implements the error interface.
+func (err *ValidationError) Error() string {
+	return fmt.Sprintf("invalid RequestData.%s: %v", err.Field, err.Err)
+}
+
+// Unwrap returns the underlying reason.
+func (err *ValidationError) Unwrap() error {
+	return err.Err
+}
+
+// knownMethods contains the HTTP methods accepted by Validate.
+var knownMethods = map[string]bool{
+	"":                 true, // Defaults to GET
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// Validate checks the RequestData for mistakes that would otherwise result in a
+// surprising request.
+//
+// All problems found are returned joined together. Each is a *ValidationError
+// wrapping one of ErrConflictingBody, ErrUnknownMethod, ErrMalformedURL,
+// ErrUnreadableFile, ErrInvalidHeader or ErrUnknownContentType and can be
+// checked with errors.Is and errors.As.
+//
+// Returns:
+//   - error: The validation errors, nil if the RequestData is valid.
+func (reqData RequestData) Validate() error {
+	var errs []error
+	invalid := func(field string, err error) {
+		errs = append(errs, &ValidationError{Field: field, Err: err})
+	}
+	// Check method
+	if !knownMethods[reqData.Type] {
+		invalid("Type", fmt.Errorf("%w %q", ErrUnknownMethod, reqData.Type))
+	}
+	// Check url
+	parsedUrl, err := url.Parse(reqData.Url)
+	if err != nil {
+		invalid("Url", fmt.Errorf("%w: %v", ErrMalformedURL, err))
+	} else if parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https" {
+		invalid("Url", fmt.Errorf("%w: unsupported scheme %q", ErrMalformedURL, parsedUrl.Scheme))
+	} else if parsedUrl.Host == "" {
+		invalid("Url", fmt.Errorf("%w: missing host", ErrMalformedURL))
+	}
+	// Check body fields
+	var bodies []string
+	if reqData.RawData != nil {
+		bodies = append(bodies, "RawData")
+		if *reqData.RawData == nil {
+			invalid("RawData", fmt.Errorf("%w: nil io.Reader", ErrUnreadableFile))
+		}
+	}
+	if reqData.JsonData != nil {
+		bodies = append(bodies, "JsonData")
+	}
+	if reqData.Data != nil {
+		bodies = append(bodies, "Data")
+		if _, err := lookupBodyEncoder(reqData.ContentType); err != nil {
+			invalid("ContentType", err)
+		}
+	}
+	if reqData.FormData != nil || reqData.FormFiles != nil || reqData.FormUploads != nil {
+		bodies = append(bodies, "FormData")
+	}
+	if len(bodies) > 1 {
+		invalid(bodies[0], fmt.Errorf("%w: %s", ErrConflictingBody, strings.Join(bodies, ", ")))
+	}
+	// Check files
+	for field, file := range reqData.FormFiles {
+		if err := checkReadable(file); err != nil {
+			invalid(fmt.Sprintf("FormFiles[%q]", field), fmt.Errorf("%w: %v", ErrUnreadableFile, err))
+		}
+	}
+	for field, upload := range reqData.FormUploads {
+		if upload.Reader == nil {
+			invalid(fmt.Sprintf("FormUploads[%q]", field), fmt.Errorf("%w: nil io.Reader", ErrUnreadableFile))
+		}
+	}
+	// Check headers
+	for key, values := range reqData.Headers {
+		if !validHeaderName(key) {
+			invalid(fmt.Sprintf("Headers[%q]", key), fmt.Errorf("%w: invalid name", ErrInvalidHeader))
+		}
+		for _, value := range values {
+			if !validHeaderValue(value) {
+				invalid(fmt.Sprintf("Headers[%q]", key), fmt.Errorf("%w: invalid value %q", ErrInvalidHeader, value))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkReadable returns an error if a form file cannot be read.
+func checkReadable(file *os.File) error {
+	if file == nil {
+		return errors.New("nil *os.File")
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return errors.New("is a directory")
+	}
+	if info.Mode().IsRegular() {
+		// Detects closed and write only files without moving the offset
+		if _, err := file.ReadAt(make([]byte, 1), 0); err != nil && err != io.EOF {
+			return err
+		}
+	}
+	return nil
+}
+
+// validHeaderName returns true if name is a valid HTTP header field name token.
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c >= 0x7f || c <= ' ' || strings.IndexByte(`"(),/:;<=>?@[\]{}`, c) != -1 {
+			return false
+		}
+	}
+	return true
+}
+
+// validHeaderValue returns true if value contains no control characters other than tab.
+func validHeaderValue(value string) bool {
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if (c < ' ' && c != '\t') || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package HttpClientPool
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	// Valid request
+	valid := RequestData{
+		Type:     "POST",
+		Url:      "https://example.com/path",
+		JsonData: map[string]bool{"ok": true},
+		Headers:  map[string][]string{"X-Test": {"1"}},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	// Invalid requests
+	writeOnly, err := os.CreateTemp("", "writeOnly.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(writeOnly.Name())
+	writeOnly.Close()
+	writeOnly, err = os.OpenFile(writeOnly.Name(), os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writeOnly.Close()
+	var raw io.Reader = strings.NewReader("raw")
+	tests := map[string]struct {
+		reqData  RequestData
+		expected error
+	}{
+		"method":   {RequestData{Type: "get", Url: "http://example.com"}, ErrUnknownMethod},
+		"scheme":   {RequestData{Url: "example.com/path"}, ErrMalformedURL},
+		"host":     {RequestData{Url: "http:///path"}, ErrMalformedURL},
+		"url":      {RequestData{Url: "http://exa mple.com"}, ErrMalformedURL},
+		"conflict": {RequestData{Url: "http://example.com", JsonData: 1, RawData: &raw}, ErrConflictingBody},
+		"form":     {RequestData{Url: "http://example.com", JsonData: 1, FormData: map[string]string{}}, ErrConflictingBody},
+		"encoder":  {RequestData{Url: "http://example.com", Data: 1, ContentType: "application/unknown"}, ErrUnknownContentType},
+		"file":     {RequestData{Url: "http://example.com", FormFiles: map[string]*os.File{"f": writeOnly}}, ErrUnreadableFile},
+		"upload":   {RequestData{Url: "http://example.com", FormUploads: map[string]FormUpload{"f": {}}}, ErrUnreadableFile},
+		"name":     {RequestData{Url: "http://example.com", Headers: map[string][]string{"Bad Name": {"1"}}}, ErrInvalidHeader},
+		"value":    {RequestData{Url: "http://example.com", Headers: map[string][]string{"X-Test": {"1\r\nX-Injected: 1"}}}, ErrInvalidHeader},
+	}
+	for name, test := range tests {
+		err := test.reqData.Validate()
+		if !errors.Is(err, test.expected) {
+			t.Errorf("%s: Expected %v got %v", name, test.expected, err)
+		}
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Errorf("%s: Expected *ValidationError got %T", name, err)
+		}
+	}
+	// QuickRequest rejects invalid requests
+	client := NewClient(nil, "HttpClient", 0)
+	if _, err := client.QuickRequest(tests["method"].reqData); !errors.Is(err, ErrUnknownMethod) {
+		t.Errorf("Expected ErrUnknownMethod got %v", err)
+	}
+}